@@ -32,12 +32,14 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/accelerator"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/cvm"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/disk"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/guestagent"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/hostnamevalidation"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/hotattach"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/imageboot"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/imagevalidation"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/licensevalidation"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/livemigrate"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/loadbalancer"
@@ -48,12 +50,15 @@ import (
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/oslogin"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/packageupgrade"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/packagevalidation"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/resilience"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/security"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/shapevalidation"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/spot"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/sql"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/ssh"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/storageperf"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/suspendresume"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/timesync"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/windowscontainers"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/test_suites/winrm"
 	"github.com/GoogleCloudPlatform/compute-daisy/compute"
@@ -259,6 +264,26 @@ func main() {
 			windowscontainers.Name,
 			windowscontainers.TestSetup,
 		},
+		{
+			spot.Name,
+			spot.TestSetup,
+		},
+		{
+			imagevalidation.Name,
+			imagevalidation.TestSetup,
+		},
+		{
+			resilience.Name,
+			resilience.TestSetup,
+		},
+		{
+			accelerator.Name,
+			accelerator.TestSetup,
+		},
+		{
+			timesync.Name,
+			timesync.TestSetup,
+		},
 	}
 
 	ctx := context.Background()
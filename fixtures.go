@@ -778,6 +778,34 @@ func (t *TestVM) SetPrivateIP(network *Network, networkIP string) error {
 	return fmt.Errorf("not found network interface %s", network.name)
 }
 
+// SetStackType sets the stack type, such as IPV4_IPV6, of the current test
+// VM's network interface on the target network.
+func (t *TestVM) SetStackType(network *Network, stackType string) error {
+	if t.instance != nil {
+		if t.instance.NetworkInterfaces == nil {
+			return fmt.Errorf("must call AddCustomNetwork prior to SetStackType")
+		}
+		for _, nic := range t.instance.NetworkInterfaces {
+			if nic.Network == network.name {
+				nic.StackType = stackType
+				return nil
+			}
+		}
+	} else if t.instancebeta != nil {
+		if t.instancebeta.NetworkInterfaces == nil {
+			return fmt.Errorf("must call AddCustomNetwork prior to SetStackType")
+		}
+		for _, nic := range t.instancebeta.NetworkInterfaces {
+			if nic.Network == network.name {
+				nic.StackType = stackType
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("not found network interface %s", network.name)
+}
+
 // Network represent network used by vm in setup.go.
 type Network struct {
 	name         string
@@ -818,6 +846,12 @@ func (n *Network) SetMTU(mtu int) {
 	}
 }
 
+// EnableULAInternalIPv6 allocates the network a ULA internal IPv6 range, which
+// subnetworks with an INTERNAL IPv6 access type assign addresses from.
+func (n *Network) EnableULAInternalIPv6() {
+	n.network.EnableUlaInternalIpv6 = true
+}
+
 // CreateSubnetwork creates custom subnetwork. Using AddCustomNetwork method
 // provided by TestVM to config network on vm
 func (n *Network) CreateSubnetwork(name string, ipRange string) (*Subnetwork, error) {
@@ -851,6 +885,13 @@ func (s *Subnetwork) SetRole(role string) {
 	s.subnetwork.Role = role
 }
 
+// SetStackType sets the subnetwork stack type and, for stack types with IPv6,
+// whether its IPv6 addresses are INTERNAL or EXTERNAL.
+func (s *Subnetwork) SetStackType(stackType, ipv6AccessType string) {
+	s.subnetwork.StackType = stackType
+	s.subnetwork.Ipv6AccessType = ipv6AccessType
+}
+
 // AddSecondaryRange add secondary IP range to Subnetwork
 func (s Subnetwork) AddSecondaryRange(rangeName, ipRange string) {
 	s.subnetwork.SecondaryIpRanges = append(s.subnetwork.SecondaryIpRanges, &compute.SubnetworkSecondaryRange{
@@ -0,0 +1,183 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accelerator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const nvidiaVendorID = "0x10de"
+
+// nvlinkMachineTypes are machine type prefixes whose GPUs are connected
+// with NVLink.
+var nvlinkMachineTypes = []string{"a2-", "a3-"}
+
+var nvlinkRe = regexp.MustCompile(`^NV\d+$`)
+
+// nvidia-smi underlines the topology header with terminal escape codes.
+var ansiEscapeRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// gpuPCIDevices returns the PCI addresses of NVIDIA display controllers, which
+// are visible whether or not the driver is installed.
+func gpuPCIDevices() ([]string, error) {
+	paths, err := filepath.Glob("/sys/bus/pci/devices/*")
+	if err != nil {
+		return nil, err
+	}
+	var gpus []string
+	for _, path := range paths {
+		vendor, err := os.ReadFile(filepath.Join(path, "vendor"))
+		if err != nil || strings.TrimSpace(string(vendor)) != nvidiaVendorID {
+			continue
+		}
+		class, err := os.ReadFile(filepath.Join(path, "class"))
+		// 0x03xxxx is the display controller class.
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(string(class)), "0x03") {
+			continue
+		}
+		gpus = append(gpus, filepath.Base(path))
+	}
+	return gpus, nil
+}
+
+// requireNvidiaGPUs skips the test unless the instance has at least min
+// NVIDIA GPUs and the driver is installed, and returns their PCI addresses.
+func requireNvidiaGPUs(t *testing.T, min int) []string {
+	t.Helper()
+	utils.LinuxOnly(t)
+	gpus, err := gpuPCIDevices()
+	if err != nil {
+		t.Fatalf("could not list PCI devices: %v", err)
+	}
+	if len(gpus) < min {
+		t.Skipf("found %d NVIDIA GPUs, test requires at least %d", len(gpus), min)
+	}
+	if !utils.CheckLinuxCmdExists("nvidia-smi") {
+		t.Skip("nvidia-smi is not installed, NVIDIA driver is not present")
+	}
+	return gpus
+}
+
+// TestGPUTopology validates that all GPUs on a multi-GPU instance are
+// enumerated by the driver, are interconnected as expected, and report their
+// NUMA affinity.
+func TestGPUTopology(t *testing.T) {
+	gpus := requireNvidiaGPUs(t, 2)
+	out, err := exec.Command("nvidia-smi", "--query-gpu=index,pci.bus_id", "--format=csv,noheader").CombinedOutput()
+	if err != nil {
+		t.Fatalf("nvidia-smi failed: %v %s", err, out)
+	}
+	enumerated := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(enumerated) != len(gpus) {
+		t.Errorf("driver enumerates %d GPUs, want %d found on the PCI bus %v: %s", len(enumerated), len(gpus), gpus, out)
+	}
+
+	topo, err := exec.Command("nvidia-smi", "topo", "-m").CombinedOutput()
+	if err != nil {
+		t.Fatalf("nvidia-smi topo -m failed: %v %s", err, topo)
+	}
+	matrix, affinity, err := parseTopology(string(topo))
+	if err != nil {
+		t.Fatalf("%v, topology matrix:\n%s", err, topo)
+	}
+	machineType, err := utils.GetMetadata(utils.Context(t), "instance", "machine-type")
+	if err != nil {
+		t.Fatalf("could not get machine type from metadata: %v", err)
+	}
+	machineType = filepath.Base(machineType)
+	var wantNVLink bool
+	for _, prefix := range nvlinkMachineTypes {
+		if strings.HasPrefix(machineType, prefix) {
+			wantNVLink = true
+		}
+	}
+	for i, row := range matrix {
+		for j, link := range row {
+			switch {
+			case i == j && link != "X":
+				t.Errorf("GPU%d has link %q to itself, want X", i, link)
+			case i != j && wantNVLink && !nvlinkRe.MatchString(link):
+				t.Errorf("GPU%d and GPU%d are connected by %s, want NVLink on %s", i, j, link, machineType)
+			case i != j && link == "X":
+				t.Errorf("GPU%d and GPU%d have no connection", i, j)
+			}
+		}
+		if affinity[i] == "" {
+			t.Errorf("GPU%d reports no NUMA affinity", i)
+		}
+	}
+	t.Logf("GPU NUMA affinity: %v", affinity)
+	if t.Failed() {
+		t.Logf("topology matrix:\n%s", topo)
+	}
+}
+
+// parseTopology parses the GPU to GPU section of nvidia-smi topo -m, returning
+// the link type between each pair of GPUs and the NUMA affinity of each GPU.
+func parseTopology(topo string) ([][]string, []string, error) {
+	lines := strings.Split(ansiEscapeRe.ReplaceAllString(topo, ""), "\n")
+	if len(lines) == 0 {
+		return nil, nil, fmt.Errorf("empty topology")
+	}
+	header := strings.Split(strings.TrimSpace(lines[0]), "\t")
+	var gpuCols int
+	numaCol := -1
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if strings.HasPrefix(h, "GPU") {
+			gpuCols++
+		}
+		if h == "NUMA Affinity" {
+			numaCol = i
+		}
+	}
+	if gpuCols == 0 {
+		return nil, nil, fmt.Errorf("no GPU columns in topology header")
+	}
+	var matrix [][]string
+	var affinity []string
+	for _, line := range lines[1:] {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "GPU") {
+			continue
+		}
+		if len(fields) < gpuCols+1 {
+			return nil, nil, fmt.Errorf("malformed topology row %q", line)
+		}
+		var row []string
+		for _, f := range fields[1 : gpuCols+1] {
+			row = append(row, strings.TrimSpace(f))
+		}
+		matrix = append(matrix, row)
+		// Rows have a leading row label which the header doesn't.
+		if numaCol >= 0 && numaCol+1 < len(fields) {
+			affinity = append(affinity, strings.TrimSpace(fields[numaCol+1]))
+		} else {
+			affinity = append(affinity, "")
+		}
+	}
+	if len(matrix) != gpuCols {
+		return nil, nil, fmt.Errorf("topology has %d GPU rows and %d GPU columns", len(matrix), gpuCols)
+	}
+	return matrix, affinity, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accelerator
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestGPUPersistenceMode validates that the NVIDIA persistence daemon is
+// running and every GPU has persistence mode enabled.
+func TestGPUPersistenceMode(t *testing.T) {
+	requireNvidiaGPUs(t, 1)
+	out, err := exec.Command("systemctl", "is-active", "nvidia-persistenced.service").Output()
+	if state := strings.TrimSpace(string(out)); state != "active" {
+		t.Errorf("nvidia-persistenced is %s, want active: %v", state, err)
+	}
+	out, err = exec.Command("nvidia-smi", "--query-gpu=index,persistence_mode", "--format=csv,noheader").CombinedOutput()
+	if err != nil {
+		t.Fatalf("nvidia-smi failed: %v %s", err, out)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		index, mode, ok := strings.Cut(line, ",")
+		if !ok {
+			t.Fatalf("unexpected nvidia-smi output %q", line)
+		}
+		mode = strings.TrimSpace(mode)
+		t.Logf("GPU %s persistence mode: %s", index, mode)
+		if mode != "Enabled" {
+			t.Errorf("GPU %s persistence mode is %s, want Enabled", index, mode)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accelerator is a CIT suite for testing GPU functionality on
+// accelerator optimized machine types.
+package accelerator
+
+import (
+	"flag"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	daisy "github.com/GoogleCloudPlatform/compute-daisy"
+	"google.golang.org/api/compute/v1"
+)
+
+// Name is the name of the test package. It must match the directory name.
+var Name = "accelerator"
+
+var gpuMachineType = flag.String("accelerator_machine_type", "", "GPU machine type to run accelerator tests on, such as a2-highgpu-2g. The suite is skipped if unset, as GPU capacity is scarce and expensive")
+
+// TestSetup sets up the test workflow.
+func TestSetup(t *imagetest.TestWorkflow) error {
+	if *gpuMachineType == "" {
+		t.Skip("no accelerator machine type specified")
+		return nil
+	}
+	inst := &daisy.Instance{}
+	inst.Scopes = append(inst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+	// GPU instances can't live migrate.
+	inst.Scheduling = &compute.Scheduling{OnHostMaintenance: "TERMINATE"}
+	vm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "gpu", Type: imagetest.PdBalanced}}, inst)
+	if err != nil {
+		return err
+	}
+	vm.ForceMachineType(*gpuMachineType)
+	vm.RunTests("TestGPUTopology|TestGPUPersistenceMode")
+	return nil
+}
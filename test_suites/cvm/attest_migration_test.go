@@ -0,0 +1,164 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cvm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// quotePCRs are the PCRs included in each quote.
+	quotePCRs = "sha256:0,1,2,3,4,5,6,7"
+	// quotesAfterMigration is how long quotes keep being collected after the
+	// migration finishes.
+	quotesAfterMigration = 30 * time.Second
+)
+
+// quoteResult is the outcome of a single quote and its verification.
+type quoteResult struct {
+	index int
+	taken time.Time
+	err   error
+}
+
+// tpm2 runs a tpm2-tools command against the kernel resource manager.
+func tpm2(args ...string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "TPM2TOOLS_TCTI=device:/dev/tpmrm0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", args[0], err, out)
+	}
+	return nil
+}
+
+// quoteAndVerify takes a quote over quotePCRs with a fresh nonce and checks it
+// against the public attestation key in dir.
+func quoteAndVerify(dir string, index int) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	qualification := hex.EncodeToString(nonce)
+	msg := filepath.Join(dir, fmt.Sprintf("quote%d.msg", index))
+	sig := filepath.Join(dir, fmt.Sprintf("quote%d.sig", index))
+	pcrs := filepath.Join(dir, fmt.Sprintf("quote%d.pcrs", index))
+	if err := tpm2("tpm2_quote", "-c", filepath.Join(dir, "ak.ctx"), "-l", quotePCRs, "-q", qualification, "-m", msg, "-s", sig, "-o", pcrs, "-g", "sha256"); err != nil {
+		return err
+	}
+	return tpm2("tpm2_checkquote", "-u", filepath.Join(dir, "ak.pub"), "-m", msg, "-s", sig, "-f", pcrs, "-g", "sha256", "-q", qualification)
+}
+
+// requireConfidentialGuest skips the test unless the kernel reports running
+// with memory encryption.
+func requireConfidentialGuest(t *testing.T) {
+	t.Helper()
+	output, err := exec.Command("dmesg").CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not read dmesg: %v", err)
+	}
+	for _, list := range [][]string{sevMsgList, sevSnpMsgList, tdxMsgList} {
+		for _, m := range list {
+			if strings.Contains(string(output), m) {
+				return
+			}
+		}
+	}
+	t.Skip("not running on a confidential machine type")
+}
+
+// TestAttestDuringMigration validates that attestation quotes taken before,
+// during, and after a live migration all verify.
+func TestAttestDuringMigration(t *testing.T) {
+	utils.LinuxOnly(t)
+	requireConfidentialGuest(t)
+	for _, cmd := range []string{"tpm2_createek", "tpm2_createak", "tpm2_quote", "tpm2_checkquote"} {
+		if !utils.CheckLinuxCmdExists(cmd) {
+			t.Skipf("%s is not installed", cmd)
+		}
+	}
+	if _, err := os.Stat("/dev/tpmrm0"); err != nil {
+		t.Skipf("no TPM resource manager available: %v", err)
+	}
+	dir := t.TempDir()
+	if err := tpm2("tpm2_createek", "-c", filepath.Join(dir, "ek.ctx"), "-G", "rsa", "-u", filepath.Join(dir, "ek.pub")); err != nil {
+		t.Fatalf("could not create endorsement key: %v", err)
+	}
+	if err := tpm2("tpm2_createak", "-C", filepath.Join(dir, "ek.ctx"), "-c", filepath.Join(dir, "ak.ctx"), "-G", "rsa", "-g", "sha256", "-s", "rsassa", "-u", filepath.Join(dir, "ak.pub"), "-f", "pem", "-n", filepath.Join(dir, "ak.name")); err != nil {
+		t.Fatalf("could not create attestation key: %v", err)
+	}
+	if err := quoteAndVerify(dir, -1); err != nil {
+		t.Fatalf("quote before migration did not verify: %v", err)
+	}
+
+	var mu sync.Mutex
+	var results []quoteResult
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			r := quoteResult{index: i, taken: time.Now()}
+			r.err = quoteAndVerify(dir, i)
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}
+	}()
+
+	migrationStart := time.Now()
+	migrateSelf(t)
+	migrationEnd := time.Now()
+	time.Sleep(quotesAfterMigration)
+	close(stop)
+	<-done
+
+	var before, during, after int
+	for _, r := range results {
+		phase := "during"
+		switch {
+		case r.taken.Before(migrationStart):
+			phase = "before"
+			before++
+		case r.taken.After(migrationEnd):
+			phase = "after"
+			after++
+		default:
+			during++
+		}
+		if r.err != nil {
+			t.Errorf("quote %d taken %s migration (%v from migration start) did not verify: %v", r.index, phase, r.taken.Sub(migrationStart).Round(time.Millisecond), r.err)
+		}
+	}
+	t.Logf("took %d quotes before, %d during, and %d after a %v migration", before, during, after, migrationEnd.Sub(migrationStart).Round(time.Second))
+	if after == 0 {
+		t.Error("no quotes were taken after the migration finished")
+	}
+}
@@ -29,6 +29,19 @@ import (
 var sevMsgList = []string{"AMD Secure Encrypted Virtualization (SEV) active", "AMD Memory Encryption Features active: SEV", "Memory Encryption Features active: AMD SEV"}
 var sevSnpMsgList = []string{"SEV: SNP guest platform device initialized", "Memory Encryption Features active: SEV SEV-ES SEV-SNP", "Memory Encryption Features active: AMD SEV SEV-ES SEV-SNP"}
 var tdxMsgList = []string{"Memory Encryption Features active: TDX", "Memory Encryption Features active: Intel TDX"}
+var ccaMsgList = []string{"RME: Using RSI version", "Realm Management Extension"}
+
+// requireArchitecture skips the test if the guest is not running on arch.
+func requireArchitecture(t *testing.T, arch string) {
+	t.Helper()
+	guestArch, err := utils.GuestArchitecture()
+	if err != nil {
+		t.Fatalf("could not determine guest architecture: %v", err)
+	}
+	if guestArch != arch {
+		t.Skipf("test only applies to %s, guest is %s", arch, guestArch)
+	}
+}
 
 func searchDmesg(t *testing.T, matches []string) {
 	output, err := exec.Command("dmesg").CombinedOutput()
@@ -44,31 +57,29 @@ func searchDmesg(t *testing.T, matches []string) {
 }
 
 func TestSEVEnabled(t *testing.T) {
+	requireArchitecture(t, utils.ArchX86_64)
 	searchDmesg(t, sevMsgList)
 }
 
 func TestSEVSNPEnabled(t *testing.T) {
+	requireArchitecture(t, utils.ArchX86_64)
 	searchDmesg(t, sevSnpMsgList)
 }
 
 func TestTDXEnabled(t *testing.T) {
+	requireArchitecture(t, utils.ArchX86_64)
 	searchDmesg(t, tdxMsgList)
 }
 
-func TestLiveMigrate(t *testing.T) {
-	marker := "/var/lm-test-start"
-	if utils.IsWindows() {
-		marker = `C:\lm-test-start`
-	}
-	if _, err := os.Stat(marker); err != nil && !os.IsNotExist(err) {
-		t.Fatalf("could not determine if live migrate testing has already started: %v", err)
-	} else if err == nil {
-		t.Fatal("unexpected reboot during live migrate test")
-	}
-	err := os.WriteFile(marker, nil, 0777)
-	if err != nil {
-		t.Fatalf("could not mark beginning of live migrate testing: %v", err)
-	}
+func TestCCAEnabled(t *testing.T) {
+	requireArchitecture(t, utils.ArchARM64)
+	searchDmesg(t, ccaMsgList)
+}
+
+// migrateSelf live migrates the instance running the test and waits for the
+// migration to finish.
+func migrateSelf(t *testing.T) {
+	t.Helper()
 	ctx := utils.Context(t)
 	prj, zone, err := utils.GetProjectZone(ctx)
 	if err != nil {
@@ -82,7 +93,7 @@ func TestLiveMigrate(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not make compute api client: %v", err)
 	}
-	t.Cleanup(func() { client.Close() })
+	defer client.Close()
 	req := &computepb.SimulateMaintenanceEventInstanceRequest{
 		Project:  prj,
 		Zone:     zone,
@@ -93,6 +104,23 @@ func TestLiveMigrate(t *testing.T) {
 		t.Fatalf("could not migrate self: %v", err)
 	}
 	op.Wait(ctx) // Errors here come from things completely out of our control, such as the availability of a physical machine to take our VM.
+}
+
+func TestLiveMigrate(t *testing.T) {
+	marker := "/var/lm-test-start"
+	if utils.IsWindows() {
+		marker = `C:\lm-test-start`
+	}
+	if _, err := os.Stat(marker); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("could not determine if live migrate testing has already started: %v", err)
+	} else if err == nil {
+		t.Fatal("unexpected reboot during live migrate test")
+	}
+	err := os.WriteFile(marker, nil, 0777)
+	if err != nil {
+		t.Fatalf("could not mark beginning of live migrate testing: %v", err)
+	}
+	migrateSelf(t)
 	if _, err := os.Stat(marker); err != nil {
 		t.Errorf("could not confirm migrate testing has started ok: %v", err)
 	}
@@ -16,6 +16,8 @@
 package cvm
 
 import (
+	"fmt"
+
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
 	daisy "github.com/GoogleCloudPlatform/compute-daisy"
@@ -26,6 +28,14 @@ import (
 // Name is the name of the test package. It must match the directory name.
 var Name = "cvm"
 
+const (
+	// lmPeerIP is the address of the VM which echoes the TCP stream sent during
+	// live migration.
+	lmPeerIP = "192.168.0.3"
+	// lmPeerPort is the port the TCP stream peer listens on.
+	lmPeerPort = 8910
+)
+
 // TestSetup sets up test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
 	for _, feature := range t.Image.GuestOsFeatures {
@@ -39,7 +49,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 				EnableConfidentialCompute: true,
 			}
 			if utils.HasFeature(t.Image, "SEV_LIVE_MIGRATABLE_V2") {
-				sevtests += "|TestLiveMigrate"
+				sevtests += "|TestLiveMigrate|TestLiveMigrateTCPContinuity|TestAttestDuringMigration"
 				vm.Scopes = append(vm.Scopes, "https://www.googleapis.com/auth/cloud-platform")
 				vm.Scheduling = &computeBeta.Scheduling{OnHostMaintenance: "MIGRATE"}
 			} else {
@@ -52,7 +62,17 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 			if err != nil {
 				return err
 			}
+			if utils.HasFeature(t.Image, "SEV_LIVE_MIGRATABLE_V2") {
+				if err := addLiveMigratePeer(t, tvm); err != nil {
+					return err
+				}
+			}
 			tvm.RunTests(sevtests)
+			if utils.HasFeature(t.Image, "UEFI_COMPATIBLE") {
+				if err := addConfidentialShieldedVM(t); err != nil {
+					return err
+				}
+			}
 		case "SEV_SNP_CAPABLE":
 			vm := &daisy.InstanceBeta{}
 			vm.Name = "sevsnp"
@@ -91,7 +111,86 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 				return err
 			}
 			tvm.RunTests("TestTDXEnabled")
+		case "CCA_CAPABLE":
+			if t.Image.Architecture != "ARM64" {
+				break
+			}
+			vm := &daisy.InstanceBeta{}
+			vm.Name = "cca"
+			vm.Zone = "us-central1-a" // CCA not available in all regions
+			vm.ConfidentialInstanceConfig = &computeBeta.ConfidentialInstanceConfig{
+				ConfidentialInstanceType:  "CCA",
+				EnableConfidentialCompute: true,
+			}
+			vm.Scheduling = &computeBeta.Scheduling{OnHostMaintenance: "TERMINATE"}
+			vm.MachineType = "c4a-standard-4"
+			disks := []*compute.Disk{
+				{Name: vm.Name, Type: imagetest.HyperdiskBalanced, Zone: "us-central1-a"},
+			}
+			tvm, err := t.CreateTestVMFromInstanceBeta(vm, disks)
+			if err != nil {
+				return err
+			}
+			tvm.RunTests("TestCCAEnabled")
 		}
 	}
 	return nil
 }
+
+// addConfidentialShieldedVM creates a SEV VM which also has every shielded
+// VM feature enabled.
+func addConfidentialShieldedVM(t *imagetest.TestWorkflow) error {
+	vm := &daisy.InstanceBeta{}
+	vm.Name = "sevshielded"
+	vm.ConfidentialInstanceConfig = &computeBeta.ConfidentialInstanceConfig{
+		ConfidentialInstanceType:  "SEV",
+		EnableConfidentialCompute: true,
+	}
+	vm.ShieldedInstanceConfig = &computeBeta.ShieldedInstanceConfig{
+		EnableSecureBoot:          true,
+		EnableVtpm:                true,
+		EnableIntegrityMonitoring: true,
+	}
+	vm.Scopes = append(vm.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+	vm.Scheduling = &computeBeta.Scheduling{OnHostMaintenance: "TERMINATE"}
+	vm.MachineType = "n2d-standard-2"
+	vm.MinCpuPlatform = "AMD Milan"
+	tvm, err := t.CreateTestVMFromInstanceBeta(vm, []*compute.Disk{{Name: vm.Name, Type: imagetest.PdBalanced}})
+	if err != nil {
+		return err
+	}
+	tvm.RunTests("TestConfidentialPlusShielded")
+	return nil
+}
+
+// addLiveMigratePeer creates a VM which echoes a TCP stream back to vm while
+// it is live migrated, and connects both VMs to a private network.
+func addLiveMigratePeer(t *imagetest.TestWorkflow, vm *imagetest.TestVM) error {
+	network, err := t.CreateNetwork("lm-network", false)
+	if err != nil {
+		return err
+	}
+	subnetwork, err := network.CreateSubnetwork("lm-subnetwork", "192.168.0.0/24")
+	if err != nil {
+		return err
+	}
+	if err := network.CreateFirewallRule("allow-tcp-lm", "tcp", nil, []string{"192.168.0.0/24"}); err != nil {
+		return err
+	}
+	peer, err := t.CreateTestVM("lmpeer")
+	if err != nil {
+		return err
+	}
+	if err := peer.AddCustomNetwork(network, subnetwork); err != nil {
+		return err
+	}
+	if err := peer.SetPrivateIP(network, lmPeerIP); err != nil {
+		return err
+	}
+	peer.RunTests("TestTCPStreamPeer")
+	if err := vm.AddCustomNetwork(network, subnetwork); err != nil {
+		return err
+	}
+	vm.AddMetadata("lm-peer", fmt.Sprintf("%s:%d", lmPeerIP, lmPeerPort))
+	return nil
+}
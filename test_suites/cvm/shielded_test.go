@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cvm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// bootMeasurements is the TPM event log of the measured boot.
+const bootMeasurements = "/sys/kernel/security/tpm0/binary_bios_measurements"
+
+// TestConfidentialPlusShielded validates that confidential computing and
+// shielded VM features work together on an instance with both enabled.
+func TestConfidentialPlusShielded(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	project, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: project, Zone: zone, Instance: name})
+	if err != nil {
+		t.Fatalf("could not get instance %s: %v", name, err)
+	}
+	shielded := inst.GetShieldedInstanceConfig()
+	if !inst.GetConfidentialInstanceConfig().GetEnableConfidentialCompute() || !shielded.GetEnableVtpm() {
+		t.Skip("instance does not have both confidential computing and a vTPM enabled")
+	}
+
+	t.Run("memory encryption", func(t *testing.T) {
+		searchDmesg(t, append(append(append([]string{}, sevMsgList...), sevSnpMsgList...), tdxMsgList...))
+	})
+
+	t.Run("attestation", func(t *testing.T) {
+		for _, cmd := range []string{"tpm2_createek", "tpm2_createak", "tpm2_quote", "tpm2_checkquote"} {
+			if !utils.CheckLinuxCmdExists(cmd) {
+				t.Skipf("%s is not installed", cmd)
+			}
+		}
+		if _, err := os.Stat("/dev/tpmrm0"); err != nil {
+			t.Fatalf("no TPM resource manager available: %v", err)
+		}
+		dir := t.TempDir()
+		if err := tpm2("tpm2_createek", "-c", filepath.Join(dir, "ek.ctx"), "-G", "rsa", "-u", filepath.Join(dir, "ek.pub")); err != nil {
+			t.Fatalf("could not create endorsement key: %v", err)
+		}
+		if err := tpm2("tpm2_createak", "-C", filepath.Join(dir, "ek.ctx"), "-c", filepath.Join(dir, "ak.ctx"), "-G", "rsa", "-g", "sha256", "-s", "rsassa", "-u", filepath.Join(dir, "ak.pub"), "-f", "pem", "-n", filepath.Join(dir, "ak.name")); err != nil {
+			t.Fatalf("could not create attestation key: %v", err)
+		}
+		if err := quoteAndVerify(dir, 0); err != nil {
+			t.Errorf("quote did not verify: %v", err)
+		}
+	})
+
+	t.Run("measured boot", func(t *testing.T) {
+		// securityfs reports a size of 0, so read it to check it has events.
+		log, err := os.ReadFile(bootMeasurements)
+		if err != nil {
+			t.Fatalf("could not read measured boot event log: %v", err)
+		}
+		if len(log) == 0 {
+			t.Errorf("measured boot event log %s is empty", bootMeasurements)
+		}
+	})
+
+	t.Run("secure boot", func(t *testing.T) {
+		if !shielded.GetEnableSecureBoot() {
+			t.Skip("secure boot is not enabled on this instance")
+		}
+		if !utils.CheckLinuxCmdExists("mokutil") {
+			t.Skip("mokutil is not installed")
+		}
+		out, err := exec.Command("mokutil", "--sb-state").CombinedOutput()
+		if err != nil {
+			t.Fatalf("could not get secure boot state: %v %s", err, out)
+		}
+		if !strings.Contains(string(out), "SecureBoot enabled") {
+			t.Errorf("secure boot is enabled on the instance but the guest reports %q", strings.TrimSpace(string(out)))
+		}
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cvm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// streamChunkSize is the size of each write to the TCP stream.
+	streamChunkSize = 4096
+	// streamAfterMigration is how long the stream keeps running after the
+	// migration finishes.
+	streamAfterMigration = 10 * time.Second
+	// peerTimeout is how long the peer waits for the stream to connect.
+	peerTimeout = 20 * time.Minute
+)
+
+// countingHash tracks the length and checksum of a stream.
+type countingHash struct {
+	hash.Hash
+	n int64
+}
+
+func (c *countingHash) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return c.Hash.Write(p)
+}
+
+func newCountingHash() *countingHash {
+	return &countingHash{Hash: sha256.New()}
+}
+
+// TestTCPStreamPeer echoes back a single TCP stream for
+// TestLiveMigrateTCPContinuity.
+func TestTCPStreamPeer(t *testing.T) {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", lmPeerPort))
+	if err != nil {
+		t.Fatalf("could not listen on port %d: %v", lmPeerPort, err)
+	}
+	defer l.Close()
+	if err := l.(*net.TCPListener).SetDeadline(time.Now().Add(peerTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		t.Skipf("no stream connected within %v: %v", peerTimeout, err)
+	}
+	defer conn.Close()
+	n, err := io.Copy(conn, conn)
+	if err != nil {
+		t.Fatalf("stream failed after echoing %d bytes: %v", n, err)
+	}
+	t.Logf("echoed %d bytes", n)
+}
+
+// TestLiveMigrateTCPContinuity validates that a TCP stream survives a live
+// migration without losing or corrupting data.
+func TestLiveMigrateTCPContinuity(t *testing.T) {
+	ctx := utils.Context(t)
+	peer, err := utils.GetMetadata(ctx, "instance", "attributes", "lm-peer")
+	if err != nil {
+		t.Skipf("no stream peer is available: %v", err)
+	}
+	var conn *net.TCPConn
+	for start := time.Now(); time.Since(start) < 5*time.Minute; time.Sleep(5 * time.Second) {
+		var c net.Conn
+		c, err = net.DialTimeout("tcp", peer, 10*time.Second)
+		if err == nil {
+			conn = c.(*net.TCPConn)
+			break
+		}
+	}
+	if conn == nil {
+		t.Fatalf("could not connect to stream peer %s: %v", peer, err)
+	}
+	defer conn.Close()
+
+	sent, received := newCountingHash(), newCountingHash()
+	stop := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		defer conn.CloseWrite()
+		chunk := make([]byte, streamChunkSize)
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for {
+			select {
+			case <-stop:
+				writeErr <- nil
+				return
+			default:
+			}
+			rng.Read(chunk)
+			if _, err := conn.Write(chunk); err != nil {
+				writeErr <- err
+				return
+			}
+			sent.Write(chunk)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(received, conn)
+		readErr <- err
+	}()
+
+	migrateSelf(t)
+	time.Sleep(streamAfterMigration)
+	close(stop)
+	if err := <-writeErr; err != nil {
+		t.Fatalf("stream broke while writing: %v", err)
+	}
+	if err := <-readErr; err != nil {
+		t.Fatalf("stream broke while reading: %v", err)
+	}
+	t.Logf("streamed %d bytes across live migration", sent.n)
+	if received.n != sent.n {
+		t.Errorf("received %d bytes, sent %d bytes", received.n, sent.n)
+	}
+	if !bytes.Equal(received.Sum(nil), sent.Sum(nil)) {
+		t.Errorf("received stream checksum %x does not match sent checksum %x", received.Sum(nil), sent.Sum(nil))
+	}
+}
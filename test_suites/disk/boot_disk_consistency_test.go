@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// guestBootDisk is the boot disk as seen by the guest.
+type guestBootDisk struct {
+	// deviceName is empty if the guest can't determine it.
+	deviceName string
+	sizeBytes  int64
+	// bus is NVME or SCSI, matching the compute API disk interface.
+	bus string
+}
+
+// linuxBootDisk finds the boot disk through the google-<device name>
+// symlink the compute API reports for it.
+func linuxBootDisk(t *testing.T, deviceName string) guestBootDisk {
+	t.Helper()
+	dev, err := filepath.EvalSymlinks("/dev/disk/by-id/google-" + deviceName)
+	if err != nil {
+		t.Fatalf("boot disk %s has no google-%s symlink: %v", deviceName, deviceName, err)
+	}
+	name := filepath.Base(dev)
+	sectors, err := os.ReadFile(filepath.Join("/sys/block", name, "size"))
+	if err != nil {
+		t.Fatalf("could not get size of %s: %v", dev, err)
+	}
+	// sysfs reports sizes in 512 byte sectors regardless of the disk's
+	// sector size.
+	n, err := strconv.ParseInt(strings.TrimSpace(string(sectors)), 10, 64)
+	if err != nil {
+		t.Fatalf("could not parse size of %s: %v", dev, err)
+	}
+	bus := "SCSI"
+	if strings.HasPrefix(name, "nvme") {
+		bus = "NVME"
+	}
+	out, err := exec.Command("findmnt", "-no", "SOURCE", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem source: %v", err)
+	}
+	root := strings.TrimSpace(string(out))
+	out, err = exec.Command("lsblk", "-nsr", "-o", "NAME,TYPE", root).Output()
+	if err != nil {
+		t.Fatalf("could not find disk of root filesystem %s: %v", root, err)
+	}
+	if !strings.Contains(string(out), name+" disk") {
+		t.Errorf("root filesystem %s is not on the boot disk %s", root, dev)
+	}
+	return guestBootDisk{deviceName: deviceName, sizeBytes: n * 512, bus: bus}
+}
+
+// windowsBootDisk finds the disk windows booted from.
+func windowsBootDisk(t *testing.T) guestBootDisk {
+	t.Helper()
+	out, err := utils.RunPowershellCmd(`$d = Get-Disk | Where-Object IsBoot; "$($d.Size) $($d.BusType) $($d.SerialNumber)"`)
+	if err != nil {
+		t.Fatalf("could not get boot disk: %v %s", err, out.Stderr)
+	}
+	fields := strings.Fields(out.Stdout)
+	if len(fields) < 2 {
+		t.Fatalf("unexpected boot disk description %q", out.Stdout)
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		t.Fatalf("could not parse boot disk size %q: %v", fields[0], err)
+	}
+	disk := guestBootDisk{sizeBytes: size, bus: strings.ToUpper(fields[1])}
+	// Persistent disks attached over SCSI use the device name as the serial
+	// number. NVMe disks don't, so their device name isn't compared.
+	if disk.bus == "SCSI" && len(fields) > 2 {
+		disk.deviceName = fields[2]
+	}
+	return disk
+}
+
+// TestBootDiskMetadataConsistency validates that the boot disk seen by the
+// guest matches the boot disk the compute API reports attached.
+func TestBootDiskMetadataConsistency(t *testing.T) {
+	ctx := utils.Context(t)
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance: %v", err)
+	}
+	instancesClient, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer instancesClient.Close()
+	inst, err := instancesClient.Get(ctx, &computepb.GetInstanceRequest{Project: prj, Zone: zone, Instance: name})
+	if err != nil {
+		t.Fatalf("could not get instance %s: %v", name, err)
+	}
+	var attached *computepb.AttachedDisk
+	for _, d := range inst.GetDisks() {
+		if d.GetBoot() {
+			attached = d
+		}
+	}
+	if attached == nil {
+		t.Fatalf("compute API reports no boot disk attached to %s", name)
+	}
+	disksClient, err := compute.NewDisksRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make disks client: %v", err)
+	}
+	defer disksClient.Close()
+	disk, err := disksClient.Get(ctx, &computepb.GetDiskRequest{Project: prj, Zone: zone, Disk: path.Base(attached.GetSource())})
+	if err != nil {
+		t.Fatalf("could not get boot disk %s: %v", attached.GetSource(), err)
+	}
+	t.Logf("compute API boot disk: device name %s, %d GB, type %s, interface %s", attached.GetDeviceName(), disk.GetSizeGb(), path.Base(disk.GetType()), attached.GetInterface())
+
+	// The metadata server is the guest's view of the control plane.
+	if mdName, err := utils.GetMetadata(ctx, "instance", "disks", "0", "device-name"); err != nil {
+		t.Errorf("could not get boot disk device name from metadata: %v", err)
+	} else if mdName != attached.GetDeviceName() {
+		t.Errorf("metadata reports boot disk device name %s, compute API reports %s", mdName, attached.GetDeviceName())
+	}
+	if mdInterface, err := utils.GetMetadata(ctx, "instance", "disks", "0", "interface"); err != nil {
+		t.Errorf("could not get boot disk interface from metadata: %v", err)
+	} else if mdInterface != attached.GetInterface() {
+		t.Errorf("metadata reports boot disk interface %s, compute API reports %s", mdInterface, attached.GetInterface())
+	}
+
+	var guest guestBootDisk
+	if utils.IsWindows() {
+		guest = windowsBootDisk(t)
+	} else {
+		guest = linuxBootDisk(t, attached.GetDeviceName())
+	}
+	t.Logf("guest boot disk: device name %q, %d bytes, bus %s", guest.deviceName, guest.sizeBytes, guest.bus)
+	if guest.deviceName != "" && guest.deviceName != attached.GetDeviceName() {
+		t.Errorf("guest boot disk is %s, compute API reports %s", guest.deviceName, attached.GetDeviceName())
+	}
+	if want := disk.GetSizeGb() << 30; guest.sizeBytes != want {
+		t.Errorf("guest boot disk is %d bytes, compute API reports %d GB (%d bytes)", guest.sizeBytes, disk.GetSizeGb(), want)
+	}
+	if guest.bus != attached.GetInterface() {
+		t.Errorf("guest boot disk is on a %s bus, compute API reports interface %s", guest.bus, attached.GetInterface())
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// predictableInterfaceRe matches the interface names used by supported
+// images, either kernel names or systemd predictable names.
+var predictableInterfaceRe = regexp.MustCompile(`^(eth\d+|en[a-z0-9]+)$`)
+
+// TestDeviceNaming validates that every attached disk is reachable through
+// its stable google-<device name> symlink, and that network interfaces have
+// predictable names.
+func TestDeviceNaming(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	disks, err := utils.GetMetadata(ctx, "instance", "disks")
+	if err != nil {
+		t.Fatalf("could not get disks from metadata: %v", err)
+	}
+	resolved := make(map[string]string)
+	for _, index := range strings.Fields(disks) {
+		name, err := utils.GetMetadata(ctx, "instance", "disks", strings.TrimSuffix(index, "/"), "device-name")
+		if err != nil {
+			t.Fatalf("could not get name of disk %s from metadata: %v", index, err)
+		}
+		link := "/dev/disk/by-id/google-" + name
+		dev, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			t.Errorf("disk %s has no usable symlink %s: %v", name, link, err)
+			continue
+		}
+		if info, err := os.Stat(dev); err != nil || info.Mode()&os.ModeDevice == 0 {
+			t.Errorf("symlink %s resolves to %s, which is not a block device", link, dev)
+			continue
+		}
+		for other, otherDev := range resolved {
+			if otherDev == dev {
+				t.Errorf("disks %s and %s both resolve to %s", name, other, dev)
+			}
+		}
+		resolved[name] = dev
+	}
+	t.Logf("disk symlinks: %v", resolved)
+
+	nics, err := utils.GetMetadata(ctx, "instance", "network-interfaces")
+	if err != nil {
+		t.Fatalf("could not get network interfaces from metadata: %v", err)
+	}
+	for _, index := range strings.Fields(nics) {
+		i, err := strconv.Atoi(strings.TrimSuffix(index, "/"))
+		if err != nil {
+			t.Fatalf("unexpected network interface index %q in metadata", index)
+		}
+		iface, err := utils.GetInterface(ctx, i)
+		if err != nil {
+			t.Errorf("could not find network interface %d: %v", i, err)
+			continue
+		}
+		t.Logf("network interface %d is %s", i, iface.Name)
+		if !predictableInterfaceRe.MatchString(iface.Name) {
+			t.Errorf("network interface %d has name %s, want a name matching %s", i, iface.Name, predictableInterfaceRe)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// nvmeSmartLog is the subset of `nvme smart-log -o json` output which is
+// checked.
+type nvmeSmartLog struct {
+	CriticalWarning *int `json:"critical_warning"`
+	AvailSpare      *int `json:"avail_spare"`
+	PercentUsed     *int `json:"percent_used"`
+}
+
+// smartctlReport is the subset of `smartctl -j` output which is checked.
+type smartctlReport struct {
+	SmartSupport struct {
+		Available bool `json:"available"`
+		Enabled   bool `json:"enabled"`
+	} `json:"smart_support"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+}
+
+// hasMountpoint reports whether dev or any of its children is mounted at
+// mountpoint.
+func hasMountpoint(dev utils.BlockDevice, mountpoint string) bool {
+	if dev.Mountpoint == mountpoint {
+		return true
+	}
+	for _, child := range dev.Children {
+		if hasMountpoint(child, mountpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// bootDisk returns the name of the disk holding the root filesystem.
+func bootDisk() (string, error) {
+	out, err := exec.Command("lsblk", "--json", "-o", "NAME,TYPE,MOUNTPOINT").Output()
+	if err != nil {
+		return "", fmt.Errorf("lsblk command failed: %v", err)
+	}
+	var list utils.BlockDeviceList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return "", fmt.Errorf("failed to unmarshal lsblk output %s: %v", out, err)
+	}
+	for _, dev := range list.BlockDevices {
+		if dev.Type == "disk" && hasMountpoint(dev, "/") {
+			return dev.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no disk holds the root filesystem")
+}
+
+// TestDiskHealth validates that the boot disk reports itself healthy through
+// NVMe or SMART health data.
+func TestDiskHealth(t *testing.T) {
+	if utils.IsWindows() {
+		testDiskHealthWindows(t)
+		return
+	}
+	disk, err := bootDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := "/dev/" + disk
+	if strings.HasPrefix(disk, "nvme") && utils.CheckLinuxCmdExists("nvme") {
+		testNVMeHealth(t, dev)
+		return
+	}
+	if !utils.CheckLinuxCmdExists("smartctl") {
+		t.Skipf("no health reporting tool is installed for %s", dev)
+	}
+	// smartctl exits non-zero for health problems as well as failures, so its
+	// output is parsed regardless of the exit code.
+	out, err := exec.Command("smartctl", "-a", "-j", dev).Output()
+	var report smartctlReport
+	if jsonErr := json.Unmarshal(out, &report); jsonErr != nil {
+		t.Fatalf("could not parse smartctl output for %s: %v, exit error %v", dev, jsonErr, err)
+	}
+	t.Logf("%s SMART support: available %t, enabled %t", dev, report.SmartSupport.Available, report.SmartSupport.Enabled)
+	if !report.SmartSupport.Available || report.SmartStatus == nil {
+		t.Skipf("%s does not expose SMART health data", dev)
+	}
+	if !report.SmartStatus.Passed {
+		t.Errorf("%s failed its SMART overall health assessment", dev)
+	}
+}
+
+func testNVMeHealth(t *testing.T, dev string) {
+	out, err := exec.Command("nvme", "smart-log", dev, "-o", "json").CombinedOutput()
+	if err != nil {
+		t.Skipf("%s does not expose an NVMe SMART log: %v %s", dev, err, out)
+	}
+	var log nvmeSmartLog
+	if err := json.Unmarshal(out, &log); err != nil {
+		t.Fatalf("could not parse NVMe SMART log for %s: %v", dev, err)
+	}
+	t.Logf("%s NVMe SMART log: %s", dev, out)
+	if log.CriticalWarning == nil {
+		t.Fatalf("%s NVMe SMART log has no critical_warning", dev)
+	}
+	if *log.CriticalWarning != 0 {
+		t.Errorf("%s reports critical warning %#x", dev, *log.CriticalWarning)
+	}
+	if log.AvailSpare == nil || log.PercentUsed == nil {
+		t.Errorf("%s NVMe SMART log is missing avail_spare or percent_used", dev)
+	}
+}
+
+func testDiskHealthWindows(t *testing.T) {
+	output, err := utils.RunPowershellCmd(`(Get-Partition -DriveLetter C | Get-Disk).HealthStatus`)
+	if err != nil {
+		t.Fatalf("could not get health of the boot disk: %v %s", err, output.Stderr)
+	}
+	status := strings.TrimSpace(output.Stdout)
+	t.Logf("boot disk health status: %s", status)
+	if status == "" {
+		t.Skip("boot disk does not report a health status")
+	}
+	if status != "Healthy" {
+		t.Errorf("boot disk health status is %s, want Healthy", status)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// TestFirstBootExpand validates that the root filesystem was expanded to fill
+// a boot disk larger than the image on first boot.
+func TestFirstBootExpand(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	if strings.Contains(image, "rhel-7-4-sap") {
+		t.Skip("disk expansion not supported on RHEL 7.4")
+	}
+	disk, err := bootDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("lsblk", "-b", "-o", "NAME,TYPE,SIZE,MOUNTPOINT", "/dev/"+disk).CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not list partitions of %s: %v %s", disk, err, out)
+	}
+	t.Logf("boot disk layout:\n%s", out)
+	fsSize, err := getDiskSize(image)
+	if err != nil {
+		t.Fatalf("could not get root filesystem size: %v", err)
+	}
+	t.Logf("root filesystem is %.1f GB on a %d GB disk", float64(fsSize)/gb, firstBootDiskSize)
+	if err := verifyDiskSize(firstBootDiskSize, image); err != nil {
+		t.Errorf("root filesystem was not expanded on first boot: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	luksMarkerFile     = "luks-marker"
+	luksMarkerContents = "luks marker written before reboot"
+	expectedLUKSCipher = "aes-xts-plain64"
+	luksDataKeyFile    = "/etc/luksdata.key"
+	luksDataMountPath  = "/mnt/disks/luksdata"
+)
+
+// TestLUKS validates that encrypted volumes are unlocked at boot with the
+// expected cipher, and that data on them survives a reboot.
+func TestLUKS(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("cryptsetup") {
+		t.Skip("cryptsetup is not installed, no encrypted volumes to test")
+	}
+	devices, err := findCryptDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		if _, err := os.Stat("/dev/disk/by-id/google-" + luksDataDiskName); err != nil {
+			t.Skip("no encrypted volumes found")
+		}
+		if _, err := os.Stat(luksDataKeyFile); err == nil {
+			t.Fatalf("encrypted data disk %s was not unlocked at boot", luksDataDiskName)
+		}
+		// first boot
+		if err := setupLUKSDataDisk(); err != nil {
+			t.Fatal(err)
+		}
+		if devices, err = findCryptDevices(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dev := range devices {
+		out, err := exec.Command("cryptsetup", "status", dev.Name).CombinedOutput()
+		if err != nil {
+			t.Errorf("cryptsetup status %s failed: %v %s", dev.Name, err, out)
+			continue
+		}
+		status := parseCryptsetupStatus(string(out))
+		t.Logf("encrypted volume %s: type %s, cipher %s, keysize %s, backing device %s", dev.Name, status["type"], status["cipher"], status["keysize"], status["device"])
+		if !strings.Contains(strings.SplitN(string(out), "\n", 2)[0], "is active") {
+			t.Errorf("encrypted volume %s is not active: %s", dev.Name, out)
+		}
+		if !strings.HasPrefix(status["type"], "LUKS") {
+			t.Errorf("encrypted volume %s is type %q, want LUKS", dev.Name, status["type"])
+		}
+		if status["cipher"] != expectedLUKSCipher {
+			t.Errorf("encrypted volume %s uses cipher %q, want %q", dev.Name, status["cipher"], expectedLUKSCipher)
+		}
+		if dump, err := exec.Command("cryptsetup", "luksDump", status["device"]).CombinedOutput(); err == nil {
+			t.Logf("luksDump of %s:\n%s", status["device"], dump)
+		}
+		// The filesystem may be on the crypt device itself or, as with an
+		// encrypted root, on an LVM volume inside it.
+		mountpoint := findMountpoint(dev)
+		if mountpoint == "" {
+			t.Logf("encrypted volume %s has no mounted filesystem, not checking data across reboot", dev.Name)
+			continue
+		}
+		if err := checkLUKSMarker(filepath.Join(mountpoint, luksMarkerFile)); err != nil {
+			t.Errorf("encrypted volume %s: %v", dev.Name, err)
+		}
+	}
+}
+
+// setupLUKSDataDisk encrypts the data disk with a key file and configures it
+// to be unlocked and mounted at boot.
+func setupLUKSDataDisk() error {
+	disk := "/dev/disk/by-id/google-" + luksDataDiskName
+	if err := os.WriteFile(luksDataKeyFile, []byte(strings.Repeat("cit-luks-key", 4)), 0400); err != nil {
+		return fmt.Errorf("could not write key file: %v", err)
+	}
+	cmds := [][]string{
+		{"cryptsetup", "luksFormat", "--batch-mode", "--type", "luks2", "--cipher", expectedLUKSCipher, "--key-file", luksDataKeyFile, disk},
+		{"cryptsetup", "open", "--key-file", luksDataKeyFile, disk, luksDataDiskName},
+		{"mkfs.ext4", "-q", "/dev/mapper/" + luksDataDiskName},
+		{"mkdir", "-p", luksDataMountPath},
+		{"mount", "/dev/mapper/" + luksDataDiskName, luksDataMountPath},
+	}
+	for _, cmd := range cmds {
+		if out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %v %s", strings.Join(cmd, " "), err, out)
+		}
+	}
+	crypttab := fmt.Sprintf("%s %s %s luks\n", luksDataDiskName, disk, luksDataKeyFile)
+	fstab := fmt.Sprintf("/dev/mapper/%s %s ext4 defaults,nofail 0 2\n", luksDataDiskName, luksDataMountPath)
+	for file, line := range map[string]string{"/etc/crypttab": crypttab, "/etc/fstab": fstab} {
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open %s: %v", file, err)
+		}
+		_, err = f.WriteString(line)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not write %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// checkLUKSMarker writes the marker on the first boot and validates its
+// contents on the second boot.
+func checkLUKSMarker(marker string) error {
+	contents, err := os.ReadFile(marker)
+	if os.IsNotExist(err) {
+		// first boot
+		if err := os.WriteFile(marker, []byte(luksMarkerContents), 0644); err != nil {
+			return fmt.Errorf("failed to write marker file %s: %v", marker, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read marker file %s: %v", marker, err)
+	}
+	// second boot
+	if string(contents) != luksMarkerContents {
+		return fmt.Errorf("marker file %s did not survive reboot, got contents %q want %q", marker, contents, luksMarkerContents)
+	}
+	return nil
+}
+
+// findMountpoint returns the first mountpoint of the device or its children.
+func findMountpoint(dev utils.BlockDevice) string {
+	if dev.Mountpoint != "" {
+		return dev.Mountpoint
+	}
+	for _, child := range dev.Children {
+		if m := findMountpoint(child); m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+func findCryptDevices() ([]utils.BlockDevice, error) {
+	out, err := exec.Command("lsblk", "--json", "-o", "NAME,TYPE,MOUNTPOINT").Output()
+	if err != nil {
+		return nil, fmt.Errorf("lsblk command failed: %v", err)
+	}
+	var list utils.BlockDeviceList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lsblk output %s: %v", out, err)
+	}
+	var crypts []utils.BlockDevice
+	var walk func([]utils.BlockDevice)
+	walk = func(devs []utils.BlockDevice) {
+		for _, d := range devs {
+			if d.Type == "crypt" {
+				crypts = append(crypts, d)
+			}
+			walk(d.Children)
+		}
+	}
+	walk(list.BlockDevices)
+	return crypts, nil
+}
+
+// parseCryptsetupStatus parses the "key: value" lines of cryptsetup status.
+func parseCryptsetupStatus(out string) map[string]string {
+	status := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		k, v, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		status[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return status
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+type volumeGroup struct {
+	name      string
+	freeBytes int64
+}
+
+type logicalVolume struct {
+	name string
+	vg   string
+	path string
+}
+
+type physicalVolume struct {
+	name      string
+	vg        string
+	sizeBytes int64
+	devBytes  int64
+}
+
+// lvmLayout is the LVM layout an image is expected to ship with.
+type lvmLayout struct {
+	rootVG string
+	// All logical volumes expected in rootVG, including the root LV.
+	lvs    []string
+	rootLV string
+}
+
+// expectedLVMLayouts maps image name substrings to their LVM layout contract.
+// The first matching entry is used, so more specific names must come first.
+var expectedLVMLayouts = []struct {
+	image  string
+	layout lvmLayout
+}{
+	{"rhel-9-0-sap", lvmLayout{rootVG: "rootvg", rootLV: "rootlv", lvs: []string{"rootlv", "usrlv", "varlv", "tmplv", "homelv"}}},
+	{"rhel-8-10-sap", lvmLayout{rootVG: "rootvg", rootLV: "rootlv", lvs: []string{"rootlv", "usrlv", "varlv", "tmplv", "homelv"}}},
+	{"rhel-8-8-sap", lvmLayout{rootVG: "rootvg", rootLV: "rootlv", lvs: []string{"rootlv", "usrlv", "varlv", "tmplv", "homelv"}}},
+}
+
+// TestLVM validates the LVM layout of images which place the root filesystem
+// on a logical volume.
+func TestLVM(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("lvs") {
+		t.Skip("lvm2 tools are not installed, image does not use LVM")
+	}
+	rootSource, err := exec.Command("findmnt", "-n", "-o", "SOURCE", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem source: %v", err)
+	}
+	rootLV, err := exec.Command("lvs", "--noheadings", "-o", "lv_name,vg_name", strings.TrimSpace(string(rootSource))).Output()
+	if err != nil {
+		t.Skipf("root filesystem %s is not a logical volume, image does not use LVM", strings.TrimSpace(string(rootSource)))
+	}
+	fields := strings.Fields(string(rootLV))
+	if len(fields) != 2 {
+		t.Fatalf("could not parse lvs output for root filesystem: %q", rootLV)
+	}
+	rootLVName, rootVGName := fields[0], fields[1]
+	t.Logf("root filesystem is on logical volume %s in volume group %s", rootLVName, rootVGName)
+
+	vgs, err := listVolumeGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lvs, err := listLogicalVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvs, err := listPhysicalVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout := formatLVMLayout(vgs, lvs, pvs)
+	t.Logf("found lvm layout:\n%s", layout)
+
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata: %v", err)
+	}
+	var expected *lvmLayout
+	for _, e := range expectedLVMLayouts {
+		if strings.Contains(image, e.image) {
+			expected = &e.layout
+			break
+		}
+	}
+	if expected == nil {
+		t.Fatalf("image %s has its root filesystem on LVM but no expected layout is defined, actual layout:\n%s", image, layout)
+	}
+	if rootVGName != expected.rootVG || rootLVName != expected.rootLV {
+		t.Errorf("root filesystem is on %s/%s, want %s/%s, actual layout:\n%s", rootVGName, rootLVName, expected.rootVG, expected.rootLV, layout)
+	}
+	var rootVG *volumeGroup
+	for i := range vgs {
+		if vgs[i].name == expected.rootVG {
+			rootVG = &vgs[i]
+		}
+	}
+	if rootVG == nil {
+		t.Fatalf("volume group %s not found, actual layout:\n%s", expected.rootVG, layout)
+	}
+	for _, name := range expected.lvs {
+		var found bool
+		for _, lv := range lvs {
+			if lv.vg == expected.rootVG && lv.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("logical volume %s/%s not found, actual layout:\n%s", expected.rootVG, name, layout)
+		}
+	}
+
+	// The test VM has had its disk resized before this runs. Depending on
+	// whether the guest grew the PV at boot, the space for expansion is either
+	// free extents in the VG or unallocated space between the end of the PV and
+	// the end of its partition, so count both.
+	expandable := rootVG.freeBytes
+	for _, pv := range pvs {
+		if pv.vg == rootVG.name {
+			expandable += pv.devBytes - pv.sizeBytes
+		}
+	}
+	if expandable <= 0 {
+		t.Errorf("root volume group %s has no space for expansion, actual layout:\n%s", rootVG.name, layout)
+	}
+}
+
+func listVolumeGroups() ([]volumeGroup, error) {
+	out, err := exec.Command("vgs", "--noheadings", "--units", "b", "--nosuffix", "-o", "vg_name,vg_free").Output()
+	if err != nil {
+		return nil, fmt.Errorf("vgs command failed: %v", err)
+	}
+	var vgs []volumeGroup
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		free, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse free space %q of volume group %s: %v", fields[1], fields[0], err)
+		}
+		vgs = append(vgs, volumeGroup{name: fields[0], freeBytes: free})
+	}
+	return vgs, nil
+}
+
+func listLogicalVolumes() ([]logicalVolume, error) {
+	out, err := exec.Command("lvs", "--noheadings", "-o", "lv_name,vg_name,lv_path").Output()
+	if err != nil {
+		return nil, fmt.Errorf("lvs command failed: %v", err)
+	}
+	var lvs []logicalVolume
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		lv := logicalVolume{name: fields[0], vg: fields[1]}
+		if len(fields) > 2 {
+			lv.path = fields[2]
+		}
+		lvs = append(lvs, lv)
+	}
+	return lvs, nil
+}
+
+func listPhysicalVolumes() ([]physicalVolume, error) {
+	out, err := exec.Command("pvs", "--noheadings", "--units", "b", "--nosuffix", "-o", "pv_name,vg_name,pv_size,dev_size").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pvs command failed: %v", err)
+	}
+	var pvs []physicalVolume
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse size %q of physical volume %s: %v", fields[2], fields[0], err)
+		}
+		dev, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse device size %q of physical volume %s: %v", fields[3], fields[0], err)
+		}
+		pvs = append(pvs, physicalVolume{name: fields[0], vg: fields[1], sizeBytes: size, devBytes: dev})
+	}
+	return pvs, nil
+}
+
+func formatLVMLayout(vgs []volumeGroup, lvs []logicalVolume, pvs []physicalVolume) string {
+	var b strings.Builder
+	for _, vg := range vgs {
+		fmt.Fprintf(&b, "vg %s (free %d bytes)\n", vg.name, vg.freeBytes)
+		for _, pv := range pvs {
+			if pv.vg == vg.name {
+				fmt.Fprintf(&b, "  pv %s (size %d bytes, device %d bytes)\n", pv.name, pv.sizeBytes, pv.devBytes)
+			}
+		}
+		for _, lv := range lvs {
+			if lv.vg == vg.name {
+				fmt.Fprintf(&b, "  lv %s %s\n", lv.name, lv.path)
+			}
+		}
+	}
+	return b.String()
+}
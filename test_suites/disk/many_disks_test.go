@@ -0,0 +1,209 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	manyDisksSize = 10
+	// manyDisksTimeout is how long the attached disks have to appear in, or
+	// disappear from, /dev/disk/by-id.
+	manyDisksTimeout = 2 * time.Minute
+)
+
+func manyDiskDeviceName(i int) string {
+	return fmt.Sprintf("many-%d", i)
+}
+
+// waitForDiskLinks waits until the by-id link of every device name is present
+// or absent, and returns the device names which are not in that state.
+func waitForDiskLinks(names []string, present bool) []string {
+	var pending []string
+	for start := time.Now(); ; time.Sleep(2 * time.Second) {
+		pending = nil
+		for _, name := range names {
+			_, err := os.Stat("/dev/disk/by-id/google-" + name)
+			if (err == nil) != present {
+				pending = append(pending, name)
+			}
+		}
+		if len(pending) == 0 || time.Since(start) > manyDisksTimeout {
+			return pending
+		}
+	}
+}
+
+// TestManyDisks validates that the maximum number of data disks for the
+// machine type can be attached, enumerate with their device names, and be
+// detached.
+func TestManyDisks(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "many-disks"); err != nil || enabled != "true" {
+		t.Skip("many-disks is not enabled")
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	inst, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance: %v", err)
+	}
+	machineType, err := utils.GetMetadata(ctx, "instance", "machine-type")
+	if err != nil {
+		t.Fatalf("could not get machine type: %v", err)
+	}
+	instancesClient, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make instances client: %v", err)
+	}
+	t.Cleanup(func() { instancesClient.Close() })
+	disksClient, err := compute.NewDisksRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make disks client: %v", err)
+	}
+	t.Cleanup(func() { disksClient.Close() })
+	machineTypesClient, err := compute.NewMachineTypesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make machine types client: %v", err)
+	}
+	t.Cleanup(func() { machineTypesClient.Close() })
+
+	mt, err := machineTypesClient.Get(ctx, &computepb.GetMachineTypeRequest{Project: prj, Zone: zone, MachineType: path.Base(machineType)})
+	if err != nil {
+		t.Fatalf("could not get machine type %s: %v", machineType, err)
+	}
+	instance, err := instancesClient.Get(ctx, &computepb.GetInstanceRequest{Project: prj, Zone: zone, Instance: inst})
+	if err != nil {
+		t.Fatalf("could not get instance %s: %v", inst, err)
+	}
+	count := int(mt.GetMaximumPersistentDisks()) - len(instance.GetDisks())
+	if count <= 0 {
+		t.Fatalf("machine type %s allows %d disks and %d are already attached", mt.GetName(), mt.GetMaximumPersistentDisks(), len(instance.GetDisks()))
+	}
+	t.Logf("attaching %d disks to reach the %s maximum of %d", count, mt.GetName(), mt.GetMaximumPersistentDisks())
+
+	var wg sync.WaitGroup
+	created := make([]bool, count)
+	errs := make([]error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			op, err := disksClient.Insert(ctx, &computepb.InsertDiskRequest{
+				Project: prj,
+				Zone:    zone,
+				DiskResource: &computepb.Disk{
+					Name:   proto.String(fmt.Sprintf("%s-%d", inst, i)),
+					SizeGb: proto.Int64(manyDisksSize),
+				},
+			})
+			if err == nil {
+				created[i] = true
+				err = op.Wait(ctx)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	t.Cleanup(func() {
+		for i := range created {
+			if !created[i] {
+				continue
+			}
+			name := fmt.Sprintf("%s-%d", inst, i)
+			if _, err := disksClient.Delete(context.Background(), &computepb.DeleteDiskRequest{Project: prj, Zone: zone, Disk: name}); err != nil {
+				t.Logf("unable to delete disk %s: %v", name, err)
+			}
+		}
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("could not create disk %d: %v", i, err)
+		}
+	}
+
+	// Attach operations on one instance are serialized, so there is nothing
+	// to gain from issuing them concurrently.
+	attached := make([]bool, count)
+	t.Cleanup(func() {
+		for i := range attached {
+			if !attached[i] {
+				continue
+			}
+			op, err := instancesClient.DetachDisk(context.Background(), &computepb.DetachDiskInstanceRequest{Project: prj, Zone: zone, Instance: inst, DeviceName: manyDiskDeviceName(i)})
+			if err == nil {
+				err = op.Wait(context.Background())
+			}
+			if err != nil {
+				t.Logf("unable to detach disk %s: %v", manyDiskDeviceName(i), err)
+			}
+		}
+	})
+	var names []string
+	for i := 0; i < count; i++ {
+		op, err := instancesClient.AttachDisk(ctx, &computepb.AttachDiskInstanceRequest{
+			Project:  prj,
+			Zone:     zone,
+			Instance: inst,
+			AttachedDiskResource: &computepb.AttachedDisk{
+				Source:     proto.String(fmt.Sprintf("projects/%s/zones/%s/disks/%s-%d", prj, zone, inst, i)),
+				DeviceName: proto.String(manyDiskDeviceName(i)),
+			},
+		})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		if err != nil {
+			t.Fatalf("could not attach disk %d of %d: %v", i+1, count, err)
+		}
+		attached[i] = true
+		names = append(names, manyDiskDeviceName(i))
+	}
+
+	if missing := waitForDiskLinks(names, true); len(missing) > 0 {
+		t.Errorf("%d of %d attached disks did not enumerate: %v", len(missing), count, missing)
+	} else {
+		t.Logf("all %d attached disks enumerated with their device names", count)
+	}
+
+	for i := 0; i < count; i++ {
+		op, err := instancesClient.DetachDisk(ctx, &computepb.DetachDiskInstanceRequest{Project: prj, Zone: zone, Instance: inst, DeviceName: manyDiskDeviceName(i)})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		if err != nil {
+			t.Fatalf("could not detach disk %s: %v", manyDiskDeviceName(i), err)
+		}
+		attached[i] = false
+	}
+	if remaining := waitForDiskLinks(names, false); len(remaining) > 0 {
+		t.Errorf("%d of %d detached disks are still present: %v", len(remaining), count, remaining)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// nvmeNamespaceRe matches an NVMe namespace block device and captures the
+// controller and namespace numbers.
+var nvmeNamespaceRe = regexp.MustCompile(`^nvme(\d+)n(\d+)$`)
+
+// nvmeNamespace is where an attached disk shows up on the NVMe bus.
+type nvmeNamespace struct {
+	controller int
+	namespace  int
+}
+
+func (n nvmeNamespace) String() string {
+	return fmt.Sprintf("nvme%dn%d", n.controller, n.namespace)
+}
+
+// parseNVMeNamespace returns the controller and namespace of an NVMe
+// namespace device such as /dev/nvme0n2.
+func parseNVMeNamespace(dev string) (nvmeNamespace, error) {
+	m := nvmeNamespaceRe.FindStringSubmatch(filepath.Base(dev))
+	if m == nil {
+		return nvmeNamespace{}, fmt.Errorf("%s is not an NVMe namespace", dev)
+	}
+	controller, _ := strconv.Atoi(m[1])
+	namespace, _ := strconv.Atoi(m[2])
+	return nvmeNamespace{controller: controller, namespace: namespace}, nil
+}
+
+// TestNVMeNamespaces validates that each persistent disk attached over NVMe
+// is the namespace matching its attach index, and that the root filesystem
+// is on the boot disk's namespace.
+func TestNVMeNamespaces(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	bootInterface, err := utils.GetMetadata(ctx, "instance", "disks", "0", "interface")
+	if err != nil {
+		t.Fatalf("could not get boot disk interface from metadata: %v", err)
+	}
+	if bootInterface != "NVME" {
+		t.Skipf("boot disk uses %s, not NVMe", bootInterface)
+	}
+	disks, err := utils.GetMetadata(ctx, "instance", "disks")
+	if err != nil {
+		t.Fatalf("could not get disks from metadata: %v", err)
+	}
+
+	var boot nvmeNamespace
+	mapping := make(map[string]nvmeNamespace)
+	for _, index := range strings.Fields(disks) {
+		index = strings.TrimSuffix(index, "/")
+		diskType, err := utils.GetMetadata(ctx, "instance", "disks", index, "type")
+		if err != nil {
+			t.Fatalf("could not get type of disk %s from metadata: %v", index, err)
+		}
+		// Local SSDs are on their own controllers, outside the persistent
+		// disk namespace ordering.
+		if diskType != "PERSISTENT" {
+			continue
+		}
+		name, err := utils.GetMetadata(ctx, "instance", "disks", index, "device-name")
+		if err != nil {
+			t.Fatalf("could not get name of disk %s from metadata: %v", index, err)
+		}
+		dev, err := filepath.EvalSymlinks("/dev/disk/by-id/google-" + name)
+		if err != nil {
+			t.Errorf("disk %s has no google-%s symlink: %v", name, name, err)
+			continue
+		}
+		ns, err := parseNVMeNamespace(dev)
+		if err != nil {
+			t.Errorf("disk %s: %v", name, err)
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			t.Fatalf("unexpected disk index %q in metadata", index)
+		}
+		if ns.namespace != i+1 {
+			t.Errorf("disk %s with attach index %d is namespace %s, want namespace %d", name, i, ns, i+1)
+		}
+		for other, otherNS := range mapping {
+			if otherNS == ns {
+				t.Errorf("disks %s and %s are both namespace %s", name, other, ns)
+			}
+		}
+		if i == 0 {
+			boot = ns
+		}
+		mapping[name] = ns
+	}
+	t.Logf("namespaces by disk: %v", mapping)
+	if len(mapping) < 2 {
+		t.Errorf("found %d persistent NVMe disks, want a boot disk and at least one data disk", len(mapping))
+	}
+
+	out, err := exec.Command("findmnt", "-no", "SOURCE", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem source: %v", err)
+	}
+	root := strings.TrimSpace(string(out))
+	out, err = exec.Command("lsblk", "-nsr", "-o", "NAME,TYPE", root).Output()
+	if err != nil {
+		t.Fatalf("could not find disk of root filesystem %s: %v", root, err)
+	}
+	var rootDisk string
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[1] == "disk" {
+			rootDisk = fields[0]
+		}
+	}
+	if rootDisk != boot.String() {
+		t.Errorf("root filesystem %s is on %s, want boot disk namespace %s", root, rootDisk, boot)
+	}
+}
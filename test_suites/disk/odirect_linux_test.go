@@ -0,0 +1,45 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// directIOTestFile is where TestODirect writes, on the boot disk.
+const directIOTestFile = "/var/cit-odirect"
+
+// openDirect opens path for reading and writing, bypassing the page cache.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC|syscall.O_DIRECT, 0644)
+}
+
+// filesystemType returns the type of the filesystem containing path.
+func filesystemType(path string) (string, error) {
+	out, err := exec.Command("stat", "-f", "-c", "%T", path).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// directIOUnsupported returns whether a failure to open a file with direct I/O
+// is expected on the filesystem.
+func directIOUnsupported(fsType string) bool {
+	return fsType == "tmpfs" || fsType == "ramfs"
+}
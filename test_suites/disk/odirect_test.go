@@ -0,0 +1,79 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+const (
+	// directIOAlignment satisfies the alignment requirements of direct I/O on
+	// both 512 byte and 4k sector disks.
+	directIOAlignment = 4096
+	directIOSize      = 1 << 20
+)
+
+// alignedBuffer returns a buffer of size bytes whose address is aligned for
+// direct I/O.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directIOAlignment)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % directIOAlignment); rem != 0 {
+		offset = directIOAlignment - rem
+	}
+	return buf[offset : offset+size]
+}
+
+// TestODirect validates that files on the boot disk can be written and read
+// back with direct I/O, bypassing the page cache.
+func TestODirect(t *testing.T) {
+	fsType, err := filesystemType(filepath.Dir(directIOTestFile))
+	if err != nil {
+		t.Fatalf("could not get filesystem type of %s: %v", filepath.Dir(directIOTestFile), err)
+	}
+	f, err := openDirect(directIOTestFile)
+	if err != nil {
+		if directIOUnsupported(fsType) {
+			t.Skipf("%s filesystem does not support direct I/O: %v", fsType, err)
+		}
+		t.Fatalf("could not open %s with direct I/O on %s filesystem: %v", directIOTestFile, fsType, err)
+	}
+	defer os.Remove(directIOTestFile)
+	defer f.Close()
+
+	data := alignedBuffer(directIOSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		t.Fatalf("direct I/O write to %s filesystem failed: %v", fsType, err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatalf("could not sync %s: %v", directIOTestFile, err)
+	}
+	read := alignedBuffer(directIOSize)
+	if _, err := f.ReadAt(read, 0); err != nil {
+		t.Fatalf("direct I/O read from %s filesystem failed: %v", fsType, err)
+	}
+	if !bytes.Equal(data, read) {
+		t.Fatalf("data read back with direct I/O from %s filesystem does not match data written", fsType)
+	}
+	t.Logf("direct I/O succeeded on %s filesystem", fsType)
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// directIOTestFile is where TestODirect writes, on the boot disk.
+	directIOTestFile = `C:\cit-odirect`
+
+	fileFlagNoBuffering  = 0x20000000
+	fileFlagWriteThrough = 0x80000000
+)
+
+// openDirect opens path for reading and writing, bypassing the file cache.
+func openDirect(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.CREATE_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL|fileFlagNoBuffering|fileFlagWriteThrough, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "CreateFile", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
+
+// filesystemType returns the type of the filesystem containing path.
+func filesystemType(path string) (string, error) {
+	out, err := utils.RunPowershellCmd("(Get-Volume -FilePath '" + path + "').FileSystem")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.Stdout), nil
+}
+
+// directIOUnsupported returns whether a failure to open a file with direct I/O
+// is expected on the filesystem.
+func directIOUnsupported(fsType string) bool {
+	return false
+}
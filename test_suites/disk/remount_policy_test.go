@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// ext4 errors behaviors as reported by tune2fs, and the mount options which
+// select them.
+var ext4ErrorBehaviors = map[string]string{
+	"Continue":          "continue",
+	"Remount read-only": "remount-ro",
+	"Panic":             "panic",
+	"errors=continue":   "continue",
+	"errors=remount-ro": "remount-ro",
+	"errors=panic":      "panic",
+}
+
+// acceptedExt4ErrorBehaviors are the behaviors which keep a failing disk from
+// being written to further.
+var acceptedExt4ErrorBehaviors = map[string]bool{"remount-ro": true, "panic": true}
+
+var errorsOptionRe = regexp.MustCompile(`(^|,)(errors=[a-z-]+)(,|$)`)
+
+// rootErrorBehavior returns the error behavior of the ext4 root filesystem,
+// from its mount options if set there and otherwise from its superblock.
+func rootErrorBehavior(t *testing.T, source, options string) string {
+	t.Helper()
+	if m := errorsOptionRe.FindStringSubmatch(options); m != nil {
+		return ext4ErrorBehaviors[m[2]]
+	}
+	out, err := exec.Command("tune2fs", "-l", source).Output()
+	if err != nil {
+		t.Fatalf("could not read superblock of %s: %v", source, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if value, ok := strings.CutPrefix(line, "Errors behavior:"); ok {
+			return ext4ErrorBehaviors[strings.TrimSpace(value)]
+		}
+	}
+	t.Fatalf("no errors behavior in superblock of %s", source)
+	return ""
+}
+
+// TestRemountReadOnlyPolicy validates that the root filesystem stops taking
+// writes when it hits an error, rather than continuing on a failing disk.
+func TestRemountReadOnlyPolicy(t *testing.T) {
+	if utils.IsWindows() {
+		testRemountReadOnlyPolicyWindows(t)
+		return
+	}
+	out, err := exec.Command("findmnt", "-n", "-o", "SOURCE,FSTYPE,OPTIONS", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem: %v", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 3 {
+		t.Fatalf("unexpected findmnt output %q", out)
+	}
+	source, fsType, options := fields[0], fields[1], fields[2]
+	t.Logf("root filesystem %s is %s mounted with %s", source, fsType, options)
+	if fsType != "ext4" {
+		t.Skipf("%s shuts down on errors without a configurable policy", fsType)
+	}
+	if !utils.CheckLinuxCmdExists("tune2fs") && !errorsOptionRe.MatchString(options) {
+		t.Skip("tune2fs is not installed and the errors behavior is not set in the mount options")
+	}
+	if behavior := rootErrorBehavior(t, source, options); !acceptedExt4ErrorBehaviors[behavior] {
+		t.Errorf("root filesystem errors behavior is %q, want remount-ro", behavior)
+	}
+}
+
+func testRemountReadOnlyPolicyWindows(t *testing.T) {
+	output, err := utils.RunPowershellCmd("fsutil dirty query C:")
+	if err != nil {
+		t.Fatalf("could not query dirty bit of C: %v %s", err, output.Stderr)
+	}
+	t.Logf("fsutil: %s", strings.TrimSpace(output.Stdout))
+	if !strings.Contains(output.Stdout, "NOT Dirty") {
+		t.Errorf("volume C: is marked dirty: %s", output.Stdout)
+	}
+	// autochk checks dirty volumes at boot only if it is in BootExecute.
+	output, err = utils.RunPowershellCmd(`(Get-ItemProperty 'HKLM:\SYSTEM\CurrentControlSet\Control\Session Manager').BootExecute`)
+	if err != nil {
+		t.Fatalf("could not read BootExecute: %v %s", err, output.Stderr)
+	}
+	t.Logf("BootExecute: %s", strings.TrimSpace(output.Stdout))
+	if !strings.Contains(output.Stdout, "autocheck autochk") {
+		t.Errorf("autochk does not run at boot to repair dirty volumes, BootExecute is %q", output.Stdout)
+	}
+	output, err = utils.RunPowershellCmd("chkntfs C:")
+	if err != nil {
+		t.Fatalf("chkntfs C: failed: %v %s", err, output.Stderr)
+	}
+	if strings.Contains(output.Stdout, "excluded") {
+		t.Errorf("volume C: is excluded from checking at boot: %s", output.Stdout)
+	}
+}
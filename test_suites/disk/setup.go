@@ -16,6 +16,7 @@
 package disk
 
 import (
+	"flag"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
@@ -44,8 +45,19 @@ var (
 	}
 )
 
+var manyDisks = flag.Bool("disk_many_disks", false, "run TestManyDisks, which attaches the maximum number of data disks for the machine type. Off by default for cost and quota")
+
 const (
 	resizeDiskSize = 200
+	// firstBootDiskSize is the boot disk size for TestFirstBootExpand, larger
+	// than the disk size of any image so the root filesystem must be expanded,
+	// and large enough that partitions other than root fit in the tolerance.
+	firstBootDiskSize = 100
+	// luksDataDiskName is the name of the data disk encrypted by TestLUKS when
+	// the image has no encrypted volumes of its own.
+	luksDataDiskName = "luksdata"
+	// writeCacheDiskName is the pd-ssd data disk TestWriteCacheFlush writes to.
+	writeCacheDiskName = "writecachedata"
 )
 
 // TestSetup sets up the test workflow.
@@ -62,7 +74,61 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 			return err
 		}
 	}
-	vm.RunTests("TestDiskReadWrite|TestDiskResize")
+	vm.RunTests("TestDiskReadWrite|TestDiskResize|TestLVM|TestDeviceNaming|TestODirect|TestRemountReadOnlyPolicy|TestDiskHealth")
+
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		luksInst := &daisy.Instance{}
+		luksInst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
+		// The test encrypts the data disk on the first boot and checks that it
+		// is unlocked and mounted after the reboot.
+		luksvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "luks", Type: imagetest.PdBalanced}, {Name: luksDataDiskName, Type: imagetest.PdBalanced, SizeGb: 10}}, luksInst)
+		if err != nil {
+			return err
+		}
+		if err := luksvm.Reboot(); err != nil {
+			return err
+		}
+		luksvm.RunTests("TestLUKS")
+	}
+
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		firstbootvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "firstbootexpand", Type: imagetest.PdBalanced, SizeGb: firstBootDiskSize}}, nil)
+		if err != nil {
+			return err
+		}
+		firstbootvm.RunTests("TestFirstBootExpand")
+	}
+
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		writeCacheVM, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "writecache", Type: imagetest.PdBalanced}, {Name: writeCacheDiskName, Type: imagetest.PdSsd, SizeGb: 10}}, nil)
+		if err != nil {
+			return err
+		}
+		writeCacheVM.RunTests("TestWriteCacheFlush")
+	}
+
+	if *manyDisks && !utils.HasFeature(t.Image, "WINDOWS") {
+		manyDisksVM, err := t.CreateTestVM("manydisks")
+		if err != nil {
+			return err
+		}
+		manyDisksVM.AddScope("https://www.googleapis.com/auth/cloud-platform")
+		manyDisksVM.AddMetadata("many-disks", "true")
+		manyDisksVM.RunTests("TestManyDisks")
+	}
+
+	snapshotInst := &daisy.Instance{}
+	snapshotInst.Scopes = append(snapshotInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+	snapshotvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "snapshotRestore", Type: imagetest.PdBalanced}}, snapshotInst)
+	if err != nil {
+		return err
+	}
+	// Restoring the snapshot to a disk and reading it back is only implemented
+	// on linux.
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		snapshotvm.AddMetadata("snapshot-restore", "true")
+	}
+	snapshotvm.RunTests("TestSnapshotRestore|TestBootDiskMetadataConsistency")
 	// Block device naming is an interaction between OS and hardware alone on windows, there is no guest-environment equivalent of udev rules for us to test.
 	if !utils.HasFeature(t.Image, "WINDOWS") && utils.HasFeature(t.Image, "GVNIC") {
 		for _, tc := range blockdevNamingCases {
@@ -77,7 +143,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 			if err != nil {
 				return err
 			}
-			vm.RunTests("TestBlockDeviceNaming")
+			vm.RunTests("TestBlockDeviceNaming|TestDeviceNaming|TestNVMeNamespaces")
 		}
 	}
 	return nil
@@ -0,0 +1,239 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	snapshotMarkerContents = "written before snapshot"
+	snapshotMarkerModified = "written after snapshot"
+	snapshotRestoreMount   = "/mnt/disks/snapshotrestore"
+)
+
+// TestSnapshotRestore validates that a snapshot of the boot disk captures the
+// disk contents at the time it was taken.
+func TestSnapshotRestore(t *testing.T) {
+	ctx := utils.Context(t)
+	marker := "/var/snapshot-marker"
+	if utils.IsWindows() {
+		marker = `C:\snapshot-marker`
+	}
+	if err := os.WriteFile(marker, []byte(snapshotMarkerContents), 0644); err != nil {
+		t.Fatalf("could not write marker file: %v", err)
+	}
+	if !utils.IsWindows() {
+		if err := exec.Command("sync").Run(); err != nil {
+			t.Fatalf("could not sync filesystems: %v", err)
+		}
+	}
+
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	inst, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance: %v", err)
+	}
+	disksClient, err := compute.NewDisksRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make disks client: %v", err)
+	}
+	t.Cleanup(func() { disksClient.Close() })
+	snapshotsClient, err := compute.NewSnapshotsRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make snapshots client: %v", err)
+	}
+	t.Cleanup(func() { snapshotsClient.Close() })
+
+	snapshotName := "restore-" + inst
+	sourceDisk := fmt.Sprintf("projects/%s/zones/%s/disks/%s", prj, zone, inst)
+	op, err := disksClient.CreateSnapshot(ctx, &computepb.CreateSnapshotDiskRequest{
+		Project:    prj,
+		GuestFlush: proto.Bool(true),
+		Zone:       zone,
+		Disk:       inst,
+		SnapshotResource: &computepb.Snapshot{
+			Name:       proto.String(snapshotName),
+			SourceDisk: proto.String(sourceDisk),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create snapshot: %v", err)
+	}
+	t.Cleanup(func() {
+		op, err := snapshotsClient.Delete(context.Background(), &computepb.DeleteSnapshotRequest{Project: prj, Snapshot: snapshotName})
+		if err != nil {
+			t.Logf("unable to delete snapshot %s: %v", snapshotName, err)
+			return
+		}
+		if err := op.Wait(context.Background()); err != nil {
+			t.Logf("failed waiting for deletion of snapshot %s: %v", snapshotName, err)
+		}
+	})
+	if err := op.Wait(ctx); err != nil {
+		t.Fatalf("failed to wait for snapshot creation: %v", err)
+	}
+
+	if err := os.WriteFile(marker, []byte(snapshotMarkerModified), 0644); err != nil {
+		t.Fatalf("could not modify marker file: %v", err)
+	}
+
+	snapshot, err := snapshotsClient.Get(ctx, &computepb.GetSnapshotRequest{Project: prj, Snapshot: snapshotName})
+	if err != nil {
+		t.Fatalf("could not get snapshot %s: %v", snapshotName, err)
+	}
+	disk, err := disksClient.Get(ctx, &computepb.GetDiskRequest{Project: prj, Zone: zone, Disk: inst})
+	if err != nil {
+		t.Fatalf("could not get disk %s: %v", inst, err)
+	}
+	if snapshot.GetStatus() != "READY" {
+		t.Errorf("snapshot %s has status %s, want READY", snapshotName, snapshot.GetStatus())
+	}
+	if !strings.HasSuffix(snapshot.GetSourceDisk(), sourceDisk) {
+		t.Errorf("snapshot %s has source disk %s, want %s", snapshotName, snapshot.GetSourceDisk(), sourceDisk)
+	}
+	if snapshot.GetDiskSizeGb() != disk.GetSizeGb() {
+		t.Errorf("snapshot %s has disk size %dGB, want %dGB", snapshotName, snapshot.GetDiskSizeGb(), disk.GetSizeGb())
+	}
+	if snapshot.GetStorageBytes() <= 0 {
+		t.Errorf("snapshot %s reports %d storage bytes, want > 0", snapshotName, snapshot.GetStorageBytes())
+	}
+
+	if restore, err := utils.GetMetadata(ctx, "instance", "attributes", "snapshot-restore"); err != nil || restore != "true" {
+		t.Log("snapshot-restore is not enabled, not restoring snapshot to a disk")
+		return
+	}
+	// The restored disk is attached to this instance, which on LVM root images
+	// would bring in a second copy of the root PV and VG with the same UUIDs,
+	// and put the marker on a logical volume rather than a partition.
+	if rootSource, err := exec.Command("findmnt", "-n", "-o", "SOURCE", "/").Output(); err == nil {
+		if err := exec.Command("lvs", strings.TrimSpace(string(rootSource))).Run(); err == nil {
+			t.Log("root filesystem is on LVM, not restoring snapshot to a disk")
+			return
+		}
+	}
+	restored, err := restoreSnapshotMarker(ctx, t, disksClient, prj, zone, inst, snapshot.GetSelfLink(), marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != snapshotMarkerContents {
+		t.Errorf("marker file on disk restored from snapshot has contents %q, want %q", restored, snapshotMarkerContents)
+	}
+}
+
+// restoreSnapshotMarker creates a disk from the snapshot, attaches it to the
+// instance, and returns the contents of the marker file found on it.
+func restoreSnapshotMarker(ctx context.Context, t *testing.T, disksClient *compute.DisksClient, prj, zone, inst, snapshot, marker string) (string, error) {
+	t.Helper()
+	instancesClient, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not make instances client: %v", err)
+	}
+	t.Cleanup(func() { instancesClient.Close() })
+
+	diskName := "restored-" + inst
+	op, err := disksClient.Insert(ctx, &computepb.InsertDiskRequest{
+		Project: prj,
+		Zone:    zone,
+		DiskResource: &computepb.Disk{
+			Name:           proto.String(diskName),
+			SourceSnapshot: proto.String(snapshot),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not create disk from snapshot: %v", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return "", fmt.Errorf("failed waiting for disk creation from snapshot: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := disksClient.Delete(context.Background(), &computepb.DeleteDiskRequest{Project: prj, Zone: zone, Disk: diskName}); err != nil {
+			t.Logf("unable to delete disk %s: %v", diskName, err)
+		}
+	})
+
+	op, err = instancesClient.AttachDisk(ctx, &computepb.AttachDiskInstanceRequest{
+		Project:  prj,
+		Zone:     zone,
+		Instance: inst,
+		AttachedDiskResource: &computepb.AttachedDisk{
+			Source:     proto.String(fmt.Sprintf("projects/%s/zones/%s/disks/%s", prj, zone, diskName)),
+			DeviceName: proto.String(diskName),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not attach restored disk: %v", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return "", fmt.Errorf("failed waiting for restored disk attachment: %v", err)
+	}
+	t.Cleanup(func() {
+		op, err := instancesClient.DetachDisk(context.Background(), &computepb.DetachDiskInstanceRequest{Project: prj, Zone: zone, Instance: inst, DeviceName: diskName})
+		if err != nil {
+			t.Logf("unable to detach disk %s: %v", diskName, err)
+			return
+		}
+		if err := op.Wait(context.Background()); err != nil {
+			t.Logf("failed waiting for detachment of disk %s: %v", diskName, err)
+		}
+	})
+
+	if err := os.MkdirAll(snapshotRestoreMount, 0755); err != nil {
+		return "", fmt.Errorf("could not create mount point: %v", err)
+	}
+	var partitions []string
+	for i := 0; i < 30; i++ {
+		partitions, _ = filepath.Glob("/dev/disk/by-id/google-" + diskName + "-part*")
+		if len(partitions) > 0 {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if len(partitions) == 0 {
+		return "", fmt.Errorf("no partitions found on restored disk %s", diskName)
+	}
+	// The restored root filesystem has the same UUID as the mounted one, which
+	// xfs refuses to mount without nouuid.
+	for _, part := range partitions {
+		opts := "ro"
+		if fstype, err := exec.Command("blkid", "-o", "value", "-s", "TYPE", part).Output(); err == nil && strings.TrimSpace(string(fstype)) == "xfs" {
+			opts += ",nouuid"
+		}
+		if err := exec.Command("mount", "-o", opts, part, snapshotRestoreMount).Run(); err != nil {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(snapshotRestoreMount, marker))
+		exec.Command("umount", snapshotRestoreMount).Run()
+		if err == nil {
+			return string(contents), nil
+		}
+	}
+	return "", fmt.Errorf("could not find marker file %s on any partition of restored disk %s", marker, diskName)
+}
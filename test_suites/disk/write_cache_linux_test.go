@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// readSysfs returns the trimmed contents of a sysfs attribute.
+func readSysfs(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return strings.TrimSpace(string(data)), err
+}
+
+// flushCount returns the number of flush requests completed by a block
+// device, or -1 if the kernel does not report flushes.
+func flushCount(dev string) (int, error) {
+	stat, err := readSysfs(filepath.Join("/sys/block", dev, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// Flush statistics are the 16th and 17th fields, added in linux 5.5.
+	fields := strings.Fields(stat)
+	if len(fields) < 17 {
+		return -1, nil
+	}
+	return strconv.Atoi(fields[15])
+}
+
+// TestWriteCacheFlush validates that an SSD persistent disk reports its write
+// cache consistently and that flushes issued to it are completed.
+func TestWriteCacheFlush(t *testing.T) {
+	utils.LinuxOnly(t)
+	path, err := filepath.EvalSymlinks("/dev/disk/by-id/google-" + writeCacheDiskName)
+	if err != nil {
+		t.Skipf("no %s disk attached: %v", writeCacheDiskName, err)
+	}
+	dev := filepath.Base(path)
+	writeCache, err := readSysfs(filepath.Join("/sys/block", dev, "queue", "write_cache"))
+	if err != nil {
+		t.Fatalf("could not read write cache mode of %s: %v", dev, err)
+	}
+	fua, _ := readSysfs(filepath.Join("/sys/block", dev, "queue", "fua"))
+	t.Logf("%s: write_cache %q, fua %q", dev, writeCache, fua)
+	if cacheTypes, _ := filepath.Glob(filepath.Join("/sys/block", dev, "device", "scsi_disk", "*", "cache_type")); len(cacheTypes) > 0 {
+		cacheType, err := readSysfs(cacheTypes[0])
+		if err != nil {
+			t.Fatalf("could not read SCSI cache type of %s: %v", dev, err)
+		}
+		t.Logf("%s: SCSI cache_type %q", dev, cacheType)
+		// The block layer write cache mode is derived from the write cache
+		// enable bit the device reports.
+		if volatile := strings.Contains(cacheType, "write back"); volatile != (writeCache == "write back") {
+			t.Errorf("%s reports SCSI cache type %q but block layer write cache %q", dev, cacheType, writeCache)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_DIRECT, 0)
+	if err != nil {
+		t.Fatalf("could not open %s for direct I/O: %v", path, err)
+	}
+	defer f.Close()
+	data := alignedBuffer(directIOSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("could not generate data: %v", err)
+	}
+	before, err := flushCount(dev)
+	if err != nil {
+		t.Fatalf("could not read flush statistics of %s: %v", dev, err)
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		t.Fatalf("could not write to %s: %v", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatalf("flush of %s failed: %v", path, err)
+	}
+	after, err := flushCount(dev)
+	if err != nil {
+		t.Fatalf("could not read flush statistics of %s: %v", dev, err)
+	}
+	switch {
+	case before < 0:
+		t.Logf("kernel does not report flush statistics for %s", dev)
+	case writeCache == "write back" && after <= before:
+		t.Errorf("%s has a volatile write cache but no flush was completed by fsync, %d flushes before and %d after", dev, before, after)
+	default:
+		t.Logf("%s completed %d flushes during fsync", dev, after-before)
+	}
+
+	read := alignedBuffer(directIOSize)
+	if _, err := f.ReadAt(read, 0); err != nil {
+		t.Fatalf("could not read back %s: %v", path, err)
+	}
+	if !bytes.Equal(data, read) {
+		t.Errorf("data read back from %s after flush does not match what was written", path)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestagent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// agentRecoveryTimeout is how long the agent has to reapply its configuration
+// after a restart.
+const agentRecoveryTimeout = 2 * time.Minute
+
+// restartUserKey returns the ssh key for restartUser from metadata.
+func restartUserKey(t *testing.T) string {
+	t.Helper()
+	keys, err := utils.GetMetadata(utils.Context(t), "instance", "attributes", "ssh-keys")
+	if err != nil {
+		t.Fatalf("could not get ssh-keys from metadata: %v", err)
+	}
+	for _, line := range strings.Split(keys, "\n") {
+		if key, ok := strings.CutPrefix(strings.TrimSpace(line), restartUser+":"); ok {
+			return strings.TrimSpace(key)
+		}
+	}
+	t.Fatalf("no ssh key for %s in metadata", restartUser)
+	return ""
+}
+
+// waitForAuthorizedKey waits for key to be present in path exactly once.
+func waitForAuthorizedKey(path, key string) error {
+	var count int
+	for start := time.Now(); time.Since(start) < agentRecoveryTimeout; time.Sleep(5 * time.Second) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		count = strings.Count(string(content), key)
+		if count > 0 {
+			break
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("key appears %d times in %s, want 1", count, path)
+	}
+	return nil
+}
+
+func agentStatus(t *testing.T) string {
+	t.Helper()
+	if utils.IsWindows() {
+		out, err := utils.RunPowershellCmd(`(Get-Service GCEAgent).Status`)
+		if err != nil {
+			t.Fatalf("could not get agent status: %v %s", err, out.Stderr)
+		}
+		return strings.TrimSpace(out.Stdout)
+	}
+	out, _ := exec.Command("systemctl", "is-active", "google-guest-agent").Output()
+	return strings.TrimSpace(string(out))
+}
+
+func agentProcessCount(t *testing.T) int {
+	t.Helper()
+	if utils.IsWindows() {
+		out, err := utils.RunPowershellCmd(`@(Get-Process GCEWindowsAgent -ErrorAction SilentlyContinue).Count`)
+		if err != nil {
+			t.Fatalf("could not list agent processes: %v %s", err, out.Stderr)
+		}
+		var n int
+		fmt.Sscan(strings.TrimSpace(out.Stdout), &n)
+		return n
+	}
+	out, _ := exec.Command("pgrep", "-f", "(^|/)google_guest_agent( |$)").Output()
+	return len(strings.Fields(string(out)))
+}
+
+// TestGuestAgentRestart validates that the guest agent comes back healthy
+// after a restart and reapplies configuration from metadata.
+func TestGuestAgentRestart(t *testing.T) {
+	var keyPath, key string
+	if !utils.IsWindows() {
+		key = restartUserKey(t)
+		keyPath = filepath.Join("/home", restartUser, ".ssh", "authorized_keys")
+		if err := waitForAuthorizedKey(keyPath, key); err != nil {
+			t.Fatalf("agent did not apply metadata ssh key before restart: %v", err)
+		}
+		// Remove the key so the restarted agent has to put it back.
+		if err := os.Remove(keyPath); err != nil {
+			t.Fatalf("could not remove %s: %v", keyPath, err)
+		}
+	}
+
+	restartAgent(t)
+	var status string
+	for start := time.Now(); time.Since(start) < agentRecoveryTimeout; time.Sleep(time.Second) {
+		if status = agentStatus(t); status == "active" || status == "Running" {
+			break
+		}
+	}
+	t.Logf("agent status after restart: %s", status)
+	if status != "active" && status != "Running" {
+		t.Fatalf("agent is %s after restart", status)
+	}
+	if n := agentProcessCount(t); n != 1 {
+		t.Errorf("found %d guest agent processes after restart, want 1", n)
+	}
+	if keyPath != "" {
+		if err := waitForAuthorizedKey(keyPath, key); err != nil {
+			t.Errorf("agent did not reapply metadata ssh key after restart: %v", err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestagent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// agentStoppedPeriod is how long the agent is kept stopped.
+const agentStoppedPeriod = time.Minute
+
+// capability is something the instance should keep doing while the agent is
+// down.
+type capability struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// agentStoppedCapabilities returns the capabilities checked while the agent
+// is stopped.
+func agentStoppedCapabilities(keyPath, key string) []capability {
+	return []capability{
+		{"sshd", func(ctx context.Context) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", "localhost:22")
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			banner, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(banner, "SSH-") {
+				return fmt.Errorf("unexpected banner %q", banner)
+			}
+			return nil
+		}},
+		{"existing ssh key", func(ctx context.Context) error {
+			content, err := os.ReadFile(keyPath)
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(content), key) {
+				return fmt.Errorf("key is no longer in %s", keyPath)
+			}
+			return nil
+		}},
+		{"primary address", func(ctx context.Context) error {
+			ip, err := utils.GetMetadata(ctx, "instance", "network-interfaces", "0", "ip")
+			if err != nil {
+				return err
+			}
+			iface, err := utils.GetInterface(ctx, 0)
+			if err != nil {
+				return err
+			}
+			addrs, err := iface.Addrs()
+			if err != nil {
+				return err
+			}
+			for _, addr := range addrs {
+				if strings.HasPrefix(addr.String(), ip+"/") {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s does not have address %s", iface.Name, ip)
+		}},
+		{"metadata server", func(ctx context.Context) error {
+			_, err := utils.GetMetadata(ctx, "instance", "id")
+			return err
+		}},
+		{"dns", func(ctx context.Context) error {
+			_, err := net.DefaultResolver.LookupHost(ctx, "www.googleapis.com")
+			return err
+		}},
+	}
+}
+
+// TestGuestAgentStoppedBehavior validates that the instance stays reachable
+// while the guest agent is stopped.
+func TestGuestAgentStoppedBehavior(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	key := restartUserKey(t)
+	keyPath := filepath.Join("/home", restartUser, ".ssh", "authorized_keys")
+	if err := waitForAuthorizedKey(keyPath, key); err != nil {
+		t.Fatalf("agent did not apply metadata ssh key before it was stopped: %v", err)
+	}
+	capabilities := agentStoppedCapabilities(keyPath, key)
+	for _, c := range capabilities {
+		shortCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := c.check(shortCtx)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s does not work before stopping the agent: %v", c.name, err)
+		}
+	}
+
+	if out, err := exec.CommandContext(ctx, "systemctl", "stop", "google-guest-agent").CombinedOutput(); err != nil {
+		t.Fatalf("could not stop agent: %v %s", err, out)
+	}
+	t.Cleanup(func() {
+		if status := agentStatus(t); status != "active" {
+			restartAgent(t)
+		}
+	})
+	if status := agentStatus(t); status == "active" {
+		t.Fatalf("agent is still %s after stopping it", status)
+	}
+
+	lost := make(map[string]error)
+	for start := time.Now(); time.Since(start) < agentStoppedPeriod; time.Sleep(10 * time.Second) {
+		for _, c := range capabilities {
+			if _, ok := lost[c.name]; ok {
+				continue
+			}
+			shortCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			if err := c.check(shortCtx); err != nil {
+				lost[c.name] = fmt.Errorf("lost %v after stopping the agent: %v", time.Since(start).Round(time.Second), err)
+			}
+			cancel()
+		}
+	}
+	for _, c := range capabilities {
+		if err, ok := lost[c.name]; ok {
+			t.Errorf("%s %v", c.name, err)
+		}
+	}
+	t.Logf("%d of %d capabilities lost while the agent was stopped", len(lost), len(capabilities))
+
+	restartAgent(t)
+	var status string
+	for start := time.Now(); time.Since(start) < agentRecoveryTimeout; time.Sleep(time.Second) {
+		if status = agentStatus(t); status == "active" {
+			break
+		}
+	}
+	if status != "active" {
+		t.Errorf("agent is %s after starting it again", status)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestagent
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+var agentVersionRe = regexp.MustCompile(`GCE Agent Started \(version ([^)]+)\)`)
+
+// installedAgentVersion returns the version of the installed guest agent
+// package as reported by the package manager.
+func installedAgentVersion() (string, error) {
+	if utils.IsWindows() {
+		out, err := utils.RunPowershellCmd("googet installed google-compute-engine-windows")
+		if err != nil {
+			return "", fmt.Errorf("googet installed failed: %v %s", err, out.Stderr)
+		}
+		return strings.TrimSpace(out.Stdout), nil
+	}
+	var cmd *exec.Cmd
+	switch {
+	case utils.CheckLinuxCmdExists("dpkg-query"):
+		cmd = exec.Command("dpkg-query", "-W", "-f=${Version}", "google-guest-agent")
+	case utils.CheckLinuxCmdExists("rpm"):
+		cmd = exec.Command("rpm", "-q", "--queryformat", "%{VERSION}", "google-guest-agent")
+	default:
+		return "", fmt.Errorf("no supported package manager found")
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v", cmd, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// TestGuestAgentTelemetry validates that the guest agent reports its version
+// and publishes the signals the platform relies on.
+func TestGuestAgentTelemetry(t *testing.T) {
+	ctx := utils.Context(t)
+	if disabled, err := utils.GetMetadata(ctx, "instance", "attributes", "disable-guest-telemetry"); err == nil && disabled == "true" {
+		t.Skip("guest telemetry is disabled")
+	}
+	installed, err := installedAgentVersion()
+	if err != nil {
+		t.Skipf("guest agent package is not installed: %v", err)
+	}
+	output := getAgentOutput(t)
+	if !strings.Contains(output, "telemetry") {
+		t.Skip("agent does not support telemetry")
+	}
+	if !strings.Contains(output, "Successfully scheduled job telemetryJobID") {
+		t.Errorf("Telemetry jobs are not scheduled with telemetry enabled. Agent logs: %s", output)
+	}
+
+	matches := agentVersionRe.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		t.Fatalf("Agent did not report its version. Agent logs: %s", output)
+	}
+	reported := matches[len(matches)-1][1]
+	t.Logf("Agent reports version %s, installed package is %s", reported, installed)
+	if !strings.Contains(installed, reported) {
+		t.Errorf("Agent reports version %s, which does not match the installed package %s", reported, installed)
+	}
+
+	if !utils.IsWindows() {
+		hostkeys, err := utils.GetMetadata(ctx, "instance", "guest-attributes", "hostkeys", "/")
+		if err != nil {
+			t.Fatalf("Agent did not publish host keys to guest attributes: %v", err)
+		}
+		if strings.TrimSpace(hostkeys) == "" {
+			t.Errorf("Agent published no host keys to guest attributes")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestagent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// instanceIDFiles are where the guest agent records the instance id it ran
+// first boot setup for. Newer agents use the first, older ones the second.
+var instanceIDFiles = []string{"/etc/google_instance_id", "/etc/default/instance_configs.cfg"}
+
+// recordedInstanceID returns the instance id recorded by instance setup and
+// the file it was read from.
+func recordedInstanceID() (string, string, error) {
+	for _, path := range instanceIDFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if key, value, ok := strings.Cut(line, "="); ok {
+				if strings.TrimSpace(key) == "instance_id" {
+					return strings.TrimSpace(value), path, nil
+				}
+			} else if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "[") {
+				return line, path, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("no instance id recorded in %v", instanceIDFiles)
+}
+
+// TestInstanceSetup validates that the guest agent completed its one time
+// first boot setup.
+func TestInstanceSetup(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("google_guest_agent") {
+		t.Skip("guest agent is not installed")
+	}
+	ctx := utils.Context(t)
+
+	t.Run("instance id", func(t *testing.T) {
+		want, err := utils.GetMetadata(ctx, "instance", "id")
+		if err != nil {
+			t.Fatalf("could not get instance id from metadata: %v", err)
+		}
+		got, path, err := recordedInstanceID()
+		if err != nil {
+			t.Fatalf("instance setup did not record the instance id: %v", err)
+		}
+		if got != want {
+			t.Errorf("%s records instance id %s, want %s", path, got, want)
+		}
+	})
+
+	t.Run("host keys", func(t *testing.T) {
+		keys, err := filepath.Glob("/etc/ssh/ssh_host_*_key.pub")
+		if err != nil || len(keys) == 0 {
+			t.Fatalf("instance setup did not generate host keys: %v", err)
+		}
+		for _, key := range keys {
+			content, err := os.ReadFile(key)
+			if err != nil {
+				t.Errorf("could not read %s: %v", key, err)
+				continue
+			}
+			fields := strings.Fields(string(content))
+			if len(fields) < 2 {
+				t.Errorf("%s is malformed", key)
+				continue
+			}
+			// The agent publishes each host key as a guest attribute once
+			// generated.
+			published, err := utils.GetMetadata(ctx, "instance", "guest-attributes", "hostkeys", fields[0])
+			if err != nil {
+				t.Errorf("host key %s was not published to guest attributes: %v", fields[0], err)
+				continue
+			}
+			if !strings.Contains(published, fields[1]) {
+				t.Errorf("published host key %s does not match %s", fields[0], key)
+			}
+		}
+	})
+
+	t.Run("setup log", func(t *testing.T) {
+		out, err := exec.CommandContext(ctx, "journalctl", "-b", "-o", "cat", "-u", "google-guest-agent", "-u", "google-instance-setup").Output()
+		if err != nil {
+			t.Fatalf("could not get agent logs: %v", err)
+		}
+		if !strings.Contains(string(out), "GCE Agent Started") {
+			t.Errorf("guest agent did not log a start in this boot")
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			lower := strings.ToLower(line)
+			if strings.Contains(lower, "error") && (strings.Contains(lower, "setup") || strings.Contains(lower, "host key") || strings.Contains(lower, "boto")) {
+				t.Errorf("instance setup task failed: %s", line)
+			}
+		}
+	})
+}
@@ -22,6 +22,10 @@ import (
 // Name is the name of the test package. It must match the directory name.
 const Name = "guestagent"
 
+// restartUser is the user whose metadata ssh key must be reapplied when the
+// guest agent restarts.
+const restartUser = "restart-user"
+
 // TestSetup sets up the test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
 
@@ -45,6 +49,29 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	telemetryenabledvm.AddMetadata("disable-guest-telemetry", "false")
 	telemetryenabledvm.RunTests("TestTelemetryEnabled")
 
+	agenttelemetryinst := &daisy.Instance{}
+	agenttelemetryinst.Name = "agentTelemetry"
+	agenttelemetryvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: agenttelemetryinst.Name, Type: imagetest.PdBalanced}}, agenttelemetryinst)
+	if err != nil {
+		return err
+	}
+	agenttelemetryvm.AddMetadata("enable-guest-attributes", "true")
+	agenttelemetryvm.RunTests("TestGuestAgentTelemetry")
+
+	restartinst := &daisy.Instance{}
+	restartinst.Name = "agentRestart"
+	restartvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: restartinst.Name, Type: imagetest.PdBalanced}}, restartinst)
+	if err != nil {
+		return err
+	}
+	publicKey, err := t.AddSSHKey(restartUser)
+	if err != nil {
+		return err
+	}
+	restartvm.AddUser(restartUser, publicKey)
+	restartvm.AddMetadata("enable-oslogin", "false")
+	restartvm.RunTests("TestGuestAgentRestart|TestGuestAgentStoppedBehavior")
+
 	snapshotinst := &daisy.Instance{}
 	snapshotinst.Scopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
 	snapshotinst.Name = "snapshotScripts"
@@ -54,6 +81,17 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	}
 	snapshotvm.RunTests("TestSnapshotScripts")
 
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		setupinst := &daisy.Instance{}
+		setupinst.Name = "instanceSetup"
+		setupvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: setupinst.Name, Type: imagetest.PdBalanced}}, setupinst)
+		if err != nil {
+			return err
+		}
+		setupvm.AddMetadata("enable-guest-attributes", "true")
+		setupvm.RunTests("TestInstanceSetup")
+	}
+
 	if utils.HasFeature(t.Image, "WINDOWS") {
 		passwordInst := &daisy.Instance{}
 		passwordInst.Scopes = append(passwordInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
@@ -61,7 +99,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		if err != nil {
 			return err
 		}
-		windowsaccountVM.RunTests("TestWindowsPasswordReset")
+		windowsaccountVM.RunTests("TestWindowsPasswordReset|TestWindowsAccountManagement")
 	}
 	return nil
 }
@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestagent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-daisy/compute"
+)
+
+// newAccountUser is created by the guest agent from windows-keys metadata,
+// unlike user which exists before its password is reset.
+const newAccountUser = "windowsuser6"
+
+// newAccountGroups are the local groups the guest agent adds new accounts to.
+var newAccountGroups = []string{"Administrators"}
+
+// TestWindowsAccountManagement validates that the guest agent creates an
+// account requested through windows-keys metadata, sets its password, and
+// adds it to the expected groups.
+func TestWindowsAccountManagement(t *testing.T) {
+	utils.WindowsOnly(t)
+	existing := verifyPowershellCmd(t, "Get-CIMInstance Win32_UserAccount | ForEach-Object { Write-Output $_.Name}")
+	if strings.Contains(existing, newAccountUser) {
+		t.Fatalf("user %s exists before it was requested through metadata", newAccountUser)
+	}
+	t.Cleanup(func() {
+		if out, err := utils.RunPowershellCmd(fmt.Sprintf("net user %s /delete", newAccountUser)); err != nil {
+			t.Logf("could not delete user %s: %v %s", newAccountUser, err, out.Stderr)
+		}
+	})
+	ctx := utils.Context(t)
+	client, err := daisyCompute.NewClient(ctx)
+	if err != nil {
+		t.Fatalf("Error creating compute service: %v", err)
+	}
+
+	t.Logf("Requesting new account %q through metadata", newAccountUser)
+	password, err := resetPassword(client, t, newAccountUser)
+	if err != nil {
+		t.Fatalf("account creation failed: error %v", err)
+	}
+	// wait for guest agent to update, since it can take up to a minute
+	time.Sleep(time.Minute)
+
+	state := verifyPowershellCmd(t, fmt.Sprintf("Get-LocalUser -Name %s | Format-List Name,Enabled,PasswordRequired,PasswordLastSet,PasswordExpires,LastLogon", newAccountUser))
+	t.Logf("account state:\n%s", strings.TrimSpace(state))
+	enabled := verifyPowershellCmd(t, fmt.Sprintf("(Get-LocalUser -Name %s).Enabled", newAccountUser))
+	if strings.TrimSpace(enabled) != "True" {
+		t.Errorf("account %s is not enabled", newAccountUser)
+	}
+	for _, group := range newAccountGroups {
+		members := verifyPowershellCmd(t, fmt.Sprintf("Get-LocalGroupMember -Group %s | ForEach-Object { Write-Output $_.Name }", group))
+		found := false
+		for _, member := range strings.Fields(members) {
+			if strings.EqualFold(member, newAccountUser) || strings.HasSuffix(strings.ToLower(member), `\`+newAccountUser) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("account %s is not a member of %s, members are: %s", newAccountUser, group, strings.Join(strings.Fields(members), ", "))
+		}
+	}
+	verificationCmd := fmt.Sprintf("Start-Process -Credential (New-Object System.Management.Automation.PSCredential(\"%s\", ('%s' | ConvertTo-SecureString -AsPlainText -Force))) -WorkingDirectory C:\\Windows\\System32 -FilePath cmd.exe", newAccountUser, password)
+	// The process "Credential" in powershell does not print anything on success
+	verifyPowershellCmd(t, verificationCmd)
+}
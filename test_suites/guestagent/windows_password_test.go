@@ -50,7 +50,7 @@ func getInstanceMetadata(client daisyCompute.Client, instance, zone, project str
 	return ins.Metadata, nil
 }
 
-func generateKey(priv *rsa.PublicKey) (*windowsKeyJSON, error) {
+func generateKey(priv *rsa.PublicKey, username string) (*windowsKeyJSON, error) {
 	bs := make([]byte, 4)
 	binary.BigEndian.PutUint32(bs, uint32(priv.E))
 
@@ -60,7 +60,7 @@ func generateKey(priv *rsa.PublicKey) (*windowsKeyJSON, error) {
 		// AQAB vs AQABAA==, both are decoded as 65537.
 		Exponent: base64.StdEncoding.EncodeToString(bs),
 		Modulus:  base64.StdEncoding.EncodeToString(priv.N.Bytes()),
-		UserName: user,
+		UserName: username,
 	}, nil
 }
 
@@ -103,7 +103,7 @@ func decryptPassword(priv *rsa.PrivateKey, ep string) (string, error) {
 	return string(pwd), nil
 }
 
-func resetPassword(client daisyCompute.Client, t *testing.T) (string, error) {
+func resetPassword(client daisyCompute.Client, t *testing.T, username string) (string, error) {
 	ctx := utils.Context(t)
 	instanceName, err := utils.GetInstanceName(ctx)
 	if err != nil {
@@ -123,7 +123,7 @@ func resetPassword(client daisyCompute.Client, t *testing.T) (string, error) {
 		return "", err
 	}
 
-	winKey, err := generateKey(&key.PublicKey)
+	winKey, err := generateKey(&key.PublicKey, username)
 	if err != nil {
 		return "", err
 	}
@@ -202,7 +202,7 @@ func TestWindowsPasswordReset(t *testing.T) {
 	}
 
 	t.Logf("Resetting password on current instance for user %q\n", user)
-	decryptedPassword, err := resetPassword(client, t)
+	decryptedPassword, err := resetPassword(client, t, user)
 	if err != nil {
 		t.Fatalf("reset password failed: error %v", err)
 	}
@@ -0,0 +1,172 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostnamevalidation
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	resolvConf = "/etc/resolv.conf"
+	// metadataResolver is the DNS resolver provided by the metadata server.
+	metadataResolver = "169.254.169.254"
+	// resolvedUpstream is where systemd-resolved writes its upstream
+	// nameservers when /etc/resolv.conf points at its stub resolver.
+	resolvedUpstream = "/run/systemd/resolve/resolv.conf"
+)
+
+// resolvConfManager identifies a component that writes /etc/resolv.conf,
+// either by where the file links to or by the header it writes.
+type resolvConfManager struct {
+	name   string
+	target string
+	header string
+}
+
+var resolvConfManagers = []resolvConfManager{
+	{name: "systemd-resolved", target: "/run/systemd/resolve/", header: "This is /run/systemd/resolve/"},
+	{name: "NetworkManager", target: "/run/NetworkManager/", header: "Generated by NetworkManager"},
+	{name: "netconfig", target: "/run/netconfig/", header: "/etc/resolv.conf is a symlink to /run/netconfig/resolv.conf"},
+	{name: "resolvconf", target: "/run/resolvconf/", header: "Dynamic resolv.conf(5) file for glibc resolver(3) generated by resolvconf(8)"},
+	{name: "dhclient", header: "generated by /usr/sbin/dhclient-script"},
+}
+
+// expectedResolvConfManagers is which manager each image is expected to
+// use, by image name substring. Images not listed may use any single manager.
+var expectedResolvConfManagers = map[string]string{
+	"ubuntu":      "systemd-resolved",
+	"cos":         "systemd-resolved",
+	"rhel":        "NetworkManager",
+	"centos":      "NetworkManager",
+	"rocky-linux": "NetworkManager",
+	"almalinux":   "NetworkManager",
+	"fedora":      "NetworkManager",
+	"sles":        "netconfig",
+	"opensuse":    "netconfig",
+}
+
+// resolvConfNameservers returns the nameservers listed in a resolv.conf.
+func resolvConfNameservers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var nameservers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			nameservers = append(nameservers, fields[1])
+		}
+	}
+	return nameservers, scanner.Err()
+}
+
+// lookupWith resolves host using only the given nameserver.
+func lookupWith(ctx context.Context, nameserver, host string) ([]string, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(nameserver, "53"))
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return r.LookupHost(ctx, host)
+}
+
+// TestResolvConfManagement tests that /etc/resolv.conf is managed by the
+// component the image is expected to use and points at the metadata server.
+func TestResolvConfManagement(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	image, err := utils.GetMetadata(ctx, "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata: %v", err)
+	}
+
+	target, err := filepath.EvalSymlinks(resolvConf)
+	if err != nil {
+		t.Fatalf("couldn't resolve %s: %v", resolvConf, err)
+	}
+	content, err := os.ReadFile(resolvConf)
+	if err != nil {
+		t.Fatalf("couldn't read %s: %v", resolvConf, err)
+	}
+	var managers []string
+	for _, m := range resolvConfManagers {
+		if (m.target != "" && strings.HasPrefix(target, m.target)) || strings.Contains(string(content), m.header) {
+			managers = append(managers, m.name)
+		}
+	}
+	switch len(managers) {
+	case 0:
+		t.Errorf("couldn't determine what manages %s (links to %s)", resolvConf, target)
+	case 1:
+		t.Logf("%s is managed by %s (links to %s)", resolvConf, managers[0], target)
+	default:
+		t.Errorf("%s is claimed by several managers: %v (links to %s)", resolvConf, managers, target)
+	}
+	for substr, want := range expectedResolvConfManagers {
+		if strings.Contains(image, substr) && (len(managers) != 1 || managers[0] != want) {
+			t.Errorf("%s is managed by %v, want %s", resolvConf, managers, want)
+		}
+	}
+
+	nameservers, err := resolvConfNameservers(resolvConf)
+	if err != nil {
+		t.Fatalf("couldn't parse %s: %v", resolvConf, err)
+	}
+	t.Logf("nameservers: %v", nameservers)
+	// systemd-resolved's stub listens on loopback and forwards to the
+	// nameservers it writes to resolvedUpstream.
+	for _, ns := range nameservers {
+		if ip := net.ParseIP(ns); ip != nil && ip.IsLoopback() {
+			if nameservers, err = resolvConfNameservers(resolvedUpstream); err != nil {
+				t.Fatalf("%s uses a local stub resolver, but couldn't read its upstream nameservers: %v", resolvConf, err)
+			}
+			t.Logf("upstream nameservers: %v", nameservers)
+			break
+		}
+	}
+	found := false
+	for _, ns := range nameservers {
+		if ns == metadataResolver {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("nameservers %v do not include the metadata server %s", nameservers, metadataResolver)
+	}
+
+	// Resolve a name that isn't in /etc/hosts to exercise DNS itself.
+	addrs, err := lookupWith(ctx, metadataResolver, "www.googleapis.com")
+	if err != nil {
+		t.Errorf("couldn't resolve www.googleapis.com through %s: %v", metadataResolver, err)
+	} else {
+		t.Logf("www.googleapis.com resolves to %v through %s", addrs, metadataResolver)
+	}
+}
@@ -29,7 +29,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	if err != nil {
 		return err
 	}
-	vm1.RunTests("TestHostname|TestFQDN|TestHostKeysGeneratedOnce|TestHostsFile")
+	vm1.RunTests("TestHostname|TestFQDN|TestHostKeysGeneratedOnce|TestHostsFile|TestResolvConfManagement")
 	// custom host name test not yet implemented for windows
 	if !utils.HasFeature(t.Image, "WINDOWS") {
 		vm2, err := t.CreateTestVM("vm2.custom.domain")
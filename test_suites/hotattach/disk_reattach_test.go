@@ -0,0 +1,288 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotattach
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	reattachMarker = "/var/cit-disk-reattach"
+	// reattachTimeout is how long each side waits for the other at each
+	// stop.
+	reattachTimeout = 10 * time.Minute
+	// reattachDataSize is the amount of data written to the start of the
+	// disk and checked after it is reattached.
+	reattachDataSize = 1 << 20
+)
+
+// Phases of TestDiskReattachAfterStop, one per boot.
+const (
+	reattachAttached = iota
+	reattachDetached
+	reattachReattached
+)
+
+// reattachState is carried across boots by TestDiskReattachAfterStop. Results
+// of intermediate boots are never uploaded, so their failures are recorded
+// here and reported on the last boot.
+type reattachState struct {
+	Phase int `json:"phase"`
+	// Device is the kernel device the disk was first attached as.
+	Device string `json:"device"`
+	// Sum is the SHA-256 of the data written to the disk.
+	Sum      string   `json:"sum"`
+	Failures []string `json:"failures"`
+}
+
+// diskSum returns the SHA-256 of the first reattachDataSize bytes of device.
+func diskSum(device string) (string, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, reattachDataSize); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeDiskData writes random data to the start of device and returns its
+// SHA-256.
+func writeDiskData(device string) (string, error) {
+	data := make([]byte, reattachDataSize)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(device, os.O_WRONLY|os.O_SYNC, 0)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// saveReattachState records state and signals the reattacher that the
+// instance is ready to be stopped.
+func saveReattachState(ctx context.Context, state reattachState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reattachMarker, data, 0644); err != nil {
+		return err
+	}
+	return utils.PutMetadata(ctx, path.Join("instance", "guest-attributes", reattachNamespace, fmt.Sprintf("phase%d", state.Phase)), "ready")
+}
+
+// TestDiskReattachAfterStop validates that a data disk detached while the
+// instance is stopped is gone on the next boot, and that once reattached
+// while stopped it comes back under the same name with its data intact.
+// TestDiskReattacher stops the instance and detaches and reattaches the disk.
+func TestDiskReattachAfterStop(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	diskName, err := utils.GetMetadata(ctx, "instance", "attributes", "reattach-disk-name")
+	if err != nil {
+		t.Skip("no disk to reattach is set in metadata")
+	}
+	link := "/dev/disk/by-id/google-" + diskName
+
+	var state reattachState
+	if data, err := os.ReadFile(reattachMarker); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Fatalf("could not parse reattach state: %v", err)
+		}
+		state.Phase++
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("could not read reattach state: %v", err)
+	}
+
+	switch state.Phase {
+	case reattachAttached:
+		if state.Device, err = filepath.EvalSymlinks(link); err != nil {
+			t.Fatalf("disk %s is not attached: %v", diskName, err)
+		}
+		if state.Sum, err = writeDiskData(state.Device); err != nil {
+			t.Fatalf("could not write to %s: %v", state.Device, err)
+		}
+		t.Logf("wrote %d bytes with SHA-256 %s to %s (%s)", reattachDataSize, state.Sum, link, state.Device)
+	case reattachDetached:
+		if _, err := os.Lstat(link); err == nil {
+			state.Failures = append(state.Failures, fmt.Sprintf("%s still exists after the disk was detached", link))
+		}
+	case reattachReattached:
+		device, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			t.Fatalf("%s is missing after the disk was reattached: %v", link, err)
+		}
+		t.Logf("%s was %s when first attached and is %s after reattaching", link, state.Device, device)
+		sum, err := diskSum(device)
+		switch {
+		case err != nil:
+			t.Errorf("could not read %s after reattaching: %v", device, err)
+		case sum != state.Sum:
+			t.Errorf("data on %s changed across the detach and reattach: SHA-256 %s, want %s", device, sum, state.Sum)
+		}
+		for _, f := range state.Failures {
+			t.Error(f)
+		}
+		return
+	default:
+		t.Fatalf("unexpected reattach phase %d", state.Phase)
+	}
+
+	if err := saveReattachState(ctx, state); err != nil {
+		t.Fatalf("phase %d: could not signal readiness to be stopped: %v", state.Phase, err)
+	}
+	// The reattacher stops the instance, so this only returns if it never
+	// did.
+	time.Sleep(reattachTimeout)
+	t.Fatalf("phase %d: instance was not stopped within %v", state.Phase, reattachTimeout)
+}
+
+// stopInstance stops an instance, runs change while it is stopped, and starts
+// it again.
+func stopInstance(ctx context.Context, client *compute.InstancesClient, prj, zone, instance string, change func() error) error {
+	stop, err := client.Stop(ctx, &computepb.StopInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err == nil {
+		err = stop.Wait(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("could not stop %s: %v", instance, err)
+	}
+	changeErr := change()
+	start, err := client.Start(ctx, &computepb.StartInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err == nil {
+		err = start.Wait(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("could not start %s: %v", instance, err)
+	}
+	return changeErr
+}
+
+// TestDiskReattacher detaches the data disk of the TestDiskReattachAfterStop
+// instance while it is stopped, and reattaches it while it is stopped again.
+func TestDiskReattacher(t *testing.T) {
+	ctx := utils.Context(t)
+	diskName, err := utils.GetMetadata(ctx, "instance", "attributes", "reattach-disk-name")
+	if err != nil {
+		t.Skip("no disk to reattach is set in metadata")
+	}
+	instance, err := utils.GetRealVMName(reattachVM)
+	if err != nil {
+		t.Fatalf("could not get name of instance to stop: %v", err)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	waitReady := func(phase int) {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < reattachTimeout; time.Sleep(10 * time.Second) {
+			_, err := client.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+				Project:     prj,
+				Zone:        zone,
+				Instance:    instance,
+				VariableKey: proto.String(fmt.Sprintf("%s/phase%d", reattachNamespace, phase)),
+			})
+			if err == nil {
+				return
+			}
+		}
+		t.Fatalf("phase %d: %s did not signal it was ready within %v", phase, instance, reattachTimeout)
+	}
+
+	waitReady(reattachAttached)
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err != nil {
+		t.Fatalf("could not get %s: %v", instance, err)
+	}
+	var disk *computepb.AttachedDisk
+	for _, d := range inst.GetDisks() {
+		if d.GetDeviceName() == diskName {
+			disk = d
+		}
+	}
+	if disk == nil {
+		t.Fatalf("%s has no disk with device name %s", instance, diskName)
+	}
+	detached := false
+	// Leave the disk attached however the test ends, so it is deleted with
+	// the instance.
+	t.Cleanup(func() {
+		if detached {
+			if err := waitAttachDiskComplete(ctx, disk, prj, instance, zone); err != nil {
+				t.Errorf("could not reattach %s: %v", diskName, err)
+			}
+		}
+	})
+	err = stopInstance(ctx, client, prj, zone, instance, func() error {
+		if err := waitDetachDiskComplete(ctx, diskName, prj, instance, zone); err != nil {
+			return err
+		}
+		detached = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("detach: %v", err)
+	}
+	t.Logf("detached %s from %s while stopped", diskName, instance)
+
+	waitReady(reattachDetached)
+	err = stopInstance(ctx, client, prj, zone, instance, func() error {
+		if err := waitAttachDiskComplete(ctx, disk, prj, instance, zone); err != nil {
+			return err
+		}
+		detached = false
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reattach: %v", err)
+	}
+	t.Logf("reattached %s to %s while stopped", diskName, instance)
+}
@@ -27,12 +27,17 @@ import (
 var Name = "hotattach"
 
 const (
-	bootDiskSizeGB = 10
-
 	// the path to write the file on linux
 	linuxMountPath          = "/mnt/disks/hotattach"
 	mkfsCmd                 = "mkfs.ext4"
 	windowsMountDriveLetter = "F"
+
+	// reattachVM is the VM whose data disk is detached and reattached while
+	// it is stopped by TestDiskReattacher.
+	reattachVM = "reattachstop"
+	// reattachNamespace is the guest attribute namespace reattachVM uses to
+	// signal it is ready to be stopped.
+	reattachNamespace = "citDiskReattach"
 )
 
 // TestSetup sets up the test workflow.
@@ -40,19 +45,41 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	hotattachInst := &daisy.Instance{}
 	hotattachInst.Scopes = append(hotattachInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
 
-	hotattach, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "reattachPDBalanced", Type: imagetest.PdBalanced, SizeGb: bootDiskSizeGB}, {Name: "hotattachmount", Type: imagetest.PdBalanced, SizeGb: 30}}, hotattachInst)
+	hotattach, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "reattachPDBalanced", Type: imagetest.PdBalanced}, {Name: "hotattachmount", Type: imagetest.PdBalanced, SizeGb: 30}}, hotattachInst)
 	if err != nil {
 		return err
 	}
 	hotattach.AddMetadata("hotattach-disk-name", "hotattachmount")
 	hotattach.RunTests("TestFileHotAttach")
 
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		reattachInst := &daisy.Instance{}
+		reattachInst.Name = reattachVM
+		reattach, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: reattachInst.Name}, {Name: "reattachstopdata", Type: imagetest.PdBalanced, SizeGb: 10}}, reattachInst)
+		if err != nil {
+			return err
+		}
+		reattach.AddMetadata("enable-guest-attributes", "TRUE")
+		reattach.AddMetadata("reattach-disk-name", "reattachstopdata")
+		reattach.RunTests("TestDiskReattachAfterStop")
+
+		reattacherInst := &daisy.Instance{}
+		reattacherInst.Name = "reattacher"
+		reattacherInst.Scopes = append(reattacherInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+		reattacher, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: reattacherInst.Name}}, reattacherInst)
+		if err != nil {
+			return err
+		}
+		reattacher.AddMetadata("reattach-disk-name", "reattachstopdata")
+		reattacher.RunTests("TestDiskReattacher")
+	}
+
 	if t.Image.Architecture != "ARM64" && utils.HasFeature(t.Image, "GVNIC") {
 		lssdMountInst := &daisy.Instance{}
 		lssdMountInst.Zone = "us-east4-b"
 		lssdMountInst.MachineType = "c3-standard-8-lssd"
 
-		lssdMount, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Zone: "us-east4-b", Name: "remountLSSD", Type: imagetest.PdBalanced, SizeGb: bootDiskSizeGB}}, lssdMountInst)
+		lssdMount, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Zone: "us-east4-b", Name: "remountLSSD", Type: imagetest.PdBalanced}}, lssdMountInst)
 		if err != nil {
 			return err
 		}
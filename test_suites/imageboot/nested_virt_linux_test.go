@@ -0,0 +1,96 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageboot
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// ioctls from linux/kvm.h.
+const (
+	kvmGetAPIVersion = 0xAE00
+	kvmCreateVM      = 0xAE01
+	// kvmAPIVersion is the only stable KVM API version.
+	kvmAPIVersion = 12
+)
+
+// virtualizationFlag returns the CPU flag advertising hardware virtualization,
+// or an empty string if there is none.
+func virtualizationFlag(t *testing.T) string {
+	t.Helper()
+	cpuinfo, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		t.Fatalf("could not read cpuinfo: %v", err)
+	}
+	for _, line := range strings.Split(string(cpuinfo), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) != "flags" {
+			continue
+		}
+		for _, flag := range strings.Fields(value) {
+			if flag == "vmx" || flag == "svm" {
+				return flag
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// TestNestedVirt validates that KVM can create virtual machines on instances
+// with nested virtualization enabled.
+func TestNestedVirt(t *testing.T) {
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "nested-virtualization"); err != nil || enabled != "true" {
+		t.Skip("nested virtualization is not enabled on this instance")
+	}
+	flag := virtualizationFlag(t)
+	if flag == "" {
+		t.Fatal("CPU does not expose vmx or svm with nested virtualization enabled")
+	}
+	if _, err := os.Stat("/dev/kvm"); os.IsNotExist(err) {
+		module := "kvm_intel"
+		if flag == "svm" {
+			module = "kvm_amd"
+		}
+		if out, err := exec.Command("modprobe", module).CombinedOutput(); err != nil {
+			t.Fatalf("/dev/kvm is missing and %s could not be loaded: %v %s", module, err, out)
+		}
+	}
+	kvm, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("could not open /dev/kvm: %v", err)
+	}
+	defer kvm.Close()
+	version, _, errno := syscall.Syscall(syscall.SYS_IOCTL, kvm.Fd(), kvmGetAPIVersion, 0)
+	if errno != 0 {
+		t.Fatalf("KVM_GET_API_VERSION failed: %v", errno)
+	}
+	if version != kvmAPIVersion {
+		t.Fatalf("KVM API version is %d, want %d", version, kvmAPIVersion)
+	}
+	vmFd, _, errno := syscall.Syscall(syscall.SYS_IOCTL, kvm.Fd(), kvmCreateVM, 0)
+	if errno != 0 {
+		t.Fatalf("KVM is present but KVM_CREATE_VM failed: %v", errno)
+	}
+	syscall.Close(int(vmFd))
+	t.Logf("KVM is usable: CPU exposes %s and KVM API version %d created a VM", flag, version)
+}
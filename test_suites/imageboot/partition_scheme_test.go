@@ -0,0 +1,170 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageboot
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	espTypeGUID      = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+	biosBootTypeGUID = "21686148-6449-6e61-6874-656564454649"
+)
+
+var lsblkPairRe = regexp.MustCompile(`([A-Z-]+)="([^"]*)"`)
+
+// bootPartition is a partition of the boot disk.
+type bootPartition struct {
+	name   string
+	typ    string
+	fstype string
+}
+
+// linuxPartitionScheme returns the partition table type of the disk holding
+// the root filesystem, and its partitions.
+func linuxPartitionScheme(t *testing.T) (string, string, []bootPartition) {
+	t.Helper()
+	out, err := exec.Command("findmnt", "-n", "-o", "SOURCE", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem: %v", err)
+	}
+	// List the root device and everything it is built on, ending with the disk.
+	out, err = exec.Command("lsblk", "-s", "-P", "-o", "NAME,TYPE", strings.TrimSpace(string(out))).Output()
+	if err != nil {
+		t.Fatalf("could not find boot disk: %v", err)
+	}
+	var disk string
+	for _, m := range regexp.MustCompile(`NAME="([^"]*)" TYPE="disk"`).FindAllStringSubmatch(string(out), -1) {
+		disk = m[1]
+	}
+	if disk == "" {
+		t.Fatalf("could not find the disk under the root filesystem in %q", out)
+	}
+	out, err = exec.Command("lsblk", "-P", "-o", "NAME,TYPE,PTTYPE,PARTTYPE,FSTYPE", "/dev/"+disk).Output()
+	if err != nil {
+		t.Fatalf("could not list partitions of %s: %v", disk, err)
+	}
+	var ptType string
+	var parts []bootPartition
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := make(map[string]string)
+		for _, m := range lsblkPairRe.FindAllStringSubmatch(line, -1) {
+			fields[m[1]] = m[2]
+		}
+		switch fields["TYPE"] {
+		case "disk":
+			ptType = fields["PTTYPE"]
+		case "part":
+			parts = append(parts, bootPartition{name: fields["NAME"], typ: strings.ToLower(fields["PARTTYPE"]), fstype: fields["FSTYPE"]})
+		}
+	}
+	if utils.CheckLinuxCmdExists("sgdisk") && ptType == "gpt" {
+		if table, err := exec.Command("sgdisk", "-p", "/dev/"+disk).CombinedOutput(); err == nil {
+			t.Logf("sgdisk -p /dev/%s:\n%s", disk, table)
+		}
+	}
+	return disk, ptType, parts
+}
+
+// windowsPartitionScheme returns the partition style of the disk holding C:,
+// and its partitions.
+func windowsPartitionScheme(t *testing.T) (string, string, []bootPartition) {
+	t.Helper()
+	number, err := utils.RunPowershellCmd("(Get-Partition -DriveLetter C).DiskNumber")
+	if err != nil {
+		t.Fatalf("could not find boot disk: %v %s", err, number.Stderr)
+	}
+	disk := strings.TrimSpace(number.Stdout)
+	style, err := utils.RunPowershellCmd(fmt.Sprintf("(Get-Disk -Number %s).PartitionStyle", disk))
+	if err != nil {
+		t.Fatalf("could not get partition style of disk %s: %v %s", disk, err, style.Stderr)
+	}
+	out, err := utils.RunPowershellCmd(fmt.Sprintf(`Get-Partition -DiskNumber %s | ForEach-Object { "$($_.PartitionNumber) $($_.GptType)$($_.MbrType) $((Get-Volume -Partition $_ -ErrorAction SilentlyContinue).FileSystem)" }`, disk))
+	if err != nil {
+		t.Fatalf("could not list partitions of disk %s: %v %s", disk, err, out.Stderr)
+	}
+	var parts []bootPartition
+	for _, line := range strings.Split(strings.TrimSpace(out.Stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		p := bootPartition{name: fields[0], typ: strings.Trim(strings.ToLower(fields[1]), "{}")}
+		if len(fields) > 2 {
+			p.fstype = strings.ToLower(fields[2])
+		}
+		parts = append(parts, p)
+	}
+	return disk, strings.ToLower(strings.TrimSpace(style.Stdout)), parts
+}
+
+// TestPartitionScheme validates that the boot disk is partitioned as its
+// firmware type requires: GPT with an EFI system partition for UEFI images.
+func TestPartitionScheme(t *testing.T) {
+	firmware, err := utils.GetMetadata(utils.Context(t), "instance", "attributes", "firmware")
+	if err != nil {
+		t.Skip("firmware type is not set in metadata")
+	}
+	var disk, scheme string
+	var parts []bootPartition
+	if utils.IsWindows() {
+		disk, scheme, parts = windowsPartitionScheme(t)
+	} else {
+		disk, scheme, parts = linuxPartitionScheme(t)
+	}
+	// lsblk reports MBR as dos.
+	if scheme == "dos" {
+		scheme = "mbr"
+	}
+	t.Logf("boot disk %s of %s image uses %s partitioning", disk, firmware, scheme)
+	var esp, biosBoot bool
+	for _, p := range parts {
+		t.Logf("partition %s: type %s, filesystem %s", p.name, p.typ, p.fstype)
+		switch p.typ {
+		case espTypeGUID:
+			esp = true
+			if p.fstype != "" && p.fstype != "vfat" && p.fstype != "fat32" {
+				t.Errorf("EFI system partition %s has filesystem %s, want FAT", p.name, p.fstype)
+			}
+		case biosBootTypeGUID:
+			biosBoot = true
+		}
+	}
+
+	switch firmware {
+	case "uefi":
+		if scheme != "gpt" {
+			t.Errorf("boot disk uses %s partitioning, UEFI images must use GPT", scheme)
+		}
+		if !esp {
+			t.Errorf("boot disk has no EFI system partition with type %s", espTypeGUID)
+		}
+	case "bios":
+		if scheme == "gpt" && !biosBoot && !utils.IsWindows() {
+			t.Errorf("BIOS boot disk uses GPT without a BIOS boot partition with type %s", biosBootTypeGUID)
+		}
+		if scheme != "gpt" && scheme != "mbr" {
+			t.Errorf("boot disk uses unknown partitioning %q", scheme)
+		}
+	default:
+		t.Fatalf("unknown firmware type %q in metadata", firmware)
+	}
+}
@@ -23,6 +23,8 @@ import (
 
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	daisy "github.com/GoogleCloudPlatform/compute-daisy"
+	"google.golang.org/api/compute/v1"
 )
 
 // Name is the name of the test package. It must match the directory name.
@@ -61,7 +63,25 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		return err
 	}
 	vm3.AddMetadata("start-time", strconv.Itoa(time.Now().Second()))
-	vm3.RunTests("TestStartTime|TestBootTime")
+	if utils.HasFeature(t.Image, "UEFI_COMPATIBLE") {
+		vm3.AddMetadata("firmware", "uefi")
+	} else {
+		vm3.AddMetadata("firmware", "bios")
+	}
+	vm3.RunTests("TestStartTime|TestBootTime|TestPartitionScheme")
+
+	// Nested virtualization is only available on Intel x86 machine types.
+	if !utils.HasFeature(t.Image, "WINDOWS") && t.Image.Architecture != "ARM64" {
+		nestedInst := &daisy.Instance{}
+		nestedInst.AdvancedMachineFeatures = &compute.AdvancedMachineFeatures{EnableNestedVirtualization: true}
+		nestedInst.Metadata = map[string]string{"nested-virtualization": "true"}
+		nestedVM, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "nestedvirt"}}, nestedInst)
+		if err != nil {
+			return err
+		}
+		nestedVM.ForceMachineType("n2-standard-2")
+		nestedVM.RunTests("TestNestedVirt")
+	}
 
 	for _, r := range sbUnsupported {
 		if r.MatchString(t.Image.Name) {
@@ -0,0 +1,158 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// abSlots are the update slots of an A/B partitioned image, such as COS. Each
+// slot has a kernel partition KERN-<slot> and a root partition ROOT-<slot>.
+var abSlots = []string{"A", "B"}
+
+var lsblkPairRe = regexp.MustCompile(`([A-Z]+)="([^"]*)"`)
+
+// labeledPartition is a GPT partition with a partition label.
+type labeledPartition struct {
+	name   string
+	disk   string
+	number int
+	size   int64
+}
+
+// labeledPartitions returns the partitions with a GPT partition label, keyed
+// by label.
+func labeledPartitions(t *testing.T) map[string]labeledPartition {
+	t.Helper()
+	out, err := exec.Command("lsblk", "-b", "-P", "-o", "NAME,PKNAME,PARTLABEL,SIZE").Output()
+	if err != nil {
+		t.Fatalf("could not list partitions: %v", err)
+	}
+	parts := make(map[string]labeledPartition)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := make(map[string]string)
+		for _, m := range lsblkPairRe.FindAllStringSubmatch(line, -1) {
+			fields[m[1]] = m[2]
+		}
+		if fields["PARTLABEL"] == "" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields["SIZE"], 10, 64)
+		number, _ := os.ReadFile(filepath.Join("/sys/class/block", fields["NAME"], "partition"))
+		n, _ := strconv.Atoi(strings.TrimSpace(string(number)))
+		parts[fields["PARTLABEL"]] = labeledPartition{name: fields["NAME"], disk: fields["PKNAME"], number: n, size: size}
+	}
+	return parts
+}
+
+// rootPartition returns the name of the partition backing the root
+// filesystem, looking through a device mapper target such as dm-verity.
+func rootPartition(t *testing.T) string {
+	t.Helper()
+	out, err := exec.Command("findmnt", "-n", "-o", "SOURCE", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem: %v", err)
+	}
+	dev, err := filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("could not resolve root device: %v", err)
+	}
+	name := filepath.Base(dev)
+	slaves, err := os.ReadDir(filepath.Join("/sys/class/block", name, "slaves"))
+	if err == nil && len(slaves) > 0 {
+		name = slaves[0].Name()
+	}
+	return name
+}
+
+// cgptAttribute returns a GPT attribute of a partition, where flag is -P for
+// priority, -T for tries, or -S for successful.
+func cgptAttribute(p labeledPartition, flag string) (int, error) {
+	out, err := exec.Command("cgpt", "show", "-i", strconv.Itoa(p.number), flag, "/dev/"+p.disk).Output()
+	if err != nil {
+		return 0, fmt.Errorf("cgpt show %s of %s: %v", flag, p.name, err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// TestMultiBootPartition validates the slot layout of images with A/B update
+// partitions: the root filesystem is in the slot the bootloader prefers, and
+// the other slot is present with a matching size.
+func TestMultiBootPartition(t *testing.T) {
+	utils.LinuxOnly(t)
+	parts := labeledPartitions(t)
+	for _, slot := range abSlots {
+		for _, kind := range []string{"KERN-", "ROOT-"} {
+			if _, ok := parts[kind+slot]; !ok {
+				t.Skipf("image does not use A/B partitioning, no %s%s partition", kind, slot)
+			}
+		}
+	}
+	root := rootPartition(t)
+	var active, inactive string
+	for _, slot := range abSlots {
+		p := parts["ROOT-"+slot]
+		t.Logf("slot %s: KERN-%s is %s, ROOT-%s is %s (%d bytes)", slot, slot, parts["KERN-"+slot].name, slot, p.name, p.size)
+		if p.name == root {
+			active = slot
+		} else {
+			inactive = slot
+		}
+	}
+	if active == "" {
+		t.Fatalf("root filesystem is on %s, which is not in any slot", root)
+	}
+	t.Logf("active slot is %s, inactive slot is %s", active, inactive)
+
+	if activeSize, inactiveSize := parts["ROOT-"+active].size, parts["ROOT-"+inactive].size; inactiveSize != activeSize {
+		t.Errorf("ROOT-%s is %d bytes, want the same size as ROOT-%s (%d bytes)", inactive, inactiveSize, active, activeSize)
+	}
+	if parts["KERN-"+active].disk != parts["KERN-"+inactive].disk {
+		t.Errorf("KERN-%s and KERN-%s are on different disks", active, inactive)
+	}
+
+	if !utils.CheckLinuxCmdExists("cgpt") {
+		t.Log("cgpt is not installed, not checking slot priorities")
+		return
+	}
+	activePriority, err := cgptAttribute(parts["KERN-"+active], "-P")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inactivePriority, err := cgptAttribute(parts["KERN-"+inactive], "-P")
+	if err != nil {
+		t.Fatal(err)
+	}
+	successful, err := cgptAttribute(parts["KERN-"+active], "-S")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("KERN-%s priority %d successful %d, KERN-%s priority %d", active, activePriority, successful, inactive, inactivePriority)
+	if activePriority <= inactivePriority {
+		t.Errorf("bootloader prefers KERN-%s (priority %d) over the running slot KERN-%s (priority %d)", inactive, inactivePriority, active, activePriority)
+	}
+	if successful != 1 {
+		t.Errorf("running slot KERN-%s is not marked as successfully booted", active)
+	}
+}
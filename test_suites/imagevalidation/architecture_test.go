@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"debug/elf"
+	"debug/pe"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// binaryArchitecture returns the normalized architecture a core system binary
+// was built for.
+func binaryArchitecture() (string, string, error) {
+	if utils.IsWindows() {
+		path := `C:\Windows\System32\cmd.exe`
+		f, err := pe.Open(path)
+		if err != nil {
+			return path, "", fmt.Errorf("could not open %s: %v", path, err)
+		}
+		defer f.Close()
+		switch f.Machine {
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			return path, utils.ArchX86_64, nil
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			return path, utils.ArchARM64, nil
+		}
+		return path, fmt.Sprintf("pe machine %#x", f.Machine), nil
+	}
+	path := "/bin/sh"
+	f, err := elf.Open(path)
+	if err != nil {
+		return path, "", fmt.Errorf("could not open %s: %v", path, err)
+	}
+	defer f.Close()
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return path, utils.ArchX86_64, nil
+	case elf.EM_AARCH64:
+		return path, utils.ArchARM64, nil
+	}
+	return path, f.Machine.String(), nil
+}
+
+// TestArchitecture validates that the guest kernel and userland match the
+// architecture the image declares.
+func TestArchitecture(t *testing.T) {
+	image, err := getImage(utils.Context(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.GetArchitecture() == "" || image.GetArchitecture() == "ARCHITECTURE_UNSPECIFIED" {
+		t.Skipf("image %s does not declare an architecture", image.GetName())
+	}
+	expected := utils.NormalizeArchitecture(image.GetArchitecture())
+	kernel, err := utils.GuestArchitecture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, userland, err := binaryArchitecture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("image %s declares %s, kernel is %s, %s is %s", image.GetName(), expected, kernel, path, userland)
+	if kernel != expected {
+		t.Errorf("kernel architecture is %s, want %s", kernel, expected)
+	}
+	if userland != expected {
+		t.Errorf("%s architecture is %s, want %s", path, userland, expected)
+	}
+}
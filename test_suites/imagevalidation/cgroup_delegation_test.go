@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// userManagerControllers are the controllers user@.service must delegate so
+// rootless container runtimes can limit their containers.
+var userManagerControllers = []string{"memory", "pids"}
+
+// delegateScript runs in a transient service with delegation as an
+// unprivileged user. It moves itself into a new child cgroup so its own cgroup
+// has no processes, then enables the delegated controllers for its children.
+const delegateScript = `set -e
+cg=/sys/fs/cgroup$(cut -d: -f3 /proc/self/cgroup)
+mkdir "$cg/cit-child"
+echo $$ > "$cg/cit-child/cgroup.procs"
+echo "controllers: $(cat "$cg/cgroup.controllers")"
+for c in $(cat "$cg/cgroup.controllers"); do echo "+$c" > "$cg/cgroup.subtree_control"; done
+echo "subtree: $(cat "$cg/cgroup.subtree_control")"
+echo "child: $(cat "$cg/cit-child/cgroup.controllers")"
+`
+
+// userManagerDelegation returns the controllers delegated to user@.service, as
+// set by the last Delegate= line in the unit and its drop-ins.
+func userManagerDelegation() (string, error) {
+	out, err := exec.Command("systemctl", "cat", "user@.service").Output()
+	if err != nil {
+		return "", fmt.Errorf("systemctl cat user@.service failed: %v", err)
+	}
+	delegate := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "Delegate="); ok {
+			delegate = strings.TrimSpace(value)
+		}
+	}
+	return delegate, nil
+}
+
+// TestCgroupDelegation validates that an unprivileged user can manage a
+// delegated cgroup v2 subtree, as rootless container runtimes do.
+func TestCgroupDelegation(t *testing.T) {
+	utils.LinuxOnly(t)
+	if version, _ := cgroupVersion(t); version != cgroupV2 {
+		t.Skipf("image uses cgroup %s", version)
+	}
+
+	delegate, err := userManagerDelegation()
+	if err != nil {
+		t.Fatalf("could not read user manager delegation: %v", err)
+	}
+	t.Logf("user@.service Delegate=%s", delegate)
+	switch delegate {
+	case "", "no", "false", "0", "off":
+		t.Errorf("user@.service does not delegate cgroups")
+	case "yes", "true", "1", "on":
+	default:
+		delegated := strings.Fields(delegate)
+		for _, want := range userManagerControllers {
+			found := false
+			for _, c := range delegated {
+				if c == want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("user@.service does not delegate the %s controller", want)
+			}
+		}
+	}
+
+	unit := fmt.Sprintf("cit-delegation-%d", time.Now().Unix())
+	out, err := exec.Command("systemd-run", "--unit="+unit, "--wait", "--pipe", "--quiet", "-p", "Delegate=yes", "-p", "User=nobody", "sh", "-c", delegateScript).CombinedOutput()
+	t.Logf("delegated cgroup as nobody:\n%s", out)
+	if err != nil {
+		t.Fatalf("could not manage a delegated cgroup as an unprivileged user: %v", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if child, ok := strings.CutPrefix(line, "child: "); ok {
+			for _, want := range userManagerControllers {
+				if !strings.Contains(" "+child+" ", " "+want+" ") {
+					t.Errorf("the %s controller is not available in the delegated child cgroup", want)
+				}
+			}
+			return
+		}
+	}
+	t.Error("delegated cgroup script did not report the child cgroup controllers")
+}
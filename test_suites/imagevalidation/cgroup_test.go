@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	cgroupV1 = "v1"
+	cgroupV2 = "v2"
+)
+
+// expectedCgroupVersions maps image names to the cgroup hierarchy they are
+// expected to mount by default. Images which match none are only reported.
+var expectedCgroupVersions = []struct {
+	image   *regexp.Regexp
+	version string
+}{
+	{regexp.MustCompile(`^debian-(11|12|13)`), cgroupV2},
+	{regexp.MustCompile(`^debian-10`), cgroupV1},
+	{regexp.MustCompile(`^ubuntu-(pro-|minimal-)?(2[2-9]|[3-9][0-9])`), cgroupV2},
+	{regexp.MustCompile(`^ubuntu-(pro-|minimal-)?(1[0-9]|20)`), cgroupV1},
+	{regexp.MustCompile(`^(rhel|centos-stream|rocky-linux|almalinux|oracle-linux)-(9|10)`), cgroupV2},
+	{regexp.MustCompile(`^(rhel|centos|rocky-linux|almalinux|oracle-linux)-[78]`), cgroupV1},
+	{regexp.MustCompile(`^(sles|opensuse-leap)-(12|15)`), cgroupV1},
+	{regexp.MustCompile(`^fedora`), cgroupV2},
+}
+
+// cgroupVersion returns the cgroup hierarchy mounted at /sys/fs/cgroup, and
+// whether a v2 hierarchy is also mounted alongside v1.
+func cgroupVersion(t *testing.T) (version string, hybrid bool) {
+	t.Helper()
+	out, err := exec.Command("stat", "-f", "-c", "%T", "/sys/fs/cgroup").Output()
+	if err != nil {
+		t.Fatalf("could not get filesystem type of /sys/fs/cgroup: %v", err)
+	}
+	if fsType := strings.TrimSpace(string(out)); fsType == "cgroup2fs" {
+		return cgroupV2, false
+	}
+	_, err = os.Stat("/sys/fs/cgroup/unified")
+	return cgroupV1, err == nil
+}
+
+// TestCgroupVersion validates that the image mounts the cgroup hierarchy it
+// is expected to.
+func TestCgroupVersion(t *testing.T) {
+	utils.LinuxOnly(t)
+	id, err := utils.GetImageIdentity(utils.Context(t))
+	if err != nil {
+		t.Fatalf("could not get image identity: %v", err)
+	}
+	name := id.Name
+	version, hybrid := cgroupVersion(t)
+	t.Logf("image %s uses cgroup %s (hybrid: %t)", name, version, hybrid)
+	for _, expected := range expectedCgroupVersions {
+		if !expected.image.MatchString(name) {
+			continue
+		}
+		if version != expected.version {
+			t.Errorf("image %s uses cgroup %s, expected cgroup %s", name, version, expected.version)
+		}
+		return
+	}
+	t.Logf("no expected cgroup version for image %s", name)
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// coreSpecifierRe matches the % specifiers core_pattern expands.
+var coreSpecifierRe = regexp.MustCompile(`%.`)
+
+// coreLimit returns the core file size limit of a process as reported in its
+// limits file.
+func coreLimit(pid int) string {
+	limits, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "limits"))
+	if err != nil {
+		return "unknown"
+	}
+	for _, line := range strings.Split(string(limits), "\n") {
+		if rest, ok := strings.CutPrefix(line, "Max core file size"); ok {
+			return strings.Join(strings.Fields(rest), " ")
+		}
+	}
+	return "unknown"
+}
+
+// TestCoreDumpHandling validates that a crashing process has its core dump
+// handled as kernel.core_pattern says it should be.
+func TestCoreDumpHandling(t *testing.T) {
+	utils.LinuxOnly(t)
+	pattern, err := os.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		t.Fatalf("could not read core_pattern: %v", err)
+	}
+	corePattern := strings.TrimSpace(string(pattern))
+	t.Logf("kernel.core_pattern: %q", corePattern)
+
+	// Crash a child in its own directory so relative patterns and any core
+	// left behind stay out of the way of the rest of the test.
+	dir := t.TempDir()
+	cmd := exec.Command("sh", "-c", "ulimit -c unlimited; exec sleep 60")
+	cmd.Dir = dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("could not start process to crash: %v", err)
+	}
+	pid := cmd.Process.Pid
+	// Let the shell apply the limit and exec.
+	time.Sleep(time.Second)
+	limit := coreLimit(pid)
+	if err := cmd.Process.Signal(syscall.SIGSEGV); err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("could not crash process %d: %v", pid, err)
+	}
+	cmd.Wait()
+	ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGSEGV {
+		t.Fatalf("process %d did not crash, exited with %v", pid, cmd.ProcessState)
+	}
+	t.Logf("crashed process %d with core file size limit %s, dump produced: %t", pid, limit, ws.CoreDump())
+
+	if corePattern == "" || strings.HasPrefix(limit, "0 ") {
+		t.Logf("core dumps are disabled")
+		if ws.CoreDump() {
+			t.Errorf("a core dump was produced although core dumps are disabled")
+		}
+		return
+	}
+	if !ws.CoreDump() {
+		t.Fatalf("no core dump was produced for process %d", pid)
+	}
+
+	handler, piped := strings.CutPrefix(corePattern, "|")
+	switch {
+	case piped && strings.Contains(handler, "systemd-coredump"):
+		if !utils.CheckLinuxCmdExists("coredumpctl") {
+			t.Fatalf("core dumps are piped to systemd-coredump but coredumpctl is not installed")
+		}
+		// systemd-coredump records the dump asynchronously.
+		var out []byte
+		for i := 0; i < 10; i++ {
+			out, err = exec.Command("coredumpctl", "--no-pager", "--no-legend", "list", strconv.Itoa(pid)).Output()
+			if err == nil && len(strings.TrimSpace(string(out))) > 0 {
+				break
+			}
+			time.Sleep(time.Second)
+		}
+		if len(strings.TrimSpace(string(out))) == 0 {
+			t.Fatalf("systemd-coredump did not record the crash of process %d: %v", pid, err)
+		}
+		t.Logf("systemd-coredump recorded: %s", strings.TrimSpace(string(out)))
+	case piped:
+		t.Logf("core dumps are piped to %s", strings.Fields(handler)[0])
+	default:
+		// Match the dump however the pattern expands. core_uses_pid may append
+		// the pid to patterns without one.
+		glob := coreSpecifierRe.ReplaceAllString(corePattern, "*") + "*"
+		if !filepath.IsAbs(glob) {
+			glob = filepath.Join(dir, glob)
+		}
+		matches, _ := filepath.Glob(glob)
+		var found string
+		for _, match := range matches {
+			if fi, err := os.Stat(match); err == nil && !fi.ModTime().Before(start.Add(-time.Second)) {
+				found = match
+				os.Remove(match)
+			}
+		}
+		if found == "" {
+			t.Fatalf("no core file matching %s was written", glob)
+		}
+		t.Logf("core file written to %s", found)
+	}
+}
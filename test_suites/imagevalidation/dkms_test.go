@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	citModuleName   = "cit_hello"
+	citModuleSource = `#include <linux/module.h>
+#include <linux/init.h>
+
+MODULE_LICENSE("GPL");
+MODULE_DESCRIPTION("CIT test module");
+
+static int __init cit_hello_init(void) { pr_info("cit_hello loaded\n"); return 0; }
+static void __exit cit_hello_exit(void) { pr_info("cit_hello unloaded\n"); }
+
+module_init(cit_hello_init);
+module_exit(cit_hello_exit);
+`
+)
+
+// TestDKMS validates that an out of tree kernel module can be built against
+// the running kernel with the shipped headers, and loaded.
+func TestDKMS(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("dkms") {
+		t.Skip("dkms is not installed")
+	}
+	release, err := exec.Command("uname", "-r").Output()
+	if err != nil {
+		t.Fatalf("uname -r failed: %v", err)
+	}
+	kernel := strings.TrimSpace(string(release))
+	build := filepath.Join("/lib/modules", kernel, "build")
+	if _, err := os.Stat(filepath.Join(build, "Makefile")); err != nil {
+		t.Skipf("kernel headers for the running kernel %s are not installed", kernel)
+	}
+	if !utils.CheckLinuxCmdExists("make") || !utils.CheckLinuxCmdExists("gcc") {
+		t.Skip("make or gcc is not installed")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, citModuleName+".c"), []byte(citModuleSource), 0644); err != nil {
+		t.Fatalf("could not write module source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Makefile"), []byte("obj-m += "+citModuleName+".o\n"), 0644); err != nil {
+		t.Fatalf("could not write module Makefile: %v", err)
+	}
+	out, err := exec.Command("make", "-C", build, "M="+dir, "modules").CombinedOutput()
+	if err != nil {
+		t.Fatalf("building module against kernel %s headers failed: %v\n%s", kernel, err, out)
+	}
+	module := filepath.Join(dir, citModuleName+".ko")
+	if _, err := os.Stat(module); err != nil {
+		t.Fatalf("module build succeeded but produced no %s: %v\n%s", module, err, out)
+	}
+
+	// Unsigned modules can't be loaded when the kernel is locked down.
+	if lockdown, err := os.ReadFile("/sys/kernel/security/lockdown"); err == nil && !strings.Contains(string(lockdown), "[none]") {
+		t.Logf("kernel lockdown is %s, not loading unsigned module", strings.TrimSpace(string(lockdown)))
+		return
+	}
+	if out, err := exec.Command("insmod", module).CombinedOutput(); err != nil {
+		t.Fatalf("insmod %s failed: %v\n%s", module, err, out)
+	}
+	if out, err := exec.Command("rmmod", citModuleName).CombinedOutput(); err != nil {
+		t.Errorf("rmmod %s failed: %v\n%s", citModuleName, err, out)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// embeddedScript is a helper script shipped by the guest environment.
+type embeddedScript struct {
+	path string
+	// required scripts must be present. Others are validated when present.
+	required bool
+}
+
+var (
+	// guestEnvironmentExempt matches images which don't ship the guest
+	// environment packages.
+	guestEnvironmentExempt = regexp.MustCompile(`^cos-`)
+	windowsSysprep         = `C:\Program Files\Google\Compute Engine\sysprep`
+	linuxScripts           = []embeddedScript{
+		{path: "/usr/bin/google_set_multiqueue", required: true},
+		{path: "/usr/bin/google_optimize_local_ssd", required: true},
+		{path: "/usr/bin/google_set_hostname"},
+	}
+	windowsScripts = []embeddedScript{
+		{path: filepath.Join(windowsSysprep, "sysprep.ps1"), required: true},
+		{path: filepath.Join(windowsSysprep, "gce_base.psm1"), required: true},
+		{path: filepath.Join(windowsSysprep, "instance_setup.ps1"), required: true},
+		{path: filepath.Join(windowsSysprep, "activate_instance.ps1")},
+	}
+)
+
+// shellInterpreters are interpreters whose scripts can be syntax checked with -n.
+var shellInterpreters = map[string]bool{"sh": true, "bash": true, "dash": true, "ksh": true}
+
+// scriptInterpreter returns the interpreter named by the shebang of path,
+// resolving interpreters run through env.
+func scriptInterpreter(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("could not read first line: %v", err)
+	}
+	shebang, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return "", fmt.Errorf("no shebang line")
+	}
+	fields := strings.Fields(shebang)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty shebang line")
+	}
+	if filepath.Base(fields[0]) == "env" && len(fields) > 1 {
+		return exec.LookPath(fields[1])
+	}
+	return fields[0], nil
+}
+
+func checkLinuxScript(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&0111 == 0 {
+		return fmt.Errorf("not executable, mode is %v", info.Mode())
+	}
+	interpreter, err := scriptInterpreter(path)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckLinuxCmdExists(interpreter) {
+		return fmt.Errorf("interpreter %s does not exist or is not executable", interpreter)
+	}
+	if !shellInterpreters[filepath.Base(interpreter)] {
+		return nil
+	}
+	if out, err := exec.Command(interpreter, "-n", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("syntax check failed: %v\n%s", err, out)
+	}
+	return nil
+}
+
+func checkWindowsScript(path string) error {
+	cmd := fmt.Sprintf(`$errs = $null; [System.Management.Automation.Language.Parser]::ParseFile('%s', [ref]$null, [ref]$errs) | Out-Null; $errs | ForEach-Object { "$($_.Extent.File):$($_.Extent.StartLineNumber): $($_.Message)" }`, path)
+	out, err := utils.RunPowershellCmd(cmd)
+	if err != nil {
+		return fmt.Errorf("could not parse script: %v %s", err, out.Stderr)
+	}
+	if errs := strings.TrimSpace(out.Stdout); errs != "" {
+		return fmt.Errorf("syntax check failed:\n%s", errs)
+	}
+	return nil
+}
+
+// TestEmbeddedScripts validates that the helper scripts shipped in the image
+// are executable and syntactically valid.
+func TestEmbeddedScripts(t *testing.T) {
+	id, err := utils.GetImageIdentity(utils.Context(t))
+	if err != nil {
+		t.Fatalf("could not get image identity: %v", err)
+	}
+	scripts, check := linuxScripts, checkLinuxScript
+	if utils.IsWindows() {
+		scripts, check = windowsScripts, checkWindowsScript
+	}
+	for _, script := range scripts {
+		if _, err := os.Stat(script.path); os.IsNotExist(err) {
+			if script.required && !guestEnvironmentExempt.MatchString(id.Name) {
+				t.Errorf("%s is not present on image %s", script.path, id.Name)
+			}
+			continue
+		}
+		if err := check(script.path); err != nil {
+			t.Errorf("%s: %v", script.path, err)
+		} else {
+			t.Logf("%s is valid", script.path)
+		}
+	}
+}
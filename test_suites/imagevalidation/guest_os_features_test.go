@@ -0,0 +1,196 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// errUnconfirmed is returned by feature checks which cannot tell whether the
+// guest supports a feature.
+var errUnconfirmed = errors.New("could not be confirmed")
+
+// featureCheck confirms the guest supports a guest OS feature. It returns
+// the evidence found, or an error if the guest does not support it.
+type featureCheck func() (string, error)
+
+// guestOSFeatureChecks are the checks for each guest OS feature, by OS.
+// Features without a check are only reported.
+var guestOSFeatureChecks = map[string]struct{ linux, windows featureCheck }{
+	"UEFI_COMPATIBLE": {
+		linux: func() (string, error) {
+			if _, err := os.Stat("/sys/firmware/efi"); err == nil {
+				return "booted with UEFI", nil
+			}
+			return "", fmt.Errorf("instance did not boot with UEFI")
+		},
+		windows: func() (string, error) {
+			out, err := utils.RunPowershellCmd("$env:firmware_type")
+			if err != nil {
+				return "", err
+			}
+			if firmware := strings.TrimSpace(out.Stdout); firmware != "UEFI" {
+				return "", fmt.Errorf("instance booted with %s firmware", firmware)
+			}
+			return "booted with UEFI", nil
+		},
+	},
+	"GVNIC": {
+		linux:   kernelModuleCheck("gve"),
+		windows: googetPackageCheck("google-compute-engine-driver-gvnic"),
+	},
+	"IDPF": {
+		linux: kernelModuleCheck("idpf"),
+	},
+	"VIRTIO_SCSI_MULTIQUEUE": {
+		linux:   kernelModuleCheck("virtio_scsi"),
+		windows: googetPackageCheck("google-compute-engine-driver-vioscsi"),
+	},
+	"SEV_CAPABLE":         {linux: kernelConfigCheck("CONFIG_AMD_MEM_ENCRYPT")},
+	"SEV_LIVE_MIGRATABLE": {linux: kernelConfigCheck("CONFIG_AMD_MEM_ENCRYPT")},
+	"SEV_LIVE_MIGRATABLE_V2": {
+		linux: kernelConfigCheck("CONFIG_AMD_MEM_ENCRYPT"),
+	},
+	"SEV_SNP_CAPABLE": {linux: kernelConfigCheck("CONFIG_AMD_MEM_ENCRYPT")},
+	"TDX_CAPABLE":     {linux: kernelConfigCheck("CONFIG_INTEL_TDX_GUEST")},
+	"WINDOWS": {
+		linux: func() (string, error) {
+			return "", fmt.Errorf("image is not Windows")
+		},
+		windows: func() (string, error) {
+			return "running Windows", nil
+		},
+	},
+}
+
+// kernelModuleCheck returns a check that a kernel module is loaded, built in
+// or available to load.
+func kernelModuleCheck(module string) featureCheck {
+	return func() (string, error) {
+		if _, err := os.Stat("/sys/module/" + module); err == nil {
+			return module + " is loaded", nil
+		}
+		if out, err := exec.Command("modinfo", "-n", module).Output(); err == nil {
+			return module + " is available at " + strings.TrimSpace(string(out)), nil
+		}
+		return "", fmt.Errorf("kernel module %s is not available", module)
+	}
+}
+
+// kernelConfig returns the value of an option in the running kernel's
+// configuration.
+func kernelConfig(option string) (string, error) {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", err
+	}
+	var r io.Reader
+	if f, err := os.Open("/boot/config-" + strings.TrimSpace(string(release))); err == nil {
+		defer f.Close()
+		r = f
+	} else if f, err := os.Open("/proc/config.gz"); err == nil {
+		defer f.Close()
+		if r, err = gzip.NewReader(f); err != nil {
+			return "", err
+		}
+	} else {
+		return "", errUnconfirmed
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), option+"="); ok {
+			return value, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// kernelConfigCheck returns a check that a kernel option is enabled.
+func kernelConfigCheck(option string) featureCheck {
+	return func() (string, error) {
+		value, err := kernelConfig(option)
+		if err != nil {
+			return "", err
+		}
+		if value != "y" {
+			return "", fmt.Errorf("kernel option %s is not enabled", option)
+		}
+		return option + "=y", nil
+	}
+}
+
+// googetPackageCheck returns a check that a googet package is installed.
+func googetPackageCheck(pkg string) featureCheck {
+	return func() (string, error) {
+		out, err := utils.RunPowershellCmd(`C:\ProgramData\GooGet\googet.exe installed ` + pkg)
+		if err != nil || !strings.Contains(out.Stdout, pkg) {
+			return "", fmt.Errorf("package %s is not installed", pkg)
+		}
+		return pkg + " is installed", nil
+	}
+}
+
+// TestGuestOSFeatures validates that the guest supports each guest OS
+// feature the image advertises.
+func TestGuestOSFeatures(t *testing.T) {
+	image, err := getImage(utils.Context(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var features []string
+	advertised := make(map[string]bool)
+	for _, f := range image.GetGuestOsFeatures() {
+		features = append(features, f.GetType())
+		advertised[f.GetType()] = true
+	}
+	sort.Strings(features)
+	t.Logf("image %s advertises guest OS features %v", image.GetName(), features)
+
+	for _, feature := range features {
+		checks := guestOSFeatureChecks[feature]
+		check := checks.linux
+		if utils.IsWindows() {
+			check = checks.windows
+		}
+		if check == nil {
+			t.Logf("%s: no check", feature)
+			continue
+		}
+		switch evidence, err := check(); err {
+		case nil:
+			t.Logf("%s: confirmed, %s", feature, evidence)
+		case errUnconfirmed:
+			t.Logf("%s: %v", feature, err)
+		default:
+			t.Errorf("%s is advertised but not supported: %v", feature, err)
+		}
+	}
+	// GCE only sets up Windows specific behavior for images which advertise
+	// WINDOWS.
+	if utils.IsWindows() && !advertised["WINDOWS"] {
+		t.Errorf("image %s runs Windows but does not advertise WINDOWS", image.GetName())
+	}
+}
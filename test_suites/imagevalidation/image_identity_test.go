@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+var windowsServerRe = regexp.MustCompile(`Windows Server (\d{4})( R2)?`)
+
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+// imageNameParts maps an OS ID to the substrings the image name must contain
+// for a given OS version.
+var imageNameParts = map[string]func(version string) []string{
+	"ubuntu": func(v string) []string { return []string{"ubuntu", strings.ReplaceAll(v, ".", "")} },
+	"debian": func(v string) []string { return []string{"debian-" + majorVersion(v)} },
+	"rhel":   func(v string) []string { return []string{"rhel-" + majorVersion(v)} },
+	"centos": func(v string) []string { return []string{"centos-", "-" + majorVersion(v)} },
+	"rocky":  func(v string) []string { return []string{"rocky-linux-" + majorVersion(v)} },
+	"almalinux": func(v string) []string {
+		return []string{"almalinux-" + majorVersion(v)}
+	},
+	"ol": func(v string) []string { return []string{"oracle-linux-" + majorVersion(v)} },
+	"sles": func(v string) []string {
+		parts := []string{"sles-" + majorVersion(v)}
+		if _, sp, ok := strings.Cut(v, "."); ok && sp != "0" {
+			parts = append(parts, "-sp"+sp)
+		}
+		return parts
+	},
+	"opensuse-leap": func(v string) []string { return []string{"opensuse-leap-" + majorVersion(v)} },
+	"cos":           func(v string) []string { return []string{"cos-", "-" + majorVersion(v) + "-"} },
+	"windows": func(v string) []string {
+		m := windowsServerRe.FindStringSubmatch(v)
+		if m == nil {
+			return nil
+		}
+		parts := []string{"windows-server-" + m[1]}
+		if m[2] != "" {
+			parts = append(parts, "-r2")
+		}
+		return parts
+	},
+}
+
+// TestImageIdentityConsistency validates that the published image name
+// matches the OS inside the image.
+func TestImageIdentityConsistency(t *testing.T) {
+	id, err := utils.GetImageIdentity(utils.Context(t))
+	if err != nil {
+		t.Fatalf("could not get image identity: %v", err)
+	}
+	t.Logf("image %s reports OS %s version %s", id.Name, id.OSID, id.OSVersion)
+	if utils.IsWindows() && utils.IsWindowsClient(id.Name) {
+		t.Skip("windows client images do not report a consistent product name")
+	}
+	nameParts, ok := imageNameParts[id.OSID]
+	if !ok {
+		t.Skipf("no naming scheme known for OS %s", id.OSID)
+	}
+	parts := nameParts(id.OSVersion)
+	if len(parts) == 0 {
+		t.Fatalf("image %s reports unrecognized OS version %q", id.Name, id.OSVersion)
+	}
+	for _, part := range parts {
+		if !strings.Contains(id.Name, part) {
+			t.Errorf("image name %s does not contain %q, but the image reports OS %s version %s", id.Name, part, id.OSID, id.OSVersion)
+		}
+	}
+}
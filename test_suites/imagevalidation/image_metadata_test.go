@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// publicImageProjectRe matches the projects public images are published to,
+// the label contract only applies to published images.
+var publicImageProjectRe = regexp.MustCompile(`-cloud(-[a-z]+)?$`)
+
+// requiredImageLabels are the labels every published image must have, along
+// with the format of their values.
+var requiredImageLabels = map[string]*regexp.Regexp{
+	"public-image":    regexp.MustCompile(`^true$`),
+	"release-channel": regexp.MustCompile(`^(stable|preview|eol)$`),
+}
+
+// optionalImageLabels may be present on published images, anything else is
+// reported as unexpected.
+var optionalImageLabels = map[string]*regexp.Regexp{
+	"goog-guest-environment": regexp.MustCompile(`^[a-z0-9._-]+$`),
+}
+
+// getImage returns the image resource the instance was created from.
+func getImage(ctx context.Context) (*computepb.Image, error) {
+	image, err := utils.GetMetadata(ctx, "instance", "image")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get image from metadata: %v", err)
+	}
+	// projects/<project>/global/images/<image>
+	parts := strings.Split(image, "/")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("unexpected image %q in metadata", image)
+	}
+	client, err := compute.NewImagesRESTClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not make images client: %v", err)
+	}
+	defer client.Close()
+	return client.Get(ctx, &computepb.GetImageRequest{Project: parts[1], Image: parts[4]})
+}
+
+// TestImageMetadata validates that the image resource has the labels and
+// description required of published images.
+func TestImageMetadata(t *testing.T) {
+	ctx := utils.Context(t)
+	image, err := getImage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("image %s: family %q, description %q, labels %v", image.GetName(), image.GetFamily(), image.GetDescription(), image.GetLabels())
+	if image.GetDescription() == "" {
+		t.Errorf("image %s has no description", image.GetName())
+	}
+	project := strings.Split(image.GetSelfLink(), "/projects/")
+	if len(project) != 2 || !publicImageProjectRe.MatchString(strings.Split(project[1], "/")[0]) {
+		t.Logf("image %s is not in a public image project, not checking labels", image.GetName())
+		return
+	}
+	if image.GetFamily() == "" {
+		t.Errorf("image %s has no family", image.GetName())
+	}
+	labels := image.GetLabels()
+	var missing, malformed, unexpected []string
+	for label, re := range requiredImageLabels {
+		value, ok := labels[label]
+		if !ok {
+			missing = append(missing, label)
+		} else if !re.MatchString(value) {
+			malformed = append(malformed, fmt.Sprintf("%s=%s", label, value))
+		}
+	}
+	for label, value := range labels {
+		if _, ok := requiredImageLabels[label]; ok {
+			continue
+		}
+		if re, ok := optionalImageLabels[label]; !ok {
+			unexpected = append(unexpected, fmt.Sprintf("%s=%s", label, value))
+		} else if !re.MatchString(value) {
+			malformed = append(malformed, fmt.Sprintf("%s=%s", label, value))
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(malformed)
+	sort.Strings(unexpected)
+	if len(missing) > 0 {
+		t.Errorf("image %s is missing required labels %v", image.GetName(), missing)
+	}
+	if len(malformed) > 0 {
+		t.Errorf("image %s has malformed labels %v", image.GetName(), malformed)
+	}
+	if len(unexpected) > 0 {
+		t.Logf("image %s has unexpected labels %v", image.GetName(), unexpected)
+	}
+}
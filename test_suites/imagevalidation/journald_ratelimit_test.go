@@ -0,0 +1,149 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// defaultJournaldBurst and defaultJournaldInterval are journald's rate
+	// limit when not configured.
+	defaultJournaldBurst    = 10000
+	defaultJournaldInterval = "30s"
+	// minJournaldBurst is the smallest burst images may configure before rate
+	// limiting is considered too aggressive.
+	minJournaldBurst = 1000
+	// journaldBurstScale is the most journald scales the burst up by when the
+	// journal has free disk space.
+	journaldBurstScale = 6
+)
+
+// journaldSuppressedRe matches journald reporting the messages it dropped
+// from a unit.
+var journaldSuppressedRe = regexp.MustCompile(`Suppressed (\d+) messages from (\S+)`)
+
+// journaldConfFiles returns journald configuration files in the order they
+// are applied, later files overriding earlier ones.
+func journaldConfFiles() []string {
+	files := []string{"/etc/systemd/journald.conf"}
+	for _, dir := range []string{"/usr/lib/systemd/journald.conf.d", "/usr/local/lib/systemd/journald.conf.d", "/etc/systemd/journald.conf.d", "/run/systemd/journald.conf.d"} {
+		dropins, _ := filepath.Glob(filepath.Join(dir, "*.conf"))
+		files = append(files, dropins...)
+	}
+	return files
+}
+
+// journaldRateLimit returns the configured rate limit interval and burst.
+func journaldRateLimit() (interval string, burst int, err error) {
+	interval, burst = defaultJournaldInterval, defaultJournaldBurst
+	for _, path := range journaldConfFiles() {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok || strings.HasPrefix(key, "#") {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "RateLimitIntervalSec", "RateLimitInterval":
+				interval = value
+			case "RateLimitBurst":
+				if burst, err = strconv.Atoi(value); err != nil {
+					f.Close()
+					return "", 0, fmt.Errorf("%s: invalid RateLimitBurst %q", path, value)
+				}
+			}
+		}
+		f.Close()
+	}
+	return interval, burst, nil
+}
+
+// TestJournaldRateLimit validates that journald rate limits a unit flooding
+// the journal, without dropping messages within the configured burst.
+func TestJournaldRateLimit(t *testing.T) {
+	utils.LinuxOnly(t)
+	for _, cmd := range []string{"journalctl", "systemd-run"} {
+		if !utils.CheckLinuxCmdExists(cmd) {
+			t.Skipf("%s is not installed", cmd)
+		}
+	}
+	interval, burst, err := journaldRateLimit()
+	if err != nil {
+		t.Fatalf("could not read journald configuration: %v", err)
+	}
+	t.Logf("journald rate limit: %d messages per %s", burst, interval)
+	// An interval of 0, with or without a unit, disables rate limiting.
+	if rest := strings.TrimLeft(interval, "0"); burst == 0 || rest == "" || rest[0] < '1' || rest[0] > '9' {
+		t.Fatalf("journald rate limiting is disabled")
+	}
+	if burst < minJournaldBurst {
+		t.Errorf("journald rate limit burst is %d, want at least %d", burst, minJournaldBurst)
+	}
+
+	// Send more than the burst could be scaled up to, so some messages must
+	// be suppressed.
+	total := burst*journaldBurstScale + minJournaldBurst
+	unit := fmt.Sprintf("cit-journald-flood-%d", time.Now().Unix())
+	since := time.Now().Add(-time.Second).Format("2006-01-02 15:04:05")
+	if out, err := exec.Command("systemd-run", "--unit="+unit, "--wait", "--quiet", "seq", "-f", "cit-flood %g", "1", strconv.Itoa(total)).CombinedOutput(); err != nil {
+		t.Fatalf("could not flood the journal: %v %s", err, out)
+	}
+	// Give journald time to process what was written to its stream.
+	time.Sleep(5 * time.Second)
+
+	out, err := exec.Command("journalctl", "-u", unit, "-o", "cat", "--no-pager").Output()
+	if err != nil {
+		t.Fatalf("could not read journal of %s: %v", unit, err)
+	}
+	delivered := strings.Count(string(out), "cit-flood ")
+	out, err = exec.Command("journalctl", "-u", "systemd-journald", "--since", since, "-o", "cat", "--no-pager").Output()
+	if err != nil {
+		t.Fatalf("could not read journal of systemd-journald: %v", err)
+	}
+	suppressed := 0
+	for _, m := range journaldSuppressedRe.FindAllStringSubmatch(string(out), -1) {
+		if strings.Contains(m[2], unit) {
+			n, _ := strconv.Atoi(m[1])
+			suppressed += n
+		}
+	}
+	// journald only reports suppressed messages once the unit logs again
+	// after the interval, so the report may not cover every dropped message.
+	t.Logf("sent %d messages, %d delivered, journald reported %d suppressed", total, delivered, suppressed)
+
+	if delivered < burst {
+		t.Errorf("only %d messages were delivered, want at least the burst of %d", delivered, burst)
+	}
+	if delivered >= total {
+		t.Errorf("all %d messages were delivered, rate limiting did not kick in", total)
+	}
+}
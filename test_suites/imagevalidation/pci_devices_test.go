@@ -0,0 +1,165 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// pciDevice is a PCI vendor and device ID, formatted as lowercase hex.
+type pciDevice struct {
+	vendor string
+	device string
+}
+
+func (d pciDevice) String() string { return d.vendor + ":" + d.device }
+
+// pciDeviceClass is a kind of virtual device, any of whose IDs satisfies it.
+type pciDeviceClass struct {
+	name string
+	ids  []pciDevice
+}
+
+var (
+	gvnicDevice      = pciDeviceClass{"gVNIC", []pciDevice{{"1ae0", "0042"}}}
+	virtioNetDevice  = pciDeviceClass{"virtio-net", []pciDevice{{"1af4", "1000"}, {"1af4", "1041"}}}
+	virtioSCSIDevice = pciDeviceClass{"virtio-scsi", []pciDevice{{"1af4", "1004"}, {"1af4", "1048"}}}
+	nvmeDevice       = pciDeviceClass{"NVMe", []pciDevice{{"1ae0", "001f"}}}
+	nvidiaVendor     = "10de"
+)
+
+var windowsPCIIDRe = regexp.MustCompile(`VEN_([0-9A-Fa-f]{4})&DEV_([0-9A-Fa-f]{4})`)
+
+// listPCIDevices returns the PCI devices present in the guest.
+func listPCIDevices() ([]pciDevice, error) {
+	var devices []pciDevice
+	if utils.IsWindows() {
+		out, err := utils.RunPowershellCmd(`Get-PnpDevice -PresentOnly | Where-Object { $_.InstanceId -like 'PCI\*' } | Select-Object -ExpandProperty InstanceId`)
+		if err != nil {
+			return nil, fmt.Errorf("could not list PnP devices: %v %s", err, out.Stderr)
+		}
+		for _, m := range windowsPCIIDRe.FindAllStringSubmatch(out.Stdout, -1) {
+			devices = append(devices, pciDevice{strings.ToLower(m[1]), strings.ToLower(m[2])})
+		}
+		return devices, nil
+	}
+	paths, err := filepath.Glob("/sys/bus/pci/devices/*")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		vendor, err := os.ReadFile(filepath.Join(path, "vendor"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read vendor of %s: %v", path, err)
+		}
+		device, err := os.ReadFile(filepath.Join(path, "device"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read device of %s: %v", path, err)
+		}
+		devices = append(devices, pciDevice{
+			strings.TrimPrefix(strings.TrimSpace(string(vendor)), "0x"),
+			strings.TrimPrefix(strings.TrimSpace(string(device)), "0x"),
+		})
+	}
+	return devices, nil
+}
+
+func hasDevice(devices []pciDevice, class pciDeviceClass) bool {
+	for _, d := range devices {
+		for _, id := range class.ids {
+			if d == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestPCIDevices validates that the virtual devices the instance was created
+// with are enumerated on the PCI bus.
+func TestPCIDevices(t *testing.T) {
+	ctx := utils.Context(t)
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: prj, Zone: zone, Instance: name})
+	if err != nil {
+		t.Fatalf("could not get instance %s: %v", name, err)
+	}
+
+	var expected []pciDeviceClass
+	for _, nic := range inst.GetNetworkInterfaces() {
+		if nic.GetNicType() == "GVNIC" {
+			expected = append(expected, gvnicDevice)
+		} else {
+			expected = append(expected, virtioNetDevice)
+		}
+	}
+	for _, disk := range inst.GetDisks() {
+		if disk.GetInterface() == "NVME" {
+			expected = append(expected, nvmeDevice)
+		} else {
+			expected = append(expected, virtioSCSIDevice)
+		}
+	}
+	var gpus int64
+	for _, acc := range inst.GetGuestAccelerators() {
+		gpus += int64(acc.GetAcceleratorCount())
+	}
+
+	devices, err := listPCIDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing []string
+	for _, class := range expected {
+		if !hasDevice(devices, class) {
+			missing = append(missing, class.name)
+		}
+	}
+	var foundGPUs int64
+	for _, d := range devices {
+		if d.vendor == nvidiaVendor {
+			foundGPUs++
+		}
+	}
+	if foundGPUs < gpus {
+		missing = append(missing, fmt.Sprintf("%d NVIDIA GPUs (found %d)", gpus, foundGPUs))
+	}
+	if len(missing) > 0 {
+		t.Errorf("machine type %s is missing PCI devices %v, found devices %v", inst.GetMachineType(), missing, devices)
+	} else {
+		t.Logf("found PCI devices %v", devices)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// immutableRootWritable are paths which must stay writable on an image whose
+// root filesystem is read-only, through an overlay or a separate mount.
+var immutableRootWritable = []string{"/etc", "/home", "/tmp", "/var"}
+
+// hasMountOption reports whether the comma separated options contain opt.
+func hasMountOption(options, opt string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// fstabRootOptions returns the mount options of the root filesystem in
+// /etc/fstab, and whether it has an entry.
+func fstabRootOptions() (string, bool) {
+	data, err := os.ReadFile("/etc/fstab")
+	if err != nil {
+		return "", false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && !strings.HasPrefix(fields[0], "#") && fields[1] == "/" {
+			return fields[3], true
+		}
+	}
+	return "", false
+}
+
+// TestRootRemountRW validates that an image which boots with a read-only root
+// either remounts it read-write, or keeps it read-only with writable overlays
+// where the system needs to write.
+func TestRootRemountRW(t *testing.T) {
+	utils.LinuxOnly(t)
+	cmdline, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		t.Fatalf("could not read kernel command line: %v", err)
+	}
+	if !hasMountOption(strings.Join(strings.Fields(string(cmdline)), ","), "ro") {
+		t.Skip("root is not mounted read-only at boot")
+	}
+	out, err := exec.Command("findmnt", "-n", "-o", "OPTIONS,FSTYPE,SOURCE", "/").Output()
+	if err != nil {
+		t.Fatalf("could not find root filesystem: %v", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		t.Fatalf("unexpected findmnt output %q", out)
+	}
+	options := fields[0]
+	t.Logf("root filesystem %s (%s) is mounted with %s", fields[2], fields[1], options)
+	rootRO := hasMountOption(options, "ro")
+
+	fstabOptions, inFstab := fstabRootOptions()
+	if inFstab {
+		t.Logf("/etc/fstab root options are %s", fstabOptions)
+	}
+	if inFstab && !hasMountOption(fstabOptions, "ro") {
+		// The root filesystem is meant to be remounted read-write.
+		if rootRO {
+			status, _ := exec.Command("systemctl", "status", "--no-pager", "systemd-remount-fs.service").CombinedOutput()
+			t.Errorf("root filesystem is still read-only, want it remounted read-write. systemd-remount-fs status:\n%s", status)
+		}
+		return
+	}
+	if !rootRO {
+		t.Logf("root filesystem was remounted read-write without an fstab entry")
+		return
+	}
+
+	// The root filesystem is meant to stay read-only.
+	for _, dir := range immutableRootWritable {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		mount, _ := exec.Command("findmnt", "-n", "-o", "TARGET,FSTYPE,OPTIONS", "-T", dir).Output()
+		t.Logf("%s is on %s", dir, strings.TrimSpace(string(mount)))
+		f, err := os.CreateTemp(dir, "cit-remount-")
+		if err != nil {
+			t.Errorf("%s is not writable on a read-only root: %v", dir, err)
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// expectedSerialLogin maps image names to whether they are expected to offer a
+// login prompt on the serial console. Images which match none are only
+// reported.
+var expectedSerialLogin = []struct {
+	image   *regexp.Regexp
+	enabled bool
+}{
+	{regexp.MustCompile(`^debian-`), true},
+	{regexp.MustCompile(`^ubuntu-`), true},
+	{regexp.MustCompile(`^(rhel|centos|rocky-linux|almalinux|oracle-linux)-`), true},
+	{regexp.MustCompile(`^(sles|opensuse-leap)-`), true},
+	{regexp.MustCompile(`^fedora`), true},
+}
+
+// serialConsole returns the last serial console on the kernel command line,
+// which is the one /dev/console is attached to.
+func serialConsole(t *testing.T) string {
+	t.Helper()
+	cmdline, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		t.Fatalf("could not read kernel command line: %v", err)
+	}
+	var console string
+	for _, arg := range strings.Fields(string(cmdline)) {
+		value, ok := strings.CutPrefix(arg, "console=")
+		if !ok {
+			continue
+		}
+		tty, _, _ := strings.Cut(value, ",")
+		if strings.HasPrefix(tty, "ttyS") || strings.HasPrefix(tty, "ttyAMA") {
+			console = tty
+		}
+	}
+	return console
+}
+
+// unitState returns the enablement and activity of a systemd unit.
+func unitState(unit string) (enabled, active string) {
+	out, _ := exec.Command("systemctl", "is-enabled", unit).Output()
+	enabled = strings.TrimSpace(string(out))
+	out, _ = exec.Command("systemctl", "is-active", unit).Output()
+	active = strings.TrimSpace(string(out))
+	return enabled, active
+}
+
+// TestSerialConsoleLogin validates that a login prompt is offered on the serial
+// console of images which support emergency access through it.
+func TestSerialConsoleLogin(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("systemctl") {
+		t.Skip("image does not use systemd")
+	}
+	id, err := utils.GetImageIdentity(utils.Context(t))
+	if err != nil {
+		t.Fatalf("could not get image identity: %v", err)
+	}
+	console := serialConsole(t)
+	if console == "" {
+		console = "ttyS0"
+		t.Logf("no serial console on kernel command line, checking %s", console)
+	}
+	unit := "serial-getty@" + console + ".service"
+	enabled, active := unitState(unit)
+	t.Logf("image %s: %s is %s and %s", id.Name, unit, enabled, active)
+	for _, expected := range expectedSerialLogin {
+		if !expected.image.MatchString(id.Name) {
+			continue
+		}
+		switch {
+		case expected.enabled && active != "active":
+			t.Errorf("%s is %s, expected a login prompt on %s", unit, active, console)
+		case !expected.enabled && active == "active":
+			t.Errorf("%s is active, expected serial console login to be disabled", unit)
+		}
+		return
+	}
+	t.Logf("no expected serial console login state for image %s", id.Name)
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package imagevalidation is a CIT suite for validating that an image's
+// published resource is consistent with the image contents.
+package imagevalidation
+
+import (
+	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	daisy "github.com/GoogleCloudPlatform/compute-daisy"
+	"google.golang.org/api/compute/v1"
+)
+
+// Name is the name of the test package. It must match the directory name.
+var Name = "imagevalidation"
+
+// TestSetup sets up the test workflow.
+func TestSetup(t *imagetest.TestWorkflow) error {
+	inst := &daisy.Instance{}
+	inst.Scopes = append(inst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+	vm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "imagevalidation"}}, inst)
+	if err != nil {
+		return err
+	}
+	vm.RunTests("TestImageMetadata|TestArchitecture|TestPCIDevices|TestDKMS|TestImageIdentityConsistency|TestEmbeddedScripts|TestCgroupVersion|TestSerialConsoleLogin|TestMultiBootPartition|TestRootRemountRW|TestJournaldRateLimit|TestCgroupDelegation|TestCoreDumpHandling|TestSystemdWatchdog|TestGuestOSFeatures")
+	return nil
+}
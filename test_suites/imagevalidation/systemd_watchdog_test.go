@@ -0,0 +1,91 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagevalidation
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// watchdogProperties are the systemd manager watchdog settings reported.
+// Older versions of systemd call RebootWatchdogUSec ShutdownWatchdogUSec.
+var watchdogProperties = []string{"RuntimeWatchdogUSec", "RebootWatchdogUSec", "ShutdownWatchdogUSec", "KExecWatchdogUSec", "WatchdogDevice"}
+
+// watchdogDisabled reports whether a systemd watchdog timeout disables the
+// watchdog.
+func watchdogDisabled(value string) bool {
+	return value == "" || value == "0" || value == "infinity"
+}
+
+// TestSystemdWatchdog validates that systemd's runtime watchdog is enabled on
+// images with a watchdog device, so a hung instance resets itself, and
+// disabled on images without one.
+func TestSystemdWatchdog(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("systemctl") {
+		t.Skip("image does not use systemd")
+	}
+	args := []string{"show"}
+	for _, property := range watchdogProperties {
+		args = append(args, "-p", property)
+	}
+	out, err := exec.Command("systemctl", args...).Output()
+	if err != nil {
+		t.Fatalf("could not get systemd watchdog settings: %v", err)
+	}
+	settings := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			settings[key] = value
+			t.Logf("%s=%s", key, value)
+		}
+	}
+	runtime, ok := settings["RuntimeWatchdogUSec"]
+	if !ok {
+		t.Fatalf("systemd does not report RuntimeWatchdogUSec")
+	}
+
+	devices, _ := filepath.Glob("/sys/class/watchdog/watchdog*")
+	var names []string
+	for _, device := range devices {
+		identity, _ := os.ReadFile(filepath.Join(device, "identity"))
+		names = append(names, filepath.Base(device)+" ("+strings.TrimSpace(string(identity))+")")
+	}
+	t.Logf("watchdog devices: %v", names)
+
+	if len(devices) == 0 {
+		// systemd fails to open the device on every start if enabled without
+		// one, and the instance gains nothing from it.
+		if !watchdogDisabled(runtime) {
+			t.Errorf("RuntimeWatchdogUSec is %s but there is no watchdog device", runtime)
+		}
+		return
+	}
+	if watchdogDisabled(runtime) {
+		t.Errorf("a watchdog device is present but RuntimeWatchdogUSec is %q, a hung instance will not reset itself", runtime)
+	}
+	reboot, ok := settings["RebootWatchdogUSec"]
+	if !ok {
+		reboot = settings["ShutdownWatchdogUSec"]
+	}
+	if watchdogDisabled(reboot) {
+		t.Errorf("a watchdog device is present but the reboot watchdog is %q, a hung shutdown will not reset the instance", reboot)
+	}
+}
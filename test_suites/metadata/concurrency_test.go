@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// defaultMetadataConcurrency is the number of concurrent clients used
+	// when metadata-concurrency is not set.
+	defaultMetadataConcurrency = 50
+	// metadataConcurrencyRequests is the number of requests each client
+	// makes.
+	metadataConcurrencyRequests = 20
+)
+
+// concurrencyPaths are requested in turn by each client. Their values don't
+// change while the instance runs, so every response must match the first.
+var concurrencyPaths = [][]string{
+	{"instance", "id"},
+	{"instance", "hostname"},
+	{"instance", "zone"},
+	{"project", "project-id"},
+}
+
+// TestMetadataConcurrency validates that many concurrent requests to the
+// metadata server all succeed and return the correct values.
+func TestMetadataConcurrency(t *testing.T) {
+	ctx := utils.Context(t)
+	concurrency := defaultMetadataConcurrency
+	if value, err := utils.GetMetadata(ctx, "instance", "attributes", "metadata-concurrency"); err == nil {
+		if concurrency, err = strconv.Atoi(value); err != nil || concurrency < 1 {
+			t.Fatalf("metadata-concurrency %q is not a positive number", value)
+		}
+	}
+	want := make([]string, len(concurrencyPaths))
+	for i, path := range concurrencyPaths {
+		var err error
+		if want[i], err = utils.GetMetadata(ctx, path...); err != nil {
+			t.Fatalf("could not get %v from metadata: %v", path, err)
+		}
+	}
+
+	var mu sync.Mutex
+	var failed, corrupted int
+	var wg sync.WaitGroup
+	for c := 0; c < concurrency; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for r := 0; r < metadataConcurrencyRequests; r++ {
+				i := (c + r) % len(concurrencyPaths)
+				got, err := utils.GetMetadata(ctx, concurrencyPaths[i]...)
+				mu.Lock()
+				switch {
+				case err != nil:
+					failed++
+					if failed <= 10 {
+						t.Errorf("client %d request %d for %v failed: %v", c, r, concurrencyPaths[i], err)
+					}
+				case got != want[i]:
+					corrupted++
+					if corrupted <= 10 {
+						t.Errorf("client %d request %d for %v returned %q, want %q", c, r, concurrencyPaths[i], got, want[i])
+					}
+				}
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	total := concurrency * metadataConcurrencyRequests
+	t.Logf("%d clients made %d requests: %d failed (%.2f%%), %d corrupted", concurrency, total, failed, 100*float64(failed)/float64(total), corrupted)
+}
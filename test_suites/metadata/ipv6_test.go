@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// metadataTransports are the addresses the metadata server listens on.
+var metadataTransports = map[string]string{
+	"IPv4": "169.254.169.254",
+	"IPv6": "[fd20:ce::254]",
+}
+
+// ipv6Paths are requested over each transport and compared.
+var ipv6Paths = []string{
+	"instance/id",
+	"instance/hostname",
+	"instance/zone",
+	"instance/network-interfaces/0/ipv6s",
+	"project/project-id",
+}
+
+// getMetadataFrom requests path from the metadata server at host.
+func getMetadataFrom(client *http.Client, host, path string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/computeMetadata/v1/%s", host, path), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Metadata-Flavor", "Google")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http response code is %v", resp.StatusCode)
+	}
+	if flavor := resp.Header.Get("Metadata-Flavor"); flavor != "Google" {
+		return "", fmt.Errorf("Metadata-Flavor response header is %q, want Google", flavor)
+	}
+	return string(body), nil
+}
+
+// TestMetadataOverIPv6 validates that the metadata server is reachable over
+// IPv6 as well as IPv4 on instances with IPv6, and that both return the same
+// values.
+func TestMetadataOverIPv6(t *testing.T) {
+	ipv6s, err := utils.GetMetadata(utils.Context(t), "instance", "network-interfaces", "0", "ipv6s")
+	if err != nil || strings.TrimSpace(ipv6s) == "" {
+		t.Skip("instance has no IPv6 address on its primary interface")
+	}
+	t.Logf("primary interface IPv6 addresses: %s", strings.Join(strings.Fields(ipv6s), ", "))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var succeeded []string
+	for _, transport := range []string{"IPv4", "IPv6"} {
+		if _, err := getMetadataFrom(client, metadataTransports[transport], "instance/id"); err != nil {
+			t.Errorf("metadata server is not reachable over %s at %s: %v", transport, metadataTransports[transport], err)
+			continue
+		}
+		succeeded = append(succeeded, transport)
+	}
+	t.Logf("metadata server reachable over: %s", strings.Join(succeeded, ", "))
+	if len(succeeded) != len(metadataTransports) {
+		t.FailNow()
+	}
+
+	for _, path := range ipv6Paths {
+		v4, err := getMetadataFrom(client, metadataTransports["IPv4"], path)
+		if err != nil {
+			t.Errorf("could not get %s over IPv4: %v", path, err)
+			continue
+		}
+		v6, err := getMetadataFrom(client, metadataTransports["IPv6"], path)
+		if err != nil {
+			t.Errorf("could not get %s over IPv6: %v", path, err)
+			continue
+		}
+		if v4 != v6 {
+			t.Errorf("%s differs between transports: IPv4 returned %q, IPv6 returned %q", path, v4, v6)
+		}
+	}
+}
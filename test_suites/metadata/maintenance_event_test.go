@@ -0,0 +1,139 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	maintenanceNone    = "NONE"
+	maintenanceMigrate = "MIGRATE_ON_HOST_MAINTENANCE"
+	// maintenanceEventTimeout is how long to wait for the maintenance event
+	// to start and finish after it is simulated.
+	maintenanceEventTimeout = 10 * time.Minute
+)
+
+// maintenanceTransition is a value of instance/maintenance-event observed by
+// the long-poll.
+type maintenanceTransition struct {
+	value string
+	at    time.Time
+}
+
+// pollMaintenanceEvents long-polls instance/maintenance-event starting from
+// etag and sends every new value, until the event has started and ended or
+// ctx is done.
+func pollMaintenanceEvents(ctx context.Context, etag string, transitions chan<- maintenanceTransition) {
+	defer close(transitions)
+	started := false
+	for ctx.Err() == nil {
+		value, newETag, err := utils.GetMetadataWaitForChangeETag(ctx, etag, 60, "instance", "maintenance-event")
+		if err != nil {
+			// The metadata server may be briefly unreachable while the
+			// instance migrates.
+			time.Sleep(time.Second)
+			continue
+		}
+		if newETag == etag {
+			continue
+		}
+		etag = newETag
+		transitions <- maintenanceTransition{value, time.Now()}
+		if value != maintenanceNone {
+			started = true
+		} else if started {
+			return
+		}
+	}
+}
+
+// TestMaintenanceEventPolling validates that a long-poll on the
+// maintenance-event key observes a simulated host maintenance event start and
+// finish.
+func TestMaintenanceEventPolling(t *testing.T) {
+	ctx := utils.Context(t)
+	onHostMaintenance, err := utils.GetMetadata(ctx, "instance", "scheduling", "on-host-maintenance")
+	if err != nil {
+		t.Fatalf("could not get on-host-maintenance policy: %v", err)
+	}
+	if onHostMaintenance != "MIGRATE" {
+		t.Skipf("instance does not live migrate, on-host-maintenance is %s", onHostMaintenance)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	initial, headers, err := utils.GetMetadataWithHeaders(ctx, "instance", "maintenance-event")
+	if err != nil {
+		t.Fatalf("could not get maintenance-event: %v", err)
+	}
+	if initial != maintenanceNone {
+		t.Fatalf("maintenance-event is %s before maintenance was simulated, want %s", initial, maintenanceNone)
+	}
+
+	// Start the long-poll from the ETag of NONE before the event is
+	// simulated, so the transition can't be missed between requests.
+	pollCtx, cancel := context.WithTimeout(ctx, maintenanceEventTimeout)
+	defer cancel()
+	transitions := make(chan maintenanceTransition)
+	go pollMaintenanceEvents(pollCtx, headers.Get("ETag"), transitions)
+	time.Sleep(2 * time.Second)
+
+	simulated := time.Now()
+	opErr := make(chan error, 1)
+	go func() {
+		op, err := client.SimulateMaintenanceEvent(ctx, &computepb.SimulateMaintenanceEventInstanceRequest{Project: prj, Zone: zone, Instance: name})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		if err != nil {
+			// No event is coming, stop waiting for one.
+			cancel()
+		}
+		opErr <- err
+	}()
+
+	var observed []string
+	for tr := range transitions {
+		t.Logf("maintenance-event changed to %s %v after maintenance was simulated", tr.value, tr.at.Sub(simulated).Round(time.Millisecond))
+		observed = append(observed, tr.value)
+	}
+	if err := <-opErr; err != nil {
+		t.Fatalf("could not simulate maintenance event: %v", err)
+	}
+	if len(observed) == 0 || observed[0] != maintenanceMigrate {
+		t.Fatalf("maintenance-event changed to %v, want %s first", observed, maintenanceMigrate)
+	}
+	if observed[len(observed)-1] != maintenanceNone {
+		t.Errorf("maintenance-event did not return to %s within %v, last value was %s", maintenanceNone, maintenanceEventTimeout, observed[len(observed)-1])
+	}
+}
@@ -16,12 +16,15 @@ package metadata
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os/exec"
 	"testing"
 	"time"
 
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	daisyCompute "github.com/GoogleCloudPlatform/compute-daisy/compute"
+	"google.golang.org/api/compute/v1"
 )
 
 func guestAgentPackageName() string {
@@ -100,3 +103,85 @@ func reinstallGuestAgent(ctx context.Context, t *testing.T) {
 		}
 	}
 }
+
+// setMetadataItem sets key to value in md, or removes key if value is nil.
+func setMetadataItem(md *compute.Metadata, key string, value *string) {
+	for i, item := range md.Items {
+		if item.Key != key {
+			continue
+		}
+		if value == nil {
+			md.Items = append(md.Items[:i], md.Items[i+1:]...)
+		} else {
+			item.Value = value
+		}
+		return
+	}
+	if value != nil {
+		md.Items = append(md.Items, &compute.MetadataItems{Key: key, Value: value})
+	}
+}
+
+// setInstanceAttribute sets an attribute in the metadata of the instance
+// running the test through the compute API, or removes it if value is nil.
+func setInstanceAttribute(ctx context.Context, key string, value *string) error {
+	client, err := daisyCompute.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not make compute api client: %v", err)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		return fmt.Errorf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get instance name: %v", err)
+	}
+	inst, err := client.GetInstance(prj, zone, name)
+	if err != nil {
+		return fmt.Errorf("could not get instance %s: %v", name, err)
+	}
+	md := inst.Metadata
+	if md == nil {
+		md = &compute.Metadata{}
+	}
+	setMetadataItem(md, key, value)
+	if err := client.SetInstanceMetadata(prj, zone, name, md); err != nil {
+		return fmt.Errorf("could not set metadata of instance %s: %v", name, err)
+	}
+	return nil
+}
+
+// setProjectAttribute sets an attribute in the metadata of the project of the
+// instance running the test, or removes it if value is nil. Other tests may
+// change project metadata concurrently, so it retries when the metadata
+// changed between reading and writing it.
+func setProjectAttribute(ctx context.Context, key string, value *string) error {
+	client, err := daisyCompute.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not make compute api client: %v", err)
+	}
+	prj, _, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		return fmt.Errorf("could not find project: %v", err)
+	}
+	for attempt := 0; ; attempt++ {
+		project, err := client.GetProject(prj)
+		if err != nil {
+			return fmt.Errorf("could not get project %s: %v", prj, err)
+		}
+		md := project.CommonInstanceMetadata
+		if md == nil {
+			md = &compute.Metadata{}
+		}
+		setMetadataItem(md, key, value)
+		err = client.SetCommonInstanceMetadata(prj, md)
+		if err == nil {
+			return nil
+		}
+		if attempt == 4 {
+			return fmt.Errorf("could not set metadata of project %s: %v", prj, err)
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// resolveAttribute resolves an attribute the way the guest environment does,
+// preferring the instance value over the project value.
+func resolveAttribute(ctx context.Context, key string) (string, error) {
+	value, err := utils.GetMetadata(ctx, "instance", "attributes", key)
+	if errors.Is(err, utils.ErrMDSEntryNotFound) {
+		return utils.GetMetadata(ctx, "project", "attributes", key)
+	}
+	return value, err
+}
+
+// waitForAttribute waits for key to resolve to want.
+func waitForAttribute(ctx context.Context, key, want string) error {
+	var got string
+	var err error
+	for start := time.Now(); time.Since(start) < 2*time.Minute; time.Sleep(5 * time.Second) {
+		if got, err = resolveAttribute(ctx, key); err == nil && got == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s resolved to %q (err %v), want %q", key, got, err, want)
+}
+
+// TestMetadataPrecedence validates that an instance attribute takes
+// precedence over the project attribute with the same key, and that the
+// project attribute applies again once the instance attribute is removed.
+func TestMetadataPrecedence(t *testing.T) {
+	ctx := utils.Context(t)
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	// Project metadata is shared with every other test in the project, so the
+	// key is unique to this instance and used by nothing else.
+	key := "cit-precedence-" + name
+	projectValue, instanceValue := "project", "instance"
+	t.Cleanup(func() {
+		if err := setInstanceAttribute(ctx, key, nil); err != nil {
+			t.Errorf("could not remove %s from instance metadata: %v", key, err)
+		}
+		if err := setProjectAttribute(ctx, key, nil); err != nil {
+			t.Errorf("could not remove %s from project metadata: %v", key, err)
+		}
+	})
+
+	if err := setProjectAttribute(ctx, key, &projectValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForAttribute(ctx, key, projectValue); err != nil {
+		t.Fatalf("with only the project value set: %v", err)
+	}
+	if err := setInstanceAttribute(ctx, key, &instanceValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForAttribute(ctx, key, instanceValue); err != nil {
+		t.Fatalf("with both instance and project values set: %v", err)
+	}
+	if got, err := utils.GetMetadata(ctx, "project", "attributes", key); err != nil || got != projectValue {
+		t.Errorf("project attribute %s is %q (err %v), want %q", key, got, err, projectValue)
+	}
+
+	if err := setInstanceAttribute(ctx, key, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForAttribute(ctx, key, projectValue); err != nil {
+		t.Fatalf("after removing the instance value: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// metadataRoute is the route blackholed to cut off the metadata server.
+	metadataRoute = "169.254.169.254/32"
+	// metadataOutage is how long the metadata server is unreachable.
+	metadataOutage = time.Minute
+	// metadataRecoveryTimeout is how long services have to recover after the
+	// metadata server is reachable again.
+	metadataRecoveryTimeout = 2 * time.Minute
+)
+
+// criticalServices must stay up while the metadata server is unreachable.
+var criticalServices = []string{"google-guest-agent", "sshd", "ssh"}
+
+// serviceRestarts returns the restart count of each active critical service.
+func serviceRestarts() map[string]string {
+	restarts := make(map[string]string)
+	for _, service := range criticalServices {
+		if exec.Command("systemctl", "is-active", "--quiet", service).Run() != nil {
+			continue
+		}
+		out, _ := exec.Command("systemctl", "show", "--property=NRestarts", "--value", service).Output()
+		restarts[service] = strings.TrimSpace(string(out))
+	}
+	return restarts
+}
+
+func restoreMetadataRoute() error {
+	out, err := exec.Command("ip", "route", "del", "blackhole", metadataRoute).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip route del failed: %v %s", err, out)
+	}
+	return nil
+}
+
+// TestMetadataResilience validates that the guest agent and critical services
+// survive a metadata server outage and recover when it ends.
+func TestMetadataResilience(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "metadata-resilience"); err != nil || enabled != "true" {
+		t.Skip("metadata-resilience is not enabled for this instance")
+	}
+	before := serviceRestarts()
+	if _, ok := before["google-guest-agent"]; !ok {
+		t.Fatal("guest agent is not running before the outage")
+	}
+
+	// Schedule removal of the route independently of this process, so the
+	// instance recovers even if the test binary is killed mid outage.
+	backstop := fmt.Sprintf("--on-active=%d", int((metadataOutage + metadataRecoveryTimeout).Seconds()))
+	if out, err := exec.Command("systemd-run", "--unit=cit-mds-restore", backstop, "ip", "route", "del", "blackhole", metadataRoute).CombinedOutput(); err != nil {
+		t.Fatalf("could not schedule route restore: %v %s", err, out)
+	}
+	t.Cleanup(func() {
+		exec.Command("systemctl", "stop", "cit-mds-restore.timer").Run()
+	})
+	if out, err := exec.Command("ip", "route", "add", "blackhole", metadataRoute).CombinedOutput(); err != nil {
+		t.Fatalf("could not block metadata server route: %v %s", err, out)
+	}
+	restored := false
+	t.Cleanup(func() {
+		if !restored {
+			if err := restoreMetadataRoute(); err != nil {
+				t.Errorf("could not restore metadata server route: %v", err)
+			}
+		}
+	})
+
+	shortCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	_, err := utils.GetMetadata(shortCtx, "instance", "id")
+	cancel()
+	if err == nil {
+		t.Fatal("metadata server is still reachable with its route blocked")
+	}
+	for start := time.Now(); time.Since(start) < metadataOutage; time.Sleep(5 * time.Second) {
+		for service := range before {
+			if exec.Command("systemctl", "is-active", "--quiet", service).Run() != nil {
+				t.Errorf("%s is not active %v into the metadata outage", service, time.Since(start).Round(time.Second))
+			}
+		}
+	}
+
+	if err := restoreMetadataRoute(); err != nil {
+		t.Fatalf("could not restore metadata server route: %v", err)
+	}
+	restored = true
+	var id string
+	for start := time.Now(); time.Since(start) < metadataRecoveryTimeout; time.Sleep(5 * time.Second) {
+		shortCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		id, err = utils.GetMetadata(shortCtx, "instance", "id")
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("metadata server did not become reachable after restoring its route: %v", err)
+	}
+	t.Logf("metadata server reachable again, instance id %s", id)
+
+	after := serviceRestarts()
+	for service, restarts := range before {
+		if after[service] != restarts {
+			t.Errorf("%s restarted during the metadata outage, restart count went from %s to %q", service, restarts, after[service])
+		}
+	}
+}
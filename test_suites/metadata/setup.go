@@ -17,6 +17,8 @@ package metadata
 
 import (
 	"embed"
+	"flag"
+	"fmt"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
@@ -40,11 +42,27 @@ const (
 	shutdownScriptWindowsURL = "scripts/shutdownScriptWindows.ps1"
 	startupScriptWindowsURL  = "scripts/startupScriptWindows.ps1"
 	daemonScriptWindowsURL   = "scripts/daemonScriptWindows.ps1"
+	shutdownGraceLinuxURL    = "scripts/shutdownGraceLinux.sh"
+	shutdownGraceWindowsURL  = "scripts/shutdownGraceWindows.ps1"
+
+	metadataScriptsStartupWindowsURL  = "scripts/metadataScriptsStartupWindows.ps1"
+	metadataScriptsShutdownWindowsURL = "scripts/metadataScriptsShutdownWindows.ps1"
+
+	// shutdownGraceKey is the metadata key which sets how long shutdown
+	// scripts may run before they are stopped.
+	shutdownGraceKey = "shutdown-script-timeout"
+	// shutdownGraceSeconds is the window TestShutdownGraceConfig configures,
+	// well under the default so the difference is measurable.
+	shutdownGraceSeconds = 30
 )
 
 //go:embed *
 var scripts embed.FS
 
+var resilience = flag.Bool("metadata_resilience", false, "run TestMetadataResilience, which cuts the test VM off from the metadata server for a short time")
+
+var concurrency = flag.Int("metadata_concurrency", 0, "number of concurrent clients TestMetadataConcurrency uses. The test default is used if unset")
+
 // TestSetup sets up the test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
 
@@ -52,6 +70,10 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	if err != nil {
 		return err
 	}
+	vm.AddScope("https://www.googleapis.com/auth/cloud-platform")
+	if *concurrency > 0 {
+		vm.AddMetadata("metadata-concurrency", fmt.Sprint(*concurrency))
+	}
 
 	vm2Inst := &daisy.Instance{}
 	vm2Inst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
@@ -104,6 +126,51 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	}
 	vm8.AddMetadata("enable-guest-attributes", "TRUE")
 
+	if *resilience && !utils.HasFeature(t.Image, "WINDOWS") {
+		resiliencevm, err := t.CreateTestVM("mdsresilience")
+		if err != nil {
+			return err
+		}
+		resiliencevm.AddMetadata("metadata-resilience", "true")
+		resiliencevm.RunTests("TestMetadataResilience")
+	}
+
+	graceInst := &daisy.Instance{}
+	graceInst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
+	gracevm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "shutdowngrace"}}, graceInst)
+	if err != nil {
+		return err
+	}
+	gracevm.AddMetadata(shutdownGraceKey, fmt.Sprintf("%ds", shutdownGraceSeconds))
+	if err := gracevm.Reboot(); err != nil {
+		return err
+	}
+	gracevm.RunTests("TestShutdownGraceConfig")
+
+	// The metadata server is only reachable over IPv6 from instances with an
+	// IPv6 address, so this needs a dual-stack interface.
+	ipv6Network, err := t.CreateNetwork("ipv6-network", false)
+	if err != nil {
+		return err
+	}
+	ipv6Network.EnableULAInternalIPv6()
+	ipv6Subnetwork, err := ipv6Network.CreateSubnetwork("ipv6-subnetwork", "10.128.0.0/20")
+	if err != nil {
+		return err
+	}
+	ipv6Subnetwork.SetStackType("IPV4_IPV6", "INTERNAL")
+	ipv6vm, err := t.CreateTestVM("mdsipv6")
+	if err != nil {
+		return err
+	}
+	if err := ipv6vm.AddCustomNetwork(ipv6Network, ipv6Subnetwork); err != nil {
+		return err
+	}
+	if err := ipv6vm.SetStackType(ipv6Network, "IPV4_IPV6"); err != nil {
+		return err
+	}
+	ipv6vm.RunTests("TestMetadataOverIPv6")
+
 	var startupByteArr []byte
 	var shutdownByteArr []byte
 	var daemonByteArr []byte
@@ -133,6 +200,12 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		vm7.SetWindowsStartupScript(strings.Repeat("a", metadataMaxLength))
 		vm8.SetWindowsStartupScript(daemonScript)
 
+		graceScript, err := scripts.ReadFile(shutdownGraceWindowsURL)
+		if err != nil {
+			return err
+		}
+		gracevm.SetWindowsShutdownScript(string(graceScript))
+
 		sysprepspecialize, err := t.CreateTestVM("sysprepspecialize")
 		if err != nil {
 			return err
@@ -141,6 +214,27 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		sysprepspecialize.AddMetadata("sysprep-specialize-script-cmd", `pwsh -Command "Invoke-RestMethod -Method Put -Body startup_success -Headers @{'Metadata-Flavor' = 'Google'} -Uri 'http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/testing/result' -ContentType 'application/json; charset=utf-8' -UseBasicParsing"`)
 		sysprepspecialize.RunTests("TestSysprepSpecialize")
 
+		scriptsInst := &daisy.Instance{}
+		scriptsInst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
+		scriptsvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "winmetadatascripts"}}, scriptsInst)
+		if err != nil {
+			return err
+		}
+		startup, err := scripts.ReadFile(metadataScriptsStartupWindowsURL)
+		if err != nil {
+			return err
+		}
+		shutdown, err := scripts.ReadFile(metadataScriptsShutdownWindowsURL)
+		if err != nil {
+			return err
+		}
+		scriptsvm.SetWindowsStartupScript(string(startup))
+		scriptsvm.SetWindowsShutdownScript(string(shutdown))
+		if err := scriptsvm.Reboot(); err != nil {
+			return err
+		}
+		scriptsvm.RunTests("TestWindowsMetadataScripts")
+
 	} else {
 		startupByteArr, err = scripts.ReadFile(startupScriptLinuxURL)
 		if err != nil {
@@ -164,10 +258,16 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		vm6.SetStartupScript(startupScript)
 		vm7.SetStartupScript(strings.Repeat("a", metadataMaxLength))
 		vm8.SetStartupScript(daemonScript)
+
+		graceScript, err := scripts.ReadFile(shutdownGraceLinuxURL)
+		if err != nil {
+			return err
+		}
+		gracevm.SetShutdownScript(string(graceScript))
 	}
 
 	// Run the tests after setup is complete.
-	vm.RunTests("TestTokenFetch|TestMetaDataResponseHeaders|TestGetMetaDataUsingIP")
+	vm.RunTests("TestTokenFetch|TestMetaDataResponseHeaders|TestGetMetaDataUsingIP|TestMetadataWaitForChange|TestMetadataPrecedence|TestMaintenanceEventPolling|TestMetadataConcurrency")
 	vm2.RunTests("TestShutdownScripts")
 	vm3.RunTests("TestShutdownScriptsFailed")
 	vm4.RunTests("TestShutdownURLScripts")
@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// shutdownGraceSlack is how far the measured shutdown script runtime may be
+// from the configured window, to allow for script startup and the one second
+// granularity of the script's log.
+const shutdownGraceSlack = 10
+
+func shutdownGraceFiles() (runner, log string) {
+	if utils.IsWindows() {
+		return `C:\Program Files\Google\Compute Engine\metadata_scripts\GCEMetadataScripts.exe`, `C:\shutdown-grace.txt`
+	}
+	return "/usr/bin/google_metadata_script_runner", "/shutdown-grace.txt"
+}
+
+// shutdownGraceSupported returns whether the metadata script runner knows the
+// shutdown grace metadata key.
+func shutdownGraceSupported(runner string) (bool, error) {
+	data, err := os.ReadFile(runner)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Contains(data, []byte(shutdownGraceKey)), nil
+}
+
+// shutdownScriptRuntime returns how many seconds the shutdown script logged
+// timestamps for.
+func shutdownScriptRuntime(log string) (int64, error) {
+	data, err := os.ReadFile(log)
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Fields(string(data))
+	if len(lines) == 0 {
+		return 0, fmt.Errorf("%s is empty", log)
+	}
+	first, err := strconv.ParseInt(lines[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed timestamp %q in %s", lines[0], log)
+	}
+	last, err := strconv.ParseInt(lines[len(lines)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed timestamp %q in %s", lines[len(lines)-1], log)
+	}
+	return last - first, nil
+}
+
+// TestShutdownGraceConfig validates that a shutdown script is stopped once
+// the window configured in metadata has passed.
+func TestShutdownGraceConfig(t *testing.T) {
+	runner, log := shutdownGraceFiles()
+	supported, err := shutdownGraceSupported(runner)
+	if err != nil {
+		t.Fatalf("could not read metadata script runner: %v", err)
+	}
+	if !supported {
+		t.Skipf("metadata script runner does not support %s", shutdownGraceKey)
+	}
+	guard := utils.DefaultRebootGuard()
+	state, err := guard.State(t.Name())
+	if err != nil {
+		t.Fatalf("could not get reboot state: %v", err)
+	}
+
+	switch state {
+	case utils.RebootNone:
+		// first boot
+		if err := os.Remove(log); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("could not clear shutdown script log: %v", err)
+		}
+		if err := guard.Begin(t.Name()); err != nil {
+			t.Fatal(err)
+		}
+		return
+	case utils.RebootPending:
+		t.Fatal("instance did not reboot")
+	}
+
+	// second boot
+	t.Cleanup(func() { guard.Release(t.Name()) })
+	runtime, err := shutdownScriptRuntime(log)
+	if err != nil {
+		t.Fatalf("could not read shutdown script log: %v", err)
+	}
+	t.Logf("shutdown script ran for %ds with %s set to %ds", runtime, shutdownGraceKey, shutdownGraceSeconds)
+	if runtime > shutdownGraceSeconds+shutdownGraceSlack {
+		t.Errorf("shutdown script ran for %ds, past the %ds window set in %s", runtime, shutdownGraceSeconds, shutdownGraceKey)
+	}
+	if runtime < shutdownGraceSeconds-shutdownGraceSlack {
+		t.Errorf("shutdown script was stopped after %ds, before the %ds window set in %s", runtime, shutdownGraceSeconds, shutdownGraceKey)
+	}
+}
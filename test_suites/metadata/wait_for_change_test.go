@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	waitForChangeKey = "cit-wait-for-change"
+	// waitForChangeTimeout is the timeout_sec of the long-poll which should
+	// return early with the update.
+	waitForChangeTimeout = 120
+	// waitForChangeLatency is how long after the update completes the
+	// long-poll may take to return.
+	waitForChangeLatency = 30 * time.Second
+)
+
+// TestMetadataWaitForChange validates that a wait_for_change long-poll times
+// out without a change, and returns promptly with the new value when the
+// entry is updated through the compute API.
+func TestMetadataWaitForChange(t *testing.T) {
+	ctx := utils.Context(t)
+	initial, updated := "initial", "updated"
+	if err := setInstanceAttribute(ctx, waitForChangeKey, &initial); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := setInstanceAttribute(ctx, waitForChangeKey, nil); err != nil {
+			t.Errorf("could not remove %s from metadata: %v", waitForChangeKey, err)
+		}
+	})
+	var etag string
+	for start := time.Now(); time.Since(start) < time.Minute; time.Sleep(time.Second) {
+		value, headers, err := utils.GetMetadataWithHeaders(ctx, "instance", "attributes", waitForChangeKey)
+		if err == nil && value == initial {
+			etag = headers.Get("ETag")
+			break
+		}
+	}
+	if etag == "" {
+		t.Fatalf("metadata server did not serve %s=%s with an ETag", waitForChangeKey, initial)
+	}
+
+	// Without a change, the long-poll returns the same value and ETag once
+	// timeout_sec passes.
+	start := time.Now()
+	value, newETag, err := utils.GetMetadataWaitForChangeETag(ctx, etag, 5, "instance", "attributes", waitForChangeKey)
+	if err != nil {
+		t.Fatalf("long-poll without a change failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 4*time.Second {
+		t.Errorf("long-poll without a change returned after %v, before its 5s timeout", elapsed)
+	}
+	if value != initial || newETag != etag {
+		t.Errorf("long-poll without a change returned %q with ETag %s, want %q with ETag %s", value, newETag, initial, etag)
+	}
+
+	type result struct {
+		value, etag string
+		err         error
+		at          time.Time
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, etag, err := utils.GetMetadataWaitForChangeETag(ctx, etag, waitForChangeTimeout, "instance", "attributes", waitForChangeKey)
+		done <- result{value, etag, err, time.Now()}
+	}()
+	// Give the long-poll time to be established before the update.
+	time.Sleep(2 * time.Second)
+	if err := setInstanceAttribute(ctx, waitForChangeKey, &updated); err != nil {
+		t.Fatal(err)
+	}
+	updatedAt := time.Now()
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("long-poll for the update failed: %v", r.err)
+	}
+	t.Logf("long-poll returned %v after the update completed", r.at.Sub(updatedAt))
+	if r.value != updated {
+		t.Errorf("long-poll returned %q, want %q", r.value, updated)
+	}
+	if r.etag == etag {
+		t.Errorf("long-poll returned the old ETag %s with the updated value", etag)
+	}
+	if latency := r.at.Sub(updatedAt); latency > waitForChangeLatency {
+		t.Errorf("long-poll returned %v after the update completed, want under %v", latency, waitForChangeLatency)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// windowsScriptsLog is where the startup and shutdown scripts of
+// TestWindowsMetadataScripts log each run.
+const windowsScriptsLog = `C:\cit-metadata-scripts.txt`
+
+// scriptRuns returns the kind of each logged script run, in order.
+func scriptRuns(t *testing.T, want int) []string {
+	t.Helper()
+	var runs []string
+	// The startup script may still be running when the test starts.
+	for start := time.Now(); time.Since(start) < 2*time.Minute; time.Sleep(5 * time.Second) {
+		data, err := os.ReadFile(windowsScriptsLog)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatalf("could not read %s: %v", windowsScriptsLog, err)
+		}
+		runs = nil
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if kind, _, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+				runs = append(runs, kind)
+			}
+		}
+		if len(runs) >= want {
+			break
+		}
+	}
+	return runs
+}
+
+// logScriptEvents reports what the metadata script runner logged to the event
+// log.
+func logScriptEvents(t *testing.T) {
+	t.Helper()
+	output, err := utils.RunPowershellCmd(`Get-WinEvent -LogName Application,System -MaxEvents 20 -ErrorAction SilentlyContinue | Where-Object { $_.ProviderName -like 'GCEMetadataScripts*' -or $_.Message -like '*windows-*-script-ps1*' } | Format-List TimeCreated,ProviderName,Message`)
+	if err != nil {
+		t.Logf("could not read event log: %v %s", err, output.Stderr)
+		return
+	}
+	t.Logf("metadata script events:\n%s", strings.TrimSpace(output.Stdout))
+}
+
+// TestWindowsMetadataScripts validates that windows-startup-script-ps1 runs on
+// every boot and windows-shutdown-script-ps1 runs when the instance stops.
+func TestWindowsMetadataScripts(t *testing.T) {
+	utils.WindowsOnly(t)
+	guard := utils.DefaultRebootGuard()
+	state, err := guard.State(t.Name())
+	if err != nil {
+		t.Fatalf("could not get reboot state: %v", err)
+	}
+
+	switch state {
+	case utils.RebootNone:
+		// first boot
+		runs := scriptRuns(t, 1)
+		logScriptEvents(t)
+		if len(runs) != 1 || runs[0] != "startup" {
+			t.Fatalf("script runs on first boot are %v, want [startup]", runs)
+		}
+		if err := guard.Begin(t.Name()); err != nil {
+			t.Fatal(err)
+		}
+		return
+	case utils.RebootPending:
+		t.Fatal("instance did not reboot")
+	}
+
+	// second boot
+	t.Cleanup(func() { guard.Release(t.Name()) })
+	runs := scriptRuns(t, 3)
+	logScriptEvents(t)
+	want := []string{"startup", "shutdown", "startup"}
+	if strings.Join(runs, " ") != strings.Join(want, " ") {
+		t.Errorf("script runs across the reboot are %v, want %v", runs, want)
+	}
+}
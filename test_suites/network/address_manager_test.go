@@ -0,0 +1,142 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+// addressManagerTimeout is how long the guest agent has to apply an alias IP
+// change.
+const addressManagerTimeout = 5 * time.Minute
+
+// setAliasIPRanges replaces the alias IP ranges of nic0 on the instance.
+func setAliasIPRanges(ctx context.Context, client *compute.InstancesClient, project, zone, name string, ranges []*computepb.AliasIpRange) error {
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: project, Zone: zone, Instance: name})
+	if err != nil {
+		return err
+	}
+	if len(inst.GetNetworkInterfaces()) == 0 {
+		return fmt.Errorf("instance %s has no network interfaces", name)
+	}
+	op, err := client.UpdateNetworkInterface(ctx, &computepb.UpdateNetworkInterfaceInstanceRequest{
+		Project:          project,
+		Zone:             zone,
+		Instance:         name,
+		NetworkInterface: inst.GetNetworkInterfaces()[0].GetName(),
+		NetworkInterfaceResource: &computepb.NetworkInterface{
+			AliasIpRanges: ranges,
+			Fingerprint:   inst.GetNetworkInterfaces()[0].Fingerprint,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// interfaceState returns the addresses and guest agent routes of iface for
+// logging.
+func interfaceState(iface string) string {
+	addrs, err := exec.Command("ip", "address", "show", "dev", iface).CombinedOutput()
+	if err != nil {
+		addrs = []byte(err.Error())
+	}
+	routes, err := exec.Command("ip", "route", "list", "table", "local", "dev", iface, "proto", "66").CombinedOutput()
+	if err != nil {
+		routes = []byte(err.Error())
+	}
+	return fmt.Sprintf("%s\nguest agent routes:\n%s", addrs, routes)
+}
+
+// TestAddressManagerReconfig validates that the guest agent applies alias IP
+// changes made through the compute API without a reboot.
+func TestAddressManagerReconfig(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("google_guest_agent") {
+		t.Skip("guest agent is not installed")
+	}
+	ctx := utils.Context(t)
+	iface, err := utils.GetInterface(ctx, 0)
+	if err != nil {
+		t.Fatalf("couldn't get interface: %v", err)
+	}
+	project, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	routes, err := getGoogleRoutes(iface.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isInRoutes(routes, addressManagerAlias) {
+		t.Fatalf("initial alias %s is not routed, routes are %v", addressManagerAlias, routes)
+	}
+	t.Logf("interface %s before alias change:\n%s", iface.Name, interfaceState(iface.Name))
+
+	t.Cleanup(func() {
+		original := []*computepb.AliasIpRange{{IpCidrRange: proto.String(addressManagerAlias), SubnetworkRangeName: proto.String(secondaryRangeName)}}
+		if err := setAliasIPRanges(context.Background(), client, project, zone, name, original); err != nil {
+			t.Errorf("could not restore alias IP range %s: %v", addressManagerAlias, err)
+		}
+	})
+	newRanges := []*computepb.AliasIpRange{{IpCidrRange: proto.String(addressManagerNewAlias), SubnetworkRangeName: proto.String(secondaryRangeName)}}
+	if err := setAliasIPRanges(ctx, client, project, zone, name, newRanges); err != nil {
+		t.Fatalf("could not change alias IP range to %s: %v", addressManagerNewAlias, err)
+	}
+
+	for start := time.Now(); time.Since(start) < addressManagerTimeout; {
+		// getGoogleRoutes waits for the guest agent before listing routes.
+		routes, err = getGoogleRoutes(iface.Name)
+		if err == nil && isInRoutes(routes, addressManagerNewAlias) && !isInRoutes(routes, addressManagerAlias) {
+			break
+		}
+	}
+	t.Logf("interface %s after alias change:\n%s", iface.Name, interfaceState(iface.Name))
+	if !isInRoutes(routes, addressManagerNewAlias) {
+		t.Errorf("new alias %s was not routed within %v, routes are %v", addressManagerNewAlias, addressManagerTimeout, routes)
+	}
+	if isInRoutes(routes, addressManagerAlias) {
+		t.Errorf("old alias %s was not removed within %v, routes are %v", addressManagerAlias, addressManagerTimeout, routes)
+	}
+}
+
+func isInRoutes(routes []string, cidr string) bool {
+	for _, r := range routes {
+		if r == cidr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// metadataDNS is the metadata server's DNS proxy.
+const metadataDNS = "169.254.169.254"
+
+// resolvConfValues returns the values of every line for key in
+// /etc/resolv.conf.
+func resolvConfValues(key string) ([]string, error) {
+	content, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == key {
+			values = append(values, fields[1:]...)
+		}
+	}
+	return values, nil
+}
+
+// lookupThroughMetadataDNS resolves host by querying the metadata server's
+// DNS proxy directly.
+func lookupThroughMetadataDNS(ctx context.Context, host string) ([]string, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(metadataDNS, "53"))
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return r.LookupHost(ctx, host)
+}
+
+// TestInternalDNSProxy validates that an internal name, such as another
+// instance or a record in a private Cloud DNS zone, resolves through the
+// metadata server and that the internal search domains are applied.
+func TestInternalDNSProxy(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	name, err := utils.GetMetadata(ctx, "instance", "attributes", "internal-dns-name")
+	if err != nil || name == "" {
+		t.Skip("no internal DNS name to resolve is set in metadata")
+	}
+	project, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+
+	search, err := resolvConfValues("search")
+	if err != nil {
+		t.Fatalf("could not read search domains: %v", err)
+	}
+	t.Logf("search domains: %v", search)
+	// Projects with a domain use domain.com:project, which is
+	// project.domain.com in DNS.
+	projectDomain := project
+	if domain, id, ok := strings.Cut(project, ":"); ok {
+		projectDomain = id + "." + domain
+	}
+	applied := make(map[string]bool)
+	for _, domain := range search {
+		applied[strings.TrimSuffix(domain, ".")] = true
+	}
+	// Projects use either zonal or global internal DNS, so only one of the
+	// project domains has to be applied.
+	zonal, global := zone+".c."+projectDomain+".internal", "c."+projectDomain+".internal"
+	if !applied[zonal] && !applied[global] {
+		t.Errorf("neither search domain %s nor %s is applied", zonal, global)
+	}
+	if !applied["google.internal"] {
+		t.Errorf("search domain google.internal is not applied")
+	}
+
+	addrs, err := lookupThroughMetadataDNS(ctx, name)
+	if err != nil {
+		t.Fatalf("could not resolve %s through %s: %v", name, metadataDNS, err)
+	}
+	t.Logf("%s resolves to %v through %s", name, addrs, metadataDNS)
+
+	// The system resolver should find the same name by its first label
+	// when the rest of it is a search domain.
+	short, rest, _ := strings.Cut(strings.TrimSuffix(name, "."), ".")
+	for _, domain := range search {
+		if strings.TrimSuffix(domain, ".") != rest {
+			continue
+		}
+		out, err := exec.CommandContext(ctx, "getent", "hosts", short).Output()
+		if err != nil {
+			t.Errorf("could not resolve %s with search domain %s: %v", short, domain, err)
+		} else {
+			t.Logf("%s resolves to %s", short, strings.TrimSpace(string(out)))
+		}
+		break
+	}
+}
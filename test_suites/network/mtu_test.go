@@ -15,8 +15,10 @@
 package network
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
@@ -26,27 +28,69 @@ const (
 	gceMTU = 1460
 )
 
+// skipOldSysprep skips the test on Windows images with a version of
+// gcesysprep which doesn't set the interface MTU.
+func skipOldSysprep(t *testing.T) {
+	t.Helper()
+	if !utils.IsWindows() {
+		return
+	}
+	sysprepInstalled, err := utils.RunPowershellCmd(`googet installed google-compute-engine-sysprep.noarch | Select-Object -Index 1`)
+	if err != nil {
+		t.Fatalf("could not check installed sysprep version: %v", err)
+	}
+	// YYYYMMDD
+	sysprepVerRe := regexp.MustCompile("[0-9]{8}")
+	sysprepVer, err := strconv.Atoi(sysprepVerRe.FindString(sysprepInstalled.Stdout))
+	if err != nil {
+		t.Fatalf("could not determine value of sysprep version: %v", err)
+	}
+	if sysprepVer <= 20240104 {
+		t.Skipf("version %d of gcesysprep is too old to set interface mtu correctly", sysprepVer)
+	}
+}
+
 func TestDefaultMTU(t *testing.T) {
 	iface, err := utils.GetInterface(utils.Context(t), 0)
 	if err != nil {
 		t.Fatalf("couldn't find primary NIC: %v", err)
 	}
-	if utils.IsWindows() {
-		sysprepInstalled, err := utils.RunPowershellCmd(`googet installed google-compute-engine-sysprep.noarch | Select-Object -Index 1`)
+	skipOldSysprep(t)
+	if iface.MTU != gceMTU {
+		t.Fatalf("expected MTU %d on interface %s, got MTU %d", gceMTU, iface.Name, iface.MTU)
+	}
+}
+
+// TestSecondaryNICMTU validates that every interface, not just the primary,
+// has the MTU of its network.
+func TestSecondaryNICMTU(t *testing.T) {
+	ctx := utils.Context(t)
+	nics, err := utils.GetMetadata(ctx, "instance", "network-interfaces")
+	if err != nil {
+		t.Fatalf("couldn't list network interfaces from metadata: %v", err)
+	}
+	count := len(strings.Fields(nics))
+	if count < 2 {
+		t.Skip("instance has a single network interface")
+	}
+	skipOldSysprep(t)
+	for i := 0; i < count; i++ {
+		expected, err := utils.GetMetadata(ctx, "instance", "network-interfaces", fmt.Sprintf("%d", i), "mtu")
 		if err != nil {
-			t.Fatalf("could not check installed sysprep version: %v", err)
+			t.Fatalf("couldn't get MTU of interface %d from metadata: %v", i, err)
 		}
-		// YYYYMMDD
-		sysprepVerRe := regexp.MustCompile("[0-9]{8}")
-		sysprepVer, err := strconv.Atoi(sysprepVerRe.FindString(sysprepInstalled.Stdout))
+		mtu, err := strconv.Atoi(strings.TrimSpace(expected))
 		if err != nil {
-			t.Fatalf("could not determine value of sysprep version: %v", err)
+			t.Fatalf("metadata MTU %q of interface %d is not a number: %v", expected, i, err)
 		}
-		if sysprepVer <= 20240104 {
-			t.Skipf("version %d of gcesysprep is too old to set interface mtu correctly", sysprepVer)
+		iface, err := utils.GetInterface(ctx, i)
+		if err != nil {
+			t.Errorf("couldn't find interface %d: %v", i, err)
+			continue
+		}
+		t.Logf("interface %d (%s) has MTU %d, network MTU is %d", i, iface.Name, iface.MTU, mtu)
+		if iface.MTU != mtu {
+			t.Errorf("expected MTU %d on interface %s, got MTU %d", mtu, iface.Name, iface.MTU)
 		}
-	}
-	if iface.MTU != gceMTU {
-		t.Fatalf("expected MTU %d on interface %s, got MTU %d", gceMTU, iface.Name, iface.MTU)
 	}
 }
@@ -16,6 +16,7 @@
 package network
 
 import (
+	"flag"
 	"regexp"
 	"strings"
 
@@ -37,6 +38,20 @@ type InstanceConfig struct {
 var vm1Config = InstanceConfig{name: "ping1", ip: "192.168.0.2"}
 var vm2Config = InstanceConfig{name: "ping2", ip: "192.168.0.3"}
 
+var internalDNSName = flag.String("network_internal_dns_name", "", "internal DNS name, such as a record in a private Cloud DNS zone visible to the default network, for TestInternalDNSProxy and TestWindowsDNSSearchPath to resolve. The test is skipped if unset")
+
+const (
+	// secondaryRangeName is the secondary range of subnetwork-1 which alias IP
+	// ranges are allocated from.
+	secondaryRangeName = "secondary-range"
+	// addressManagerAlias is the alias IP range the address manager VM starts
+	// with.
+	addressManagerAlias = "10.14.10.0/24"
+	// addressManagerNewAlias is the alias IP range the address manager VM
+	// switches to during the test.
+	addressManagerNewAlias = "10.14.11.0/24"
+)
+
 // TestSetup sets up the test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
 	network1, err := t.CreateNetwork("network-1", false)
@@ -47,7 +62,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	if err != nil {
 		return err
 	}
-	subnetwork1.AddSecondaryRange("secondary-range", "10.14.0.0/16")
+	subnetwork1.AddSecondaryRange(secondaryRangeName, "10.14.0.0/16")
 	if err := network1.CreateFirewallRule("allow-tcp-net1", "tcp", nil, []string{"10.128.0.0/20"}); err != nil {
 		return err
 	}
@@ -77,10 +92,11 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	if err := vm1.SetPrivateIP(network2, vm1Config.ip); err != nil {
 		return err
 	}
-	vm1.RunTests("TestSendPing|TestDHCP|TestDefaultMTU")
+	vm1.RunTests("TestSendPing|TestDHCP|TestDefaultMTU|TestSecondaryNICMTU|TestTCPTuningUnderLoad")
 
 	multinictests := "TestStaticIP|TestWaitForPing"
-	if !utils.HasFeature(t.Image, "WINDOWS") && !strings.Contains(t.Image.Name, "sles-15") && !strings.Contains(t.Image.Name, "opensuse-leap") && !strings.Contains(t.Image.Name, "ubuntu-1604") && !strings.Contains(t.Image.Name, "ubuntu-pro-1604") && !strings.Contains(t.Image.Name, "cos") {
+	aliasSupported := !utils.HasFeature(t.Image, "WINDOWS") && !strings.Contains(t.Image.Name, "sles-15") && !strings.Contains(t.Image.Name, "opensuse-leap") && !strings.Contains(t.Image.Name, "ubuntu-1604") && !strings.Contains(t.Image.Name, "ubuntu-pro-1604") && !strings.Contains(t.Image.Name, "cos")
+	if aliasSupported {
 		multinictests += "|TestAlias"
 	}
 
@@ -92,6 +108,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		return err
 	}
 	vm2.AddMetadata("enable-guest-attributes", "TRUE")
+	vm2.AddMetadata("static-ip", vm2Config.ip)
 	if err := vm2.AddCustomNetwork(network1, subnetwork1); err != nil {
 		return err
 	}
@@ -101,7 +118,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	if err := vm2.SetPrivateIP(network2, vm2Config.ip); err != nil {
 		return err
 	}
-	if err := vm2.AddAliasIPRanges("10.14.8.0/24", "secondary-range"); err != nil {
+	if err := vm2.AddAliasIPRanges("10.14.8.0/24", secondaryRangeName); err != nil {
 		return err
 	}
 	if err := vm2.Reboot(); err != nil {
@@ -123,5 +140,34 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		vm3.UseGVNIC()
 	}
 
+	if aliasSupported {
+		vm4, err := t.CreateTestVM("addressmgr")
+		if err != nil {
+			return err
+		}
+		if err := vm4.AddCustomNetwork(network1, subnetwork1); err != nil {
+			return err
+		}
+		if err := vm4.AddAliasIPRanges(addressManagerAlias, secondaryRangeName); err != nil {
+			return err
+		}
+		vm4.AddScope("https://www.googleapis.com/auth/cloud-platform")
+		vm4.RunTests("TestAddressManagerReconfig")
+	}
+
+	if *internalDNSName != "" {
+		// Uses the default network, where private zones are usually visible.
+		vm5, err := t.CreateTestVM("internaldns")
+		if err != nil {
+			return err
+		}
+		vm5.AddMetadata("internal-dns-name", *internalDNSName)
+		if utils.HasFeature(t.Image, "WINDOWS") {
+			vm5.RunTests("TestWindowsDNSSearchPath")
+		} else {
+			vm5.RunTests("TestInternalDNSProxy")
+		}
+	}
+
 	return nil
 }
@@ -17,9 +17,13 @@ package network
 import (
 	"fmt"
 	"math/bits"
+	"net"
+	"os"
+	"os/exec"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
 )
@@ -76,6 +80,112 @@ func TestStaticIP(t *testing.T) {
 		}
 		t.Errorf("no address for interface %s with ip %s was found", ifaceIndex, expectedIP)
 	}
+	checkStaticIPRetained(t)
+}
+
+// checkStaticIPRetained checks that the reserved IP in the static-ip metadata
+// attribute stays configured across a DHCP renewal on the first boot, and
+// across the reboot. A marker file records that the first boot's checks ran.
+func checkStaticIPRetained(t *testing.T) {
+	t.Helper()
+	staticIP, err := utils.GetMetadata(utils.Context(t), "instance", "attributes", "static-ip")
+	if err != nil || staticIP == "" {
+		t.Log("no static IP in metadata, not checking it is retained")
+		return
+	}
+	marker := "/var/cit-static-ip"
+	if utils.IsWindows() {
+		marker = `C:\cit-static-ip`
+	}
+	if _, err := os.Stat(marker); err == nil {
+		iface, addrs := findLocalIP(t, staticIP)
+		t.Logf("after reboot, assigned static IP %s, observed %v on interface %s", staticIP, addrs, iface)
+		if iface == "" {
+			t.Errorf("static IP %s was not retained across the reboot", staticIP)
+		}
+		return
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("could not check for %s: %v", marker, err)
+	}
+
+	iface, addrs := findLocalIP(t, staticIP)
+	t.Logf("assigned static IP %s, observed %v on interface %s", staticIP, addrs, iface)
+	if iface == "" {
+		t.Errorf("static IP %s is not configured", staticIP)
+		return
+	}
+	if err := renewDHCP(iface); err != nil {
+		t.Logf("could not renew DHCP lease on %s, not checking static IP across a renewal: %v", iface, err)
+	} else {
+		var renewed string
+		for i := 0; i < 30 && renewed == ""; i++ {
+			time.Sleep(time.Second)
+			renewed, addrs = findLocalIP(t, staticIP)
+		}
+		t.Logf("after DHCP renewal, assigned static IP %s, observed %v on interface %s", staticIP, addrs, renewed)
+		if renewed == "" {
+			t.Errorf("static IP %s was not retained across a DHCP renewal", staticIP)
+		}
+	}
+	if err := os.WriteFile(marker, []byte(staticIP), 0644); err != nil {
+		t.Errorf("could not write %s: %v", marker, err)
+	}
+}
+
+// findLocalIP returns the name of the interface with address ip and all of
+// that interface's addresses, or an empty name if no interface has it.
+func findLocalIP(t *testing.T, ip string) (string, []string) {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("could not list interfaces: %v", err)
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		var found bool
+		var observed []string
+		for _, addr := range addrs {
+			observed = append(observed, addr.String())
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.String() == ip {
+				found = true
+			}
+		}
+		if found {
+			return iface.Name, observed
+		}
+	}
+	return "", nil
+}
+
+// renewDHCP renews the DHCP lease of iface with whichever tool manages it.
+func renewDHCP(iface string) error {
+	if utils.IsWindows() {
+		_, err := utils.RunPowershellCmd(fmt.Sprintf(`ipconfig /renew "%s"`, iface))
+		return err
+	}
+	var errs []string
+	for _, cmd := range [][]string{
+		{"networkctl", "renew", iface},
+		{"nmcli", "device", "reapply", iface},
+		{"wicked", "ifreload", iface},
+		{"dhclient", "-1", iface},
+	} {
+		if !utils.CheckLinuxCmdExists(cmd[0]) {
+			continue
+		}
+		out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v %s", cmd[0], err, strings.TrimSpace(string(out))))
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no DHCP client found")
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
 }
 
 func suffixFromMask(mask string) string {
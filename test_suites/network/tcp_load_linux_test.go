@@ -0,0 +1,183 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// tcpLoadConnections is the number of concurrent connections held open.
+	tcpLoadConnections = 20000
+	tcpLoadWorkers     = 64
+)
+
+// readSysctl returns the value of the sysctl at path relative to /proc/sys.
+func readSysctl(path string) (string, error) {
+	data, err := os.ReadFile("/proc/sys/" + path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// TestTCPTuningUnderLoad validates that the network stack can hold many
+// concurrent TCP connections without running out of ephemeral ports or
+// conntrack entries.
+func TestTCPTuningUnderLoad(t *testing.T) {
+	utils.LinuxOnly(t)
+
+	portRange, err := readSysctl("net/ipv4/ip_local_port_range")
+	if err != nil {
+		t.Fatalf("could not read ip_local_port_range: %v", err)
+	}
+	ports := strings.Fields(portRange)
+	if len(ports) != 2 {
+		t.Fatalf("unexpected ip_local_port_range %q", portRange)
+	}
+	low, err1 := strconv.Atoi(ports[0])
+	high, err2 := strconv.Atoi(ports[1])
+	if err1 != nil || err2 != nil {
+		t.Fatalf("unexpected ip_local_port_range %q", portRange)
+	}
+	t.Logf("net.ipv4.ip_local_port_range = %d %d (%d ports)", low, high, high-low+1)
+	if high-low+1 < tcpLoadConnections {
+		t.Errorf("ephemeral port range %d-%d has fewer than %d ports", low, high, tcpLoadConnections)
+	}
+	for _, s := range []string{"net/core/somaxconn", "net/ipv4/tcp_max_syn_backlog", "net/ipv4/tcp_tw_reuse", "fs/file-max"} {
+		if v, err := readSysctl(s); err == nil {
+			t.Logf("%s = %s", strings.ReplaceAll(s, "/", "."), v)
+		}
+	}
+	conntrackMax, err := readSysctl("net/netfilter/nf_conntrack_max")
+	conntrack := err == nil
+	if conntrack {
+		t.Logf("net.netfilter.nf_conntrack_max = %s", conntrackMax)
+		if max, err := strconv.Atoi(conntrackMax); err == nil && max < tcpLoadConnections {
+			t.Errorf("nf_conntrack_max %d is less than %d connections", max, tcpLoadConnections)
+		}
+	} else {
+		t.Log("conntrack is not loaded")
+	}
+
+	// Each connection uses a descriptor on both ends.
+	target := tcpLoadConnections
+	limit := &syscall.Rlimit{Cur: 2*tcpLoadConnections + 1024, Max: 2*tcpLoadConnections + 1024}
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, limit); err != nil {
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, limit); err != nil {
+			t.Fatalf("could not get open file limit: %v", err)
+		}
+		limit.Cur = limit.Max
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, limit); err != nil {
+			t.Fatalf("could not raise open file limit: %v", err)
+		}
+		if max := (int(limit.Max) - 1024) / 2; max < target {
+			t.Logf("open file limit %d allows only %d connections", limit.Max, max)
+			target = max
+		}
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	defer func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var opened int
+	work := make(chan struct{})
+	for i := 0; i < tcpLoadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range work {
+				c, err := net.DialTimeout("tcp4", ln.Addr().String(), 10*time.Second)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					opened++
+					conns = append(conns, c)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	start := time.Now()
+	for i := 0; i < target; i++ {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		work <- struct{}{}
+	}
+	close(work)
+	wg.Wait()
+	t.Logf("opened %d of %d connections in %v", opened, target, time.Since(start))
+
+	if conntrack {
+		if count, err := readSysctl("net/netfilter/nf_conntrack_count"); err == nil {
+			t.Logf("net.netfilter.nf_conntrack_count = %s", count)
+		}
+		if out, err := exec.Command("dmesg").Output(); err == nil && strings.Contains(string(out), "nf_conntrack: table full") {
+			t.Errorf("conntrack table filled up")
+		}
+	}
+	switch {
+	case firstErr == nil:
+	case errors.Is(firstErr, syscall.EADDRNOTAVAIL):
+		t.Errorf("ran out of ephemeral ports after %d connections: %v", opened, firstErr)
+	case errors.Is(firstErr, syscall.EMFILE), errors.Is(firstErr, syscall.ENFILE):
+		t.Errorf("ran out of file descriptors after %d connections: %v", opened, firstErr)
+	default:
+		t.Errorf("connection failed after %d connections: %v", opened, firstErr)
+	}
+}
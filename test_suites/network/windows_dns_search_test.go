@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// powershellList runs a PowerShell command and returns the non-empty lines it
+// prints.
+func powershellList(cmd string) ([]string, error) {
+	out, err := utils.RunPowershellCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%v %s", err, out.Stderr)
+	}
+	var lines []string
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// TestWindowsDNSSearchPath validates that Windows applies the internal DNS
+// suffixes of the VPC, and that an internal name resolves both through the
+// metadata server and by its short name through the suffix search list.
+func TestWindowsDNSSearchPath(t *testing.T) {
+	utils.WindowsOnly(t)
+	ctx := utils.Context(t)
+	name, err := utils.GetMetadata(ctx, "instance", "attributes", "internal-dns-name")
+	if err != nil || name == "" {
+		t.Skip("no internal DNS name to resolve is set in metadata")
+	}
+	project, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	iface, err := utils.GetInterface(ctx, 0)
+	if err != nil {
+		t.Fatalf("could not find primary interface: %v", err)
+	}
+
+	global, err := powershellList(`(Get-DnsClientGlobalSetting).SuffixSearchList`)
+	if err != nil {
+		t.Fatalf("could not get DNS suffix search list: %v", err)
+	}
+	connection, err := powershellList(fmt.Sprintf(`(Get-DnsClient -InterfaceAlias '%s').ConnectionSpecificSuffix`, iface.Name))
+	if err != nil {
+		t.Fatalf("could not get DNS suffix of %s: %v", iface.Name, err)
+	}
+	t.Logf("suffix search list: %v, %s connection suffix: %v", global, iface.Name, connection)
+
+	// Windows searches the connection suffix as well as the global list.
+	applied := make(map[string]bool)
+	for _, suffix := range append(global, connection...) {
+		applied[strings.ToLower(strings.TrimSuffix(suffix, "."))] = true
+	}
+	// Projects with a domain use domain.com:project, which is
+	// project.domain.com in DNS.
+	projectDomain := project
+	if domain, id, ok := strings.Cut(project, ":"); ok {
+		projectDomain = id + "." + domain
+	}
+	// Projects use either zonal or global internal DNS, so only one of the
+	// project suffixes has to be applied.
+	zonal, projectGlobal := zone+".c."+projectDomain+".internal", "c."+projectDomain+".internal"
+	if !applied[zonal] && !applied[projectGlobal] {
+		t.Errorf("neither DNS suffix %s nor %s is applied", zonal, projectGlobal)
+	}
+
+	addrs, err := powershellList(fmt.Sprintf(`Resolve-DnsName -Name '%s' -Server %s -DnsOnly -Type A | Where-Object { $_.IPAddress } | ForEach-Object { $_.IPAddress }`, name, metadataDNS))
+	if err != nil || len(addrs) == 0 {
+		t.Fatalf("could not resolve %s through %s: %v", name, metadataDNS, err)
+	}
+	t.Logf("%s resolves to %v through %s", name, addrs, metadataDNS)
+
+	// The system resolver should find the same name by its first label
+	// when the rest of it is an applied suffix.
+	short, rest, _ := strings.Cut(strings.TrimSuffix(name, "."), ".")
+	if !applied[strings.ToLower(rest)] {
+		t.Logf("%s is not an applied suffix, not resolving %s by its short name", rest, name)
+		return
+	}
+	shortAddrs, err := powershellList(fmt.Sprintf(`Resolve-DnsName -Name '%s' -DnsOnly -Type A | Where-Object { $_.IPAddress } | ForEach-Object { $_.IPAddress }`, short))
+	if err != nil || len(shortAddrs) == 0 {
+		t.Errorf("could not resolve %s with suffix %s: %v", short, rest, err)
+	} else {
+		t.Logf("%s resolves to %v", short, shortAddrs)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkperf
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// Size of the IPv4 and ICMP headers added to a ping payload.
+	icmpOverhead = 28
+	// Largest MTU of a network without jumbo frames.
+	standardMTU = 1500
+	jumboPings  = 5
+)
+
+// pingDontFragment sends count pings with the given payload size and the
+// don't fragment bit set, and returns the number of replies received.
+func pingDontFragment(t *testing.T, peer string, size, count int) (int, string) {
+	t.Helper()
+	var out string
+	var replyRe *regexp.Regexp
+	if utils.IsWindows() {
+		res, _ := utils.RunPowershellCmd(fmt.Sprintf("ping -f -l %d -n %d %s", size, count, peer))
+		out = res.Stdout + res.Stderr
+		replyRe = regexp.MustCompile(fmt.Sprintf(`Reply from %s: bytes=%d `, regexp.QuoteMeta(peer), size))
+	} else {
+		b, _ := exec.CommandContext(utils.Context(t), "ping", "-M", "do", "-s", fmt.Sprint(size), "-c", fmt.Sprint(count), "-W", "2", peer).CombinedOutput()
+		out = string(b)
+		replyRe = regexp.MustCompile(fmt.Sprintf(`%d bytes from %s`, size+8, regexp.QuoteMeta(peer)))
+	}
+	return len(replyRe.FindAllString(out, -1)), out
+}
+
+// iperfThroughput returns the throughput in Gbits/s reported by the iperf
+// client startup script.
+func iperfThroughput(t *testing.T) (float64, error) {
+	t.Helper()
+	var results string
+	var err error
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Duration(i) * time.Second)
+		results, err = utils.GetMetadata(utils.Context(t), "instance", "guest-attributes", "testing", "results")
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("iperf results not found: %v", err)
+	}
+	fields := strings.Split(results, " ")
+	if len(fields) < 7 {
+		return 0, fmt.Errorf("unexpected iperf results %q", results)
+	}
+	perf, err := strconv.ParseFloat(fields[5], 64)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(fields[6], "G") {
+		return 0, fmt.Errorf("unexpected iperf units %q", fields[6])
+	}
+	return perf, nil
+}
+
+// TestJumboFrameThroughput validates that full sized jumbo frames reach a
+// peer on the same jumbo frame network without being fragmented, and reports
+// the throughput measured over that network.
+func TestJumboFrameThroughput(t *testing.T) {
+	ctx := utils.Context(t)
+	peer, err := utils.GetMetadata(ctx, "instance", "attributes", "jumbo-peer")
+	if err != nil || peer == "" {
+		t.Skip("no jumbo frame peer set in metadata")
+	}
+	mtuString, err := utils.GetMetadata(ctx, "instance", "network-interfaces", "0", "mtu")
+	if err != nil {
+		t.Fatalf("couldn't get network MTU from metadata: %v", err)
+	}
+	mtu, err := strconv.Atoi(strings.TrimSpace(mtuString))
+	if err != nil {
+		t.Fatalf("metadata MTU %q is not a number: %v", mtuString, err)
+	}
+	if mtu <= standardMTU {
+		t.Skipf("network MTU is %d, not a jumbo frame network", mtu)
+	}
+
+	iface, err := utils.GetInterface(ctx, 0)
+	if err != nil {
+		t.Fatalf("couldn't find primary NIC: %v", err)
+	}
+	if iface.MTU != mtu {
+		t.Fatalf("expected MTU %d on interface %s, got MTU %d", mtu, iface.Name, iface.MTU)
+	}
+
+	// The largest payload fitting in one frame must arrive intact.
+	size := mtu - icmpOverhead
+	replies, out := pingDontFragment(t, peer, size, jumboPings)
+	if replies == 0 {
+		t.Fatalf("no replies from %s to %d byte frames with fragmentation disallowed, output:\n%s", peer, mtu, out)
+	}
+	t.Logf("%d of %d frames of %d bytes (%d byte payload) delivered to %s without fragmentation", replies, jumboPings, mtu, size, peer)
+
+	// One byte more needs fragmentation, so it must not be delivered.
+	if replies, out := pingDontFragment(t, peer, size+1, 1); replies != 0 {
+		t.Errorf("%d byte frame exceeding MTU %d was delivered with fragmentation disallowed, output:\n%s", mtu+1, mtu, out)
+	}
+
+	perf, err := iperfThroughput(t)
+	if err != nil {
+		t.Fatalf("couldn't get throughput over jumbo frame network: %v", err)
+	}
+	t.Logf("throughput to %s with MTU %d: %v Gbits/s", peer, mtu, perf)
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkperf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// mixedLoadFile is the scratch file fio reads and writes.
+	mixedLoadFile = "/var/tmp/cit-mixed-load"
+	// mixedLoadSeconds is how long each load runs alone and together.
+	mixedLoadSeconds = 30
+	// mixedLoadMinShare is the smallest fraction of its throughput alone each
+	// load must keep when both run together.
+	mixedLoadMinShare = 0.25
+	// mixedLoadSetupTimeout is how long to wait for fio and iperf to be
+	// installed and for the peer to start listening.
+	mixedLoadSetupTimeout = 10 * time.Minute
+)
+
+// fioResult is the part of fio's JSON output TestMixedLoad uses.
+type fioResult struct {
+	Jobs []struct {
+		Error int `json:"error"`
+		Read  struct {
+			BWBytes float64 `json:"bw_bytes"`
+		} `json:"read"`
+		Write struct {
+			BWBytes float64 `json:"bw_bytes"`
+		} `json:"write"`
+	} `json:"jobs"`
+}
+
+// runFIO runs a random read and write load on mixedLoadFile and returns the
+// combined throughput in MB/s.
+func runFIO(ctx context.Context) (float64, error) {
+	out, err := exec.CommandContext(ctx, "fio", "--name=mixedload", "--filename="+mixedLoadFile, "--size=1G", "--rw=randrw", "--bs=64k", "--iodepth=16", "--ioengine=libaio", "--direct=1", "--time_based", "--runtime="+strconv.Itoa(mixedLoadSeconds), "--output-format=json").Output()
+	if err != nil {
+		return 0, fmt.Errorf("fio failed: %v %s", err, out)
+	}
+	var result fioResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		return 0, fmt.Errorf("could not parse fio output: %v", err)
+	}
+	if len(result.Jobs) == 0 {
+		return 0, fmt.Errorf("fio reported no jobs")
+	}
+	if result.Jobs[0].Error != 0 {
+		return 0, fmt.Errorf("fio job failed with error %d", result.Jobs[0].Error)
+	}
+	return (result.Jobs[0].Read.BWBytes + result.Jobs[0].Write.BWBytes) / 1e6, nil
+}
+
+// runIperf sends to the iperf server at peer and returns the throughput in
+// Gbits/s.
+func runIperf(ctx context.Context, peer string) (float64, error) {
+	out, err := exec.CommandContext(ctx, "iperf", "-c", peer, "-t", strconv.Itoa(mixedLoadSeconds), "-P", "4", "-y", "C").Output()
+	if err != nil {
+		return 0, fmt.Errorf("iperf failed: %v %s", err, out)
+	}
+	// With -P the last line is the sum over all streams, with the bits per
+	// second last.
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	fields := strings.Split(lines[len(lines)-1], ",")
+	bps, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected iperf output %q", lines[len(lines)-1])
+	}
+	return bps / 1e9, nil
+}
+
+// waitForMixedLoadSetup waits for fio and iperf to be installed by the
+// startup script and for the iperf server at peer to be listening.
+func waitForMixedLoadSetup(ctx context.Context, peer string) error {
+	deadline := time.Now().Add(mixedLoadSetupTimeout)
+	for {
+		var err error
+		switch {
+		case !utils.CheckLinuxCmdExists("fio"):
+			err = fmt.Errorf("fio is not installed")
+		case !utils.CheckLinuxCmdExists("iperf"):
+			err = fmt.Errorf("iperf is not installed")
+		default:
+			var conn net.Conn
+			if conn, err = net.DialTimeout("tcp", net.JoinHostPort(peer, "5001"), 5*time.Second); err == nil {
+				return conn.Close()
+			}
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// TestMixedLoad validates that disk and network throughput hold up when both
+// run at once, without either starving the other.
+func TestMixedLoad(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	peer, err := utils.GetMetadata(ctx, "instance", "attributes", "mixed-load-peer")
+	if err != nil || peer == "" {
+		t.Skip("no mixed load peer set in metadata")
+	}
+	if err := waitForMixedLoadSetup(ctx, peer); err != nil {
+		t.Fatalf("mixed load setup did not finish: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(mixedLoadFile) })
+
+	diskAlone, err := runFIO(ctx)
+	if err != nil {
+		t.Fatalf("disk load alone: %v", err)
+	}
+	netAlone, err := runIperf(ctx, peer)
+	if err != nil {
+		t.Fatalf("network load alone: %v", err)
+	}
+	t.Logf("alone: disk %.1f MB/s, network %.2f Gbits/s", diskAlone, netAlone)
+
+	var wg sync.WaitGroup
+	var diskMixed, netMixed float64
+	var diskErr, netErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		diskMixed, diskErr = runFIO(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		netMixed, netErr = runIperf(ctx, peer)
+	}()
+	wg.Wait()
+	if diskErr != nil {
+		t.Fatalf("disk load with network load: %v", diskErr)
+	}
+	if netErr != nil {
+		t.Fatalf("network load with disk load: %v", netErr)
+	}
+	t.Logf("together: disk %.1f MB/s (%.0f%% of alone), network %.2f Gbits/s (%.0f%% of alone)", diskMixed, 100*diskMixed/diskAlone, netMixed, 100*netMixed/netAlone)
+
+	if diskMixed < mixedLoadMinShare*diskAlone {
+		t.Errorf("disk throughput fell to %.1f MB/s under network load, want at least %.0f%% of %.1f MB/s", diskMixed, 100*mixedLoadMinShare, diskAlone)
+	}
+	if netMixed < mixedLoadMinShare*netAlone {
+		t.Errorf("network throughput fell to %.2f Gbits/s under disk load, want at least %.0f%% of %.2f Gbits/s", netMixed, 100*mixedLoadMinShare, netAlone)
+	}
+}
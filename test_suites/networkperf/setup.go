@@ -36,6 +36,8 @@ var Name = "networkperf"
 
 var testFilter = flag.String("networkperf_test_filter", ".*", "regexp filter for networkperf test cases, only cases with a matching name will be run")
 
+var mixedLoad = flag.Bool("networkperf_mixed_load", false, "run TestMixedLoad, which runs disk and network load at once against a peer VM")
+
 type networkPerfTest struct {
 	name        string
 	machineType string   // Machinetype used for test
@@ -153,6 +155,8 @@ var jfServerConfig = InstanceConfig{name: "jf-server-vm", ip: "192.168.1.4"}
 var jfClientConfig = InstanceConfig{name: "jf-client-vm", ip: "192.168.1.5"}
 var tier1ServerConfig = InstanceConfig{name: "tier1-server-vm", ip: "192.168.0.6"}
 var tier1ClientConfig = InstanceConfig{name: "tier1-client-vm", ip: "192.168.0.7"}
+var mixedLoadServerConfig = InstanceConfig{name: "mixedloadserver", ip: "192.168.2.4"}
+var mixedLoadClientConfig = InstanceConfig{name: "mixedloadclient", ip: "192.168.2.5"}
 
 //go:embed startupscripts/*
 var scripts embed.FS
@@ -164,6 +168,8 @@ const (
 	linuxInstallStartupScriptURI   = "startupscripts/linux_common.sh"
 	linuxServerStartupScriptURI    = "startupscripts/linux_serverstartup.sh"
 	linuxClientStartupScriptURI    = "startupscripts/linux_clientstartup.sh"
+	linuxMixedLoadServerScriptURI  = "startupscripts/linux_mixedloadserver.sh"
+	linuxMixedLoadClientScriptURI  = "startupscripts/linux_mixedloadclient.sh"
 	windowsInstallStartupScriptURI = "startupscripts/windows_common.ps1"
 	windowsServerStartupScriptURI  = "startupscripts/windows_serverstartup.ps1"
 	windowsClientStartupScriptURI  = "startupscripts/windows_clientstartup.ps1"
@@ -259,6 +265,9 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 		if err := jfNetwork.CreateFirewallRule("jf-allow-tcp-"+tc.machineType, "tcp", []string{"5001"}, []string{"192.168.1.0/24"}); err != nil {
 			return err
 		}
+		if err := jfNetwork.CreateFirewallRule("jf-allow-icmp-"+tc.machineType, "icmp", nil, []string{"192.168.1.0/24"}); err != nil {
+			return err
+		}
 		jfNetwork.SetMTU(imagetest.JumboFramesMTU)
 
 		// Read startup scripts
@@ -379,6 +388,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 				jfClientVM.AddMetadata("iperftarget", jfServerConfig.ip)
 				jfClientVM.AddMetadata("expectedperf", defaultPerfTarget)
 				jfClientVM.AddMetadata("network-tier", net)
+				jfClientVM.AddMetadata("jumbo-peer", jfServerConfig.ip)
 
 				// Set startup scripts.
 				if utils.HasFeature(t.Image, "WINDOWS") {
@@ -401,7 +411,7 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 				serverVM.RunTests("TestGVNICExists")
 				clientVM.RunTests("TestGVNICExists|TestNetworkPerformance")
 				jfServerVM.RunTests("TestGVNICExists")
-				jfClientVM.RunTests("TestGVNICExists|TestNetworkPerformance")
+				jfClientVM.RunTests("TestGVNICExists|TestNetworkPerformance|TestJumboFrameThroughput")
 			case "TIER_1":
 				if machine.GuestCpus < 30 {
 					// Must have at least 30 vCPUs.
@@ -475,5 +485,66 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 			}
 		}
 	}
+	if *mixedLoad && !utils.HasFeature(t.Image, "WINDOWS") {
+		return mixedLoadSetup(t)
+	}
+	return nil
+}
+
+// mixedLoadSetup creates an iperf server and a client which runs
+// TestMixedLoad against it.
+func mixedLoadSetup(t *imagetest.TestWorkflow) error {
+	network, err := t.CreateNetwork("mixed-load-network", false)
+	if err != nil {
+		return err
+	}
+	subnetwork, err := network.CreateSubnetwork("mixed-load-subnetwork", "192.168.2.0/24")
+	if err != nil {
+		return err
+	}
+	if err := network.CreateFirewallRule("mixed-load-allow-tcp", "tcp", []string{"5001"}, []string{"192.168.2.0/24"}); err != nil {
+		return err
+	}
+	linuxStartup, err := scripts.ReadFile(linuxInstallStartupScriptURI)
+	if err != nil {
+		return err
+	}
+	serverScript, err := scripts.ReadFile(linuxMixedLoadServerScriptURI)
+	if err != nil {
+		return err
+	}
+	clientScript, err := scripts.ReadFile(linuxMixedLoadClientScriptURI)
+	if err != nil {
+		return err
+	}
+
+	serverVM, err := t.CreateTestVM(mixedLoadServerConfig.name)
+	if err != nil {
+		return err
+	}
+	if err := serverVM.AddCustomNetwork(network, subnetwork); err != nil {
+		return err
+	}
+	if err := serverVM.SetPrivateIP(network, mixedLoadServerConfig.ip); err != nil {
+		return err
+	}
+	serverVM.SetStartupScript(string(linuxStartup) + string(serverScript))
+	serverVM.UseGVNIC()
+	serverVM.RunTests("TestGVNICExists")
+
+	clientVM, err := t.CreateTestVM(mixedLoadClientConfig.name)
+	if err != nil {
+		return err
+	}
+	if err := clientVM.AddCustomNetwork(network, subnetwork); err != nil {
+		return err
+	}
+	if err := clientVM.SetPrivateIP(network, mixedLoadClientConfig.ip); err != nil {
+		return err
+	}
+	clientVM.AddMetadata("mixed-load-peer", mixedLoadServerConfig.ip)
+	clientVM.SetStartupScript(string(linuxStartup) + string(clientScript))
+	clientVM.UseGVNIC()
+	clientVM.RunTests("TestMixedLoad")
 	return nil
 }
@@ -0,0 +1,29 @@
+# Copyright 2024 Google LLC.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# This script installs fio, which TestMixedLoad runs alongside iperf.
+
+if [[ -f /usr/bin/apt ]]; then
+  echo "$(date +"%Y-%m-%d %T"): apt found Installing fio."
+  sudo apt install -y fio
+elif [[ -f /bin/dnf ]]; then
+  echo "$(date +"%Y-%m-%d %T"): dnf found Installing fio."
+  sudo dnf -y install fio
+elif [[ -f /bin/yum ]]; then
+  echo "$(date +"%Y-%m-%d %T"): yum found Installing fio."
+  sudo yum -y install fio
+elif [[ -f /usr/bin/zypper ]]; then
+  echo "$(date +"%Y-%m-%d %T"): zypper found Installing fio."
+  sudo zypper --non-interactive install fio
+fi
@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagevalidation
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// agentCompatJSON is the compatibility matrix of the Google guest agents.
+// Each constraint requires a component to be at a minimum version, only when
+// its optional condition holds.
+//
+//go:embed agent_compat.json
+var agentCompatJSON []byte
+
+type agentCompatMatrix struct {
+	// Components maps a component name to the packages which provide it.
+	Components  map[string][]string `json:"components"`
+	Constraints []agentConstraint   `json:"constraints"`
+}
+
+type agentConstraint struct {
+	Component  string `json:"component"`
+	MinVersion string `json:"min_version"`
+	// IfComponent and IfMinVersion restrict the constraint to when another
+	// component is installed at a minimum version, for combinations which
+	// are only broken together.
+	IfComponent  string `json:"if_component,omitempty"`
+	IfMinVersion string `json:"if_min_version,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// agentVersionRe matches the date based release of a Google agent package,
+// such as 20240213.00 in 1:20240213.00-g1 or 20240104.00@1.
+var agentVersionRe = regexp.MustCompile(`(?:^|:)(\d{8})\.(\d+)`)
+
+// compareAgentVersions returns -1, 0 or 1 as the release in a is older than,
+// the same as or newer than the release in b.
+func compareAgentVersions(a, b string) (int, error) {
+	parse := func(v string) ([2]int, error) {
+		m := agentVersionRe.FindStringSubmatch(v)
+		if m == nil {
+			return [2]int{}, fmt.Errorf("unrecognized agent version %q", v)
+		}
+		date, _ := strconv.Atoi(m[1])
+		build, _ := strconv.Atoi(m[2])
+		return [2]int{date, build}, nil
+	}
+	va, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// TestAgentVersionCompat validates that the installed Google agents satisfy
+// the compatibility matrix.
+func TestAgentVersionCompat(t *testing.T) {
+	ctx := utils.Context(t)
+	image, err := utils.GetMetadata(ctx, "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata: %v", err)
+	}
+	if strings.Contains(image, "cos") {
+		t.Skip("COS has no package manager to read agent versions from")
+	}
+	var matrix agentCompatMatrix
+	if err := json.Unmarshal(agentCompatJSON, &matrix); err != nil {
+		t.Fatalf("could not parse compatibility matrix: %v", err)
+	}
+	pkgs, err := utils.ListInstalledPackages(ctx)
+	if err != nil {
+		t.Fatalf("could not list installed packages: %v", err)
+	}
+	installed := make(map[string]string)
+	for _, pkg := range pkgs {
+		installed[pkg.Name] = pkg.Version
+	}
+	versions := make(map[string]string)
+	for component, names := range matrix.Components {
+		for _, name := range names {
+			if v, ok := installed[name]; ok {
+				versions[component] = v
+				t.Logf("%s is %s %s", component, name, v)
+				break
+			}
+		}
+	}
+
+	atLeast := func(component, min string) (bool, error) {
+		v, ok := versions[component]
+		if !ok {
+			return false, nil
+		}
+		c, err := compareAgentVersions(v, min)
+		return c >= 0, err
+	}
+	for _, c := range matrix.Constraints {
+		if c.IfComponent != "" {
+			applies, err := atLeast(c.IfComponent, c.IfMinVersion)
+			if err != nil {
+				t.Errorf("could not check %s version: %v", c.IfComponent, err)
+				continue
+			}
+			if !applies {
+				continue
+			}
+		}
+		v, ok := versions[c.Component]
+		if !ok {
+			continue
+		}
+		ok, err := atLeast(c.Component, c.MinVersion)
+		if err != nil {
+			t.Errorf("could not check %s version: %v", c.Component, err)
+			continue
+		}
+		if ok {
+			continue
+		}
+		if c.IfComponent != "" {
+			t.Errorf("%s %s is older than %s, required with %s %s or newer: %s", c.Component, v, c.MinVersion, c.IfComponent, c.IfMinVersion, c.Reason)
+		} else {
+			t.Errorf("%s %s is older than %s: %s", c.Component, v, c.MinVersion, c.Reason)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package packagevalidation
 
 import (
+	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
@@ -142,3 +143,54 @@ func testNTPWindows(t *testing.T) {
 		t.Fatalf("Time remaining is longer than the 15 minute poll interval: %f", remainingTime)
 	}
 }
+
+// rtcMode returns whether the hardware clock keeps UTC or local time, and
+// where that was read from.
+func rtcMode() (string, string, error) {
+	if utils.CheckLinuxCmdExists(timedatectlCmd) {
+		if out, err := exec.Command(timedatectlCmd, "show", "--property=LocalRTC", "--value").Output(); err == nil {
+			if strings.TrimSpace(string(out)) == "yes" {
+				return "LOCAL", "timedatectl", nil
+			}
+			return "UTC", "timedatectl", nil
+		}
+		// Older versions of timedatectl have no show command.
+		if out, err := exec.Command(timedatectlCmd, "status").Output(); err == nil {
+			for _, line := range strings.Split(string(out), "\n") {
+				if value, ok := strings.CutPrefix(strings.TrimSpace(line), "RTC in local TZ:"); ok {
+					if strings.TrimSpace(value) == "yes" {
+						return "LOCAL", "timedatectl", nil
+					}
+					return "UTC", "timedatectl", nil
+				}
+			}
+		}
+	}
+	// The third line of /etc/adjtime is the hardware clock mode, and UTC is
+	// assumed without it.
+	adjtime, err := os.ReadFile("/etc/adjtime")
+	if os.IsNotExist(err) {
+		return "UTC", "default", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	if lines := strings.Split(string(adjtime), "\n"); len(lines) > 2 && strings.TrimSpace(lines[2]) == "LOCAL" {
+		return "LOCAL", "/etc/adjtime", nil
+	}
+	return "UTC", "/etc/adjtime", nil
+}
+
+// TestHardwareClockUTC validates that the hardware clock keeps UTC, as GCE
+// provides it, so the time is consistent across reboots. See TestTimeZoneUTC
+// for the windows equivalent.
+func TestHardwareClockUTC(t *testing.T) {
+	utils.LinuxOnly(t)
+	mode, source, err := rtcMode()
+	if err != nil {
+		t.Fatalf("could not determine hardware clock mode: %v", err)
+	}
+	t.Logf("hardware clock keeps %s time, according to %s", mode, source)
+	if mode != "UTC" {
+		t.Errorf("hardware clock keeps %s time, want UTC", mode)
+	}
+}
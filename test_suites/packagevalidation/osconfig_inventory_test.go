@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagevalidation
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	osconfig "cloud.google.com/go/osconfig/apiv1"
+	"cloud.google.com/go/osconfig/apiv1/osconfigpb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// inventoryTimeout is how long the osconfig agent has to report inventory
+// after it is restarted.
+const inventoryTimeout = 10 * time.Minute
+
+// osconfigEnabled reports whether enable-osconfig is set in instance or
+// project metadata, with instance metadata taking precedence.
+func osconfigEnabled(t *testing.T) bool {
+	t.Helper()
+	ctx := utils.Context(t)
+	value, err := utils.GetMetadata(ctx, "instance", "attributes", "enable-osconfig")
+	if err != nil {
+		value, err = utils.GetMetadata(ctx, "project", "attributes", "enable-osconfig")
+	}
+	return err == nil && strings.EqualFold(value, "true")
+}
+
+// restartOSConfigAgent restarts the osconfig agent, which reports inventory
+// when it starts.
+func restartOSConfigAgent() error {
+	if utils.IsWindows() {
+		out, err := utils.RunPowershellCmd("Restart-Service google_osconfig_agent")
+		if err != nil {
+			return fmt.Errorf("%v %s", err, out.Stderr)
+		}
+		return nil
+	}
+	if out, err := exec.Command("systemctl", "restart", "google-osconfig-agent").CombinedOutput(); err != nil {
+		return fmt.Errorf("%v %s", err, out)
+	}
+	return nil
+}
+
+// reportedPackages returns the versions of the packages in inv, keyed by name
+// and normalized architecture.
+func reportedPackages(inv *osconfigpb.Inventory) map[string]string {
+	pkgs := make(map[string]string)
+	for _, item := range inv.GetItems() {
+		if item.GetType() != osconfigpb.Inventory_Item_INSTALLED_PACKAGE {
+			continue
+		}
+		sw := item.GetInstalledPackage()
+		var pkg *osconfigpb.Inventory_VersionedPackage
+		switch {
+		case sw.GetAptPackage() != nil:
+			pkg = sw.GetAptPackage()
+		case sw.GetYumPackage() != nil:
+			pkg = sw.GetYumPackage()
+		case sw.GetZypperPackage() != nil:
+			pkg = sw.GetZypperPackage()
+		case sw.GetGoogetPackage() != nil:
+			pkg = sw.GetGoogetPackage()
+		default:
+			// Updates, patches and applications are not packages of the
+			// package manager.
+			continue
+		}
+		pkgs[pkg.GetPackageName()+" "+utils.NormalizeArchitecture(pkg.GetArchitecture())] = pkg.GetVersion()
+	}
+	return pkgs
+}
+
+// freshInventory restarts the osconfig agent and returns the inventory it
+// reports afterwards.
+func freshInventory(t *testing.T) *osconfigpb.Inventory {
+	t.Helper()
+	ctx := utils.Context(t)
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	inst, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	client, err := osconfig.NewOsConfigZonalClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make osconfig client: %v", err)
+	}
+	defer client.Close()
+
+	triggered := time.Now()
+	if err := restartOSConfigAgent(); err != nil {
+		t.Fatalf("could not restart osconfig agent: %v", err)
+	}
+	name := fmt.Sprintf("projects/%s/locations/%s/instances/%s/inventory", prj, zone, inst)
+	var inv *osconfigpb.Inventory
+	for start := time.Now(); ; time.Sleep(15 * time.Second) {
+		inv, err = client.GetInventory(ctx, &osconfigpb.GetInventoryRequest{Name: name, View: osconfigpb.InventoryView_FULL})
+		if err == nil && inv.GetUpdateTime().AsTime().After(triggered) {
+			break
+		}
+		if time.Since(start) > inventoryTimeout {
+			t.Fatalf("no inventory reported since the agent was restarted within %v, last error: %v", inventoryTimeout, err)
+		}
+	}
+	t.Logf("inventory reported at %s with %d items", inv.GetUpdateTime().AsTime(), len(inv.GetItems()))
+	return inv
+}
+
+// TestOSConfigInventory validates that the inventory reported by the osconfig
+// agent matches the packages and OS installed on the guest.
+func TestOSConfigInventory(t *testing.T) {
+	ctx := utils.Context(t)
+	if !osconfigEnabled(t) {
+		t.Skip("osconfig is not enabled")
+	}
+	inv := freshInventory(t)
+
+	actualOS, err := utils.GetOSInfo(ctx)
+	if err != nil {
+		t.Fatalf("could not get OS info: %v", err)
+	}
+	reportedOS := inv.GetOsInfo()
+	osChecks := []struct {
+		field, reported, actual string
+	}{
+		{"hostname", strings.ToLower(reportedOS.GetHostname()), strings.ToLower(actualOS.Hostname)},
+		{"short name", reportedOS.GetShortName(), actualOS.ShortName},
+		{"version", reportedOS.GetVersion(), actualOS.Version},
+		{"kernel release", reportedOS.GetKernelRelease(), actualOS.KernelRelease},
+		{"architecture", utils.NormalizeArchitecture(reportedOS.GetArchitecture()), actualOS.Architecture},
+	}
+	for _, c := range osChecks {
+		t.Logf("OS %s: reported %q, actual %q", c.field, c.reported, c.actual)
+		if c.reported != c.actual {
+			t.Errorf("inventory reports OS %s %q, guest has %q", c.field, c.reported, c.actual)
+		}
+	}
+
+	installed, err := utils.ListInstalledPackages(ctx)
+	if err != nil {
+		t.Fatalf("could not list installed packages: %v", err)
+	}
+	reported := reportedPackages(inv)
+	actual := make(map[string]string)
+	for _, pkg := range installed {
+		actual[pkg.Name+" "+utils.NormalizeArchitecture(pkg.Architecture)] = pkg.Version
+	}
+	t.Logf("inventory reports %d packages, guest has %d", len(reported), len(actual))
+	var discrepancies []string
+	for pkg, version := range actual {
+		switch reportedVersion, ok := reported[pkg]; {
+		case !ok:
+			discrepancies = append(discrepancies, fmt.Sprintf("%s %s is installed but not reported", pkg, version))
+		case reportedVersion != version:
+			discrepancies = append(discrepancies, fmt.Sprintf("%s is reported at version %s, installed version is %s", pkg, reportedVersion, version))
+		}
+	}
+	for pkg, version := range reported {
+		if _, ok := actual[pkg]; !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s %s is reported but not installed", pkg, version))
+		}
+	}
+	sort.Strings(discrepancies)
+	for _, d := range discrepancies {
+		t.Error(d)
+	}
+}
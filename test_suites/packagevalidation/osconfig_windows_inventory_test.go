@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagevalidation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/osconfig/apiv1/osconfigpb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// availableWindowsUpdatesCmd lists the update ids of the updates Windows
+// Update offers the instance, as the osconfig agent searches for them.
+const availableWindowsUpdatesCmd = `$searcher = (New-Object -ComObject Microsoft.Update.Session).CreateUpdateSearcher()
+$searcher.Search("IsInstalled=0").Updates | ForEach-Object { $_.Identity.UpdateID }`
+
+// installedWindowsUpdatesCmd lists the update ids of the updates Windows
+// Update has installed.
+const installedWindowsUpdatesCmd = `$searcher = (New-Object -ComObject Microsoft.Update.Session).CreateUpdateSearcher()
+$searcher.Search("IsInstalled=1").Updates | ForEach-Object { $_.Identity.UpdateID }`
+
+// powershellLines runs a PowerShell command and returns the non-empty lines
+// it prints.
+func powershellLines(cmd string) (map[string]bool, error) {
+	out, err := utils.RunPowershellCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%v %s", err, out.Stderr)
+	}
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines[line] = true
+		}
+	}
+	return lines, nil
+}
+
+// TestOSConfigWindowsInventory validates that the installed hotfixes and
+// available Windows updates reported by the osconfig agent match what the
+// guest reports through Get-HotFix and the Windows Update API.
+func TestOSConfigWindowsInventory(t *testing.T) {
+	utils.WindowsOnly(t)
+	if !osconfigEnabled(t) {
+		t.Skip("osconfig is not enabled")
+	}
+	inv := freshInventory(t)
+
+	reportedHotfixes := make(map[string]bool)
+	reportedInstalled := make(map[string]string)
+	reportedAvailable := make(map[string]string)
+	for _, item := range inv.GetItems() {
+		switch item.GetType() {
+		case osconfigpb.Inventory_Item_INSTALLED_PACKAGE:
+			sw := item.GetInstalledPackage()
+			if qfe := sw.GetQfePackage(); qfe != nil {
+				reportedHotfixes[qfe.GetHotFixId()] = true
+			}
+			if wua := sw.GetWuaPackage(); wua != nil {
+				reportedInstalled[wua.GetUpdateId()] = wua.GetTitle()
+			}
+		case osconfigpb.Inventory_Item_AVAILABLE_PACKAGE:
+			if wua := item.GetAvailablePackage().GetWuaPackage(); wua != nil {
+				reportedAvailable[wua.GetUpdateId()] = wua.GetTitle()
+			}
+		}
+	}
+
+	hotfixes, err := powershellLines("Get-HotFix | ForEach-Object { $_.HotFixID }")
+	if err != nil {
+		t.Fatalf("could not list hotfixes: %v", err)
+	}
+	installed, err := powershellLines(installedWindowsUpdatesCmd)
+	if err != nil {
+		t.Fatalf("could not list installed Windows updates: %v", err)
+	}
+	available, err := powershellLines(availableWindowsUpdatesCmd)
+	if err != nil {
+		t.Fatalf("could not list available Windows updates: %v", err)
+	}
+	t.Logf("hotfixes: %d reported, %d installed", len(reportedHotfixes), len(hotfixes))
+	t.Logf("installed Windows updates: %d reported, %d installed", len(reportedInstalled), len(installed))
+	t.Logf("available Windows updates: %d reported, %d available", len(reportedAvailable), len(available))
+
+	var discrepancies []string
+	for id := range hotfixes {
+		if !reportedHotfixes[id] {
+			discrepancies = append(discrepancies, fmt.Sprintf("hotfix %s is installed but not reported", id))
+		}
+	}
+	for id := range reportedHotfixes {
+		if !hotfixes[id] {
+			discrepancies = append(discrepancies, fmt.Sprintf("hotfix %s is reported but not installed", id))
+		}
+	}
+	for id, title := range reportedInstalled {
+		if !installed[id] {
+			discrepancies = append(discrepancies, fmt.Sprintf("update %s (%s) is reported installed but Windows Update does not list it as installed", id, title))
+		}
+	}
+	for id, title := range reportedAvailable {
+		switch {
+		case installed[id]:
+			discrepancies = append(discrepancies, fmt.Sprintf("update %s (%s) is reported available but is installed", id, title))
+		case !available[id]:
+			// Windows Update may have superseded it since the report.
+			t.Logf("update %s (%s) is reported available but is no longer offered", id, title)
+		}
+	}
+	for id := range available {
+		if _, ok := reportedAvailable[id]; !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("update %s is available but not reported", id))
+		}
+	}
+	sort.Strings(discrepancies)
+	for _, d := range discrepancies {
+		t.Error(d)
+	}
+}
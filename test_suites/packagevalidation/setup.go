@@ -17,6 +17,8 @@
 package packagevalidation
 
 import (
+	"strings"
+
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
 )
@@ -30,7 +32,18 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	if err != nil {
 		return err
 	}
-	vm1.RunTests("TestStandardPrograms|TestGuestPackages|TestNTP")
+	vm1.RunTests("TestStandardPrograms|TestGuestPackages|TestNTP|TestAgentVersionCompat|TestHardwareClockUTC")
+
+	// COS has no package manager to compare the inventory against.
+	if !strings.Contains(t.Image.Name, "cos") {
+		inventoryvm, err := t.CreateTestVM("osconfiginventory")
+		if err != nil {
+			return err
+		}
+		inventoryvm.AddMetadata("enable-osconfig", "TRUE")
+		inventoryvm.AddScope("https://www.googleapis.com/auth/cloud-platform")
+		inventoryvm.RunTests("TestOSConfigInventory|TestOSConfigWindowsInventory")
+	}
 
 	// as part of the migration of the windows test suite, these vms
 	// are only used to run windows tests. The tests themselves
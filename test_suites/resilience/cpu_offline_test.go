@@ -0,0 +1,156 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const cpuSysfs = "/sys/devices/system/cpu"
+
+// hotpluggableCPUs returns the CPUs which can be taken offline, which
+// excludes CPUs without an online control such as CPU 0 on most kernels.
+func hotpluggableCPUs() ([]string, error) {
+	controls, err := filepath.Glob(filepath.Join(cpuSysfs, "cpu[0-9]*", "online"))
+	if err != nil {
+		return nil, err
+	}
+	var cpus []string
+	for _, control := range controls {
+		cpus = append(cpus, filepath.Base(filepath.Dir(control)))
+	}
+	return cpus, nil
+}
+
+func cpuOnline(cpu string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(cpuSysfs, cpu, "online"))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(data)) == "1", nil
+}
+
+func setCPUOnline(cpu string, online bool) error {
+	value := "0"
+	if online {
+		value = "1"
+	}
+	return os.WriteFile(filepath.Join(cpuSysfs, cpu, "online"), []byte(value), 0644)
+}
+
+// onlineCPUs returns the kernel's list of online CPUs, such as "0-3".
+func onlineCPUs() string {
+	data, _ := os.ReadFile(filepath.Join(cpuSysfs, "online"))
+	return strings.TrimSpace(string(data))
+}
+
+// runOnCPUs checks that work can still be scheduled, optionally pinned to cpu.
+func runOnCPUs(cpu string) error {
+	deadline := time.Now().Add(5 * time.Second)
+	done := make(chan struct{})
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go func() {
+			for time.Now().Before(deadline) {
+			}
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Minute):
+			return fmt.Errorf("busy loops did not finish within a minute")
+		}
+	}
+	if cpu == "" || !utils.CheckLinuxCmdExists("taskset") {
+		return nil
+	}
+	if out, err := exec.Command("taskset", "-c", strings.TrimPrefix(cpu, "cpu"), "true").CombinedOutput(); err != nil {
+		return fmt.Errorf("could not run a process on %s: %v %s", cpu, err, out)
+	}
+	return nil
+}
+
+// TestCPUOffline validates that the system stays stable while a vCPU is
+// offline, and that the vCPU rejoins when brought back online.
+func TestCPUOffline(t *testing.T) {
+	utils.LinuxOnly(t)
+	cpus, err := hotpluggableCPUs()
+	if err != nil {
+		t.Fatalf("could not list CPUs: %v", err)
+	}
+	if len(cpus) == 0 {
+		t.Skip("no CPU can be taken offline")
+	}
+	t.Cleanup(func() {
+		for _, cpu := range cpus {
+			if online, err := cpuOnline(cpu); err == nil && !online {
+				if err := setCPUOnline(cpu, true); err != nil {
+					t.Errorf("could not bring %s back online: %v", cpu, err)
+				}
+			}
+		}
+	})
+	// Take the highest numbered CPU offline, it is the least likely to be
+	// special.
+	cpu := cpus[len(cpus)-1]
+	if online, err := cpuOnline(cpu); err != nil || !online {
+		t.Fatalf("%s is not online before the test: %v", cpu, err)
+	}
+	pids := make(map[string]string)
+	for _, service := range criticalServices {
+		if pid := serviceMainPID(service); pid != "" {
+			pids[service] = pid
+		}
+	}
+	t.Logf("online CPUs: %s", onlineCPUs())
+
+	if err := setCPUOnline(cpu, false); err != nil {
+		t.Fatalf("could not take %s offline: %v", cpu, err)
+	}
+	if online, err := cpuOnline(cpu); err != nil || online {
+		t.Fatalf("%s did not go offline: %v", cpu, err)
+	}
+	t.Logf("took %s offline, online CPUs: %s", cpu, onlineCPUs())
+	if err := runOnCPUs(""); err != nil {
+		t.Errorf("scheduling stalled with %s offline: %v", cpu, err)
+	}
+
+	if err := setCPUOnline(cpu, true); err != nil {
+		t.Fatalf("could not bring %s back online: %v", cpu, err)
+	}
+	if online, err := cpuOnline(cpu); err != nil || !online {
+		t.Fatalf("%s did not come back online: %v", cpu, err)
+	}
+	t.Logf("brought %s back online, online CPUs: %s", cpu, onlineCPUs())
+	if err := runOnCPUs(cpu); err != nil {
+		t.Errorf("%s did not rejoin scheduling: %v", cpu, err)
+	}
+
+	for service, pid := range pids {
+		if after := serviceMainPID(service); after != pid {
+			t.Errorf("%s main PID changed from %s to %q while %s was offline", service, pid, after, cpu)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	hostErrorMarker = "/var/cit-host-error"
+	// hostErrorTimeout is how long each side waits for the other.
+	hostErrorTimeout = 15 * time.Minute
+)
+
+// hostErrorState is what the guest records before the host error.
+type hostErrorState struct {
+	BootID    string    `json:"boot_id"`
+	ReadyTime time.Time `json:"ready_time"`
+}
+
+// TestHostErrorRestart validates that the instance is restarted automatically
+// after it is terminated by a host event, and comes back healthy.
+// TestHostErrorTrigger terminates it from another instance.
+func TestHostErrorRestart(t *testing.T) {
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "host-error-restart"); err != nil || enabled != "true" {
+		t.Skip("host error restart is not enabled")
+	}
+	id, err := bootID()
+	if err != nil {
+		t.Fatalf("could not read boot id: %v", err)
+	}
+
+	data, err := os.ReadFile(hostErrorMarker)
+	if os.IsNotExist(err) {
+		// first boot
+		state := hostErrorState{BootID: id, ReadyTime: time.Now()}
+		if data, err = json.Marshal(state); err != nil {
+			t.Fatalf("before host error: could not marshal state: %v", err)
+		}
+		if err := os.WriteFile(hostErrorMarker, data, 0644); err != nil {
+			t.Fatalf("before host error: could not write state: %v", err)
+		}
+		if err := utils.PutMetadata(ctx, path.Join("instance", "guest-attributes", hostErrorNamespace, "ready"), "ready"); err != nil {
+			t.Fatalf("before host error: could not signal readiness: %v", err)
+		}
+		// The trigger terminates the instance, so this only returns if it
+		// never did.
+		time.Sleep(hostErrorTimeout)
+		t.Fatalf("before host error: instance was not terminated within %v", hostErrorTimeout)
+	}
+	if err != nil {
+		t.Fatalf("after host error: could not read state: %v", err)
+	}
+
+	// after the restart
+	var before hostErrorState
+	if err := json.Unmarshal(data, &before); err != nil {
+		t.Fatalf("after host error: could not parse state: %v", err)
+	}
+	if before.BootID == id {
+		t.Fatalf("after host error: still in boot %s, the instance was not restarted", id)
+	}
+	booted, err := bootTime()
+	if err != nil {
+		t.Fatalf("after host error: could not get boot time: %v", err)
+	}
+	t.Logf("ready for host error at %s, booted again at %s, %v later", before.ReadyTime.Format(time.RFC3339), booted.Format(time.RFC3339), booted.Sub(before.ReadyTime).Round(time.Second))
+	for _, problem := range bootHealth(ctx) {
+		t.Errorf("after host error: %s", problem)
+	}
+	if err := utils.PutMetadata(ctx, path.Join("instance", "guest-attributes", hostErrorNamespace, "recovered"), id); err != nil {
+		t.Errorf("after host error: could not signal recovery: %v", err)
+	}
+}
+
+// TestHostErrorTrigger terminates the TestHostErrorRestart instance with a
+// simulated host maintenance event once it is ready, and reports how long it
+// took to come back.
+func TestHostErrorTrigger(t *testing.T) {
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "host-error-restart"); err != nil || enabled != "true" {
+		t.Skip("host error restart is not enabled")
+	}
+	instance, err := utils.GetRealVMName(hostErrorVM)
+	if err != nil {
+		t.Fatalf("could not get name of instance to terminate: %v", err)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	waitFor := func(key string) error {
+		for start := time.Now(); time.Since(start) < hostErrorTimeout; time.Sleep(10 * time.Second) {
+			_, err := client.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+				Project:     prj,
+				Zone:        zone,
+				Instance:    instance,
+				VariableKey: proto.String(path.Join(hostErrorNamespace, key)),
+			})
+			if err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s did not signal %s within %v", instance, key, hostErrorTimeout)
+	}
+
+	if err := waitFor("ready"); err != nil {
+		t.Fatal(err)
+	}
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err != nil {
+		t.Fatalf("could not get %s: %v", instance, err)
+	}
+	scheduling := inst.GetScheduling()
+	t.Logf("%s has onHostMaintenance %s, automaticRestart %t", instance, scheduling.GetOnHostMaintenance(), scheduling.GetAutomaticRestart())
+	if scheduling.GetOnHostMaintenance() != "TERMINATE" || !scheduling.GetAutomaticRestart() {
+		t.Fatalf("%s must terminate on host maintenance and restart automatically", instance)
+	}
+
+	triggered := time.Now()
+	op, err := client.SimulateMaintenanceEvent(ctx, &computepb.SimulateMaintenanceEventInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
+	if err != nil {
+		t.Fatalf("could not simulate host maintenance on %s: %v", instance, err)
+	}
+	if err := waitFor("recovered"); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s recovered %v after the simulated host event", instance, time.Since(triggered).Round(time.Second))
+}
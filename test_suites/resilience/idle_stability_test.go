@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// idleCrashRe matches journal messages reporting a crash, OOM kill or
+// unexpected reboot.
+var idleCrashRe = regexp.MustCompile(`(?i)(out of memory|oom-kill|invoked oom-killer|segfault at|code=dumped|core dumped|kernel bug|call trace:|watchdog: bug|hung_task|reboot: restarting system|failed with result)`)
+
+// bootID returns the id of the current boot.
+func bootID() (string, error) {
+	id, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	return strings.TrimSpace(string(id)), err
+}
+
+// failedUnits returns the units systemd considers failed.
+func failedUnits() map[string]bool {
+	units := make(map[string]bool)
+	out, err := exec.Command("systemctl", "list-units", "--failed", "--plain", "--no-legend", "--no-pager").Output()
+	if err != nil {
+		return units
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			units[fields[0]] = true
+		}
+	}
+	return units
+}
+
+// TestIdleStability validates that the instance sits idle for a while without
+// rebooting or having services crash.
+func TestIdleStability(t *testing.T) {
+	ctx := utils.Context(t)
+	value, err := utils.GetMetadata(ctx, "instance", "attributes", "idle-duration")
+	if err != nil {
+		t.Skip("idle stability is not enabled")
+	}
+	idle, err := time.ParseDuration(value)
+	if err != nil || idle <= 0 {
+		t.Fatalf("idle-duration %q is not a positive duration", value)
+	}
+
+	startID, err := bootID()
+	if err != nil {
+		t.Fatalf("could not read boot id: %v", err)
+	}
+	startBoot, err := bootTime()
+	if err != nil {
+		t.Fatalf("could not get boot time: %v", err)
+	}
+	failedBefore := failedUnits()
+	start := time.Now()
+	t.Logf("idling for %v from %s in boot %s", idle, start.Format(time.RFC3339), startID)
+
+	select {
+	case <-time.After(idle):
+	case <-ctx.Done():
+		t.Fatalf("test context ended after %v of %v idle: %v", time.Since(start).Round(time.Second), idle, ctx.Err())
+	}
+
+	endID, err := bootID()
+	if err != nil {
+		t.Fatalf("could not read boot id after idle: %v", err)
+	}
+	if endID != startID {
+		t.Errorf("boot id changed from %s to %s while idle", startID, endID)
+	}
+	// Boot time is derived from uptime, so allow for rounding.
+	if endBoot, err := bootTime(); err != nil {
+		t.Errorf("could not get boot time after idle: %v", err)
+	} else if endBoot.Sub(startBoot).Abs() > 5*time.Second {
+		t.Errorf("instance rebooted while idle: boot time moved from %s to %s", startBoot.Format(time.RFC3339), endBoot.Format(time.RFC3339))
+	}
+
+	for unit := range failedUnits() {
+		if !failedBefore[unit] {
+			t.Errorf("%s failed while idle", unit)
+		}
+	}
+
+	if !utils.CheckLinuxCmdExists("journalctl") {
+		t.Logf("journalctl is not installed, not scanning the journal for crashes")
+		return
+	}
+	out, err := exec.Command("journalctl", "--no-pager", "-o", "short-iso", "--since", "@"+strconv.FormatInt(start.Unix(), 10)).Output()
+	if err != nil {
+		t.Fatalf("could not read journal: %v", err)
+	}
+	var crashes []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if idleCrashRe.MatchString(line) {
+			crashes = append(crashes, line)
+		}
+	}
+	for _, crash := range crashes {
+		t.Errorf("crash while idle: %s", crash)
+	}
+	t.Logf("found %d crash messages in the journal while idle", len(crashes))
+}
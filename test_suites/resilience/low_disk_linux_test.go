@@ -0,0 +1,125 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// lowDiskFill is the file which fills the root filesystem. It is the
+	// only thing the test writes, so removing it undoes the test.
+	lowDiskFill = "/var/cit-low-disk-fill"
+	// lowDiskHeadroom is the space left available on the root filesystem,
+	// enough for boot to write logs and state and for the test to run again.
+	lowDiskHeadroom = 256 << 20
+)
+
+// lowDiskServices are checked after booting with little free space.
+var lowDiskServices = []string{"systemd-journald", "sshd", "ssh", "google-guest-agent"}
+
+// rootAvailable returns the bytes available to unprivileged users and the
+// total size of the root filesystem.
+func rootAvailable() (available, size uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs("/", &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
+
+// TestLowDiskBoot validates that the instance boots and starts its services
+// when the root filesystem is nearly full.
+func TestLowDiskBoot(t *testing.T) {
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "low-disk-boot"); err != nil || enabled != "true" {
+		t.Skip("low disk boot is not enabled")
+	}
+	guard := utils.DefaultRebootGuard()
+	state, err := guard.State(t.Name())
+	if err != nil {
+		t.Fatalf("could not get reboot state: %v", err)
+	}
+
+	switch state {
+	case utils.RebootNone:
+		// first boot
+		available, _, err := rootAvailable()
+		if err != nil {
+			t.Fatalf("before reboot: could not stat root filesystem: %v", err)
+		}
+		if available <= lowDiskHeadroom {
+			t.Fatalf("before reboot: root filesystem only has %d MiB available", available>>20)
+		}
+		f, err := os.OpenFile(lowDiskFill, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			t.Fatalf("before reboot: could not create %s: %v", lowDiskFill, err)
+		}
+		err = syscall.Fallocate(int(f.Fd()), 0, 0, int64(available-lowDiskHeadroom))
+		f.Close()
+		if err != nil {
+			os.Remove(lowDiskFill)
+			t.Fatalf("before reboot: could not fill root filesystem: %v", err)
+		}
+		if err := guard.Begin(t.Name()); err != nil {
+			os.Remove(lowDiskFill)
+			t.Fatalf("before reboot: %v", err)
+		}
+		exec.Command("sync").Run()
+		return
+	case utils.RebootPending:
+		os.Remove(lowDiskFill)
+		t.Fatal("instance did not reboot")
+	}
+
+	// second boot
+	t.Cleanup(func() {
+		if err := os.Remove(lowDiskFill); err != nil {
+			t.Errorf("could not remove %s: %v", lowDiskFill, err)
+		}
+		guard.Release(t.Name())
+	})
+	if _, err := os.Stat(lowDiskFill); err != nil {
+		t.Fatalf("after reboot: %s is missing, the filesystem was not full during boot: %v", lowDiskFill, err)
+	}
+	if available, size, err := rootAvailable(); err == nil {
+		t.Logf("after reboot: %d MiB of %d MiB available on the root filesystem", available>>20, size>>20)
+	}
+
+	for _, service := range lowDiskServices {
+		out, _ := exec.CommandContext(ctx, "systemctl", "is-active", service).Output()
+		status := strings.TrimSpace(string(out))
+		if status == "inactive" || status == "unknown" {
+			// Not every image has every service, and sshd and ssh are the
+			// same service on different distributions.
+			if exec.CommandContext(ctx, "systemctl", "cat", service).Run() != nil {
+				continue
+			}
+		}
+		t.Logf("after reboot: %s is %s", service, status)
+		if status != "active" {
+			t.Errorf("after reboot: %s is %s, want active", service, status)
+		}
+	}
+	for _, problem := range bootHealth(ctx) {
+		t.Errorf("after reboot: %s", problem)
+	}
+}
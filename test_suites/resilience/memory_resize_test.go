@@ -0,0 +1,244 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	memoryResizeMarker = "/var/cit-memory-resize"
+	// memoryResizeTimeout is how long each side waits for the other during
+	// the resize.
+	memoryResizeTimeout = 10 * time.Minute
+)
+
+// memoryResizeState is what the guest records before the resize to compare
+// against afterwards.
+type memoryResizeState struct {
+	MachineType string `json:"machineType"`
+	MemTotalKB  int64  `json:"memTotalKB"`
+}
+
+// memTotalKB returns the memory visible to the kernel.
+func memTotalKB() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no MemTotal in /proc/meminfo")
+}
+
+func currentMachineType(ctx context.Context) (string, error) {
+	machineType, err := utils.GetMetadata(ctx, "instance", "machine-type")
+	if err != nil {
+		return "", fmt.Errorf("could not get machine type from metadata: %v", err)
+	}
+	return path.Base(machineType), nil
+}
+
+// machineTypeMemoryKB returns the memory of a machine type from the compute API.
+func machineTypeMemoryKB(ctx context.Context, machineType string) (int64, error) {
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not find project and zone: %v", err)
+	}
+	client, err := compute.NewMachineTypesRESTClient(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+	mt, err := client.Get(ctx, &computepb.GetMachineTypeRequest{Project: prj, Zone: zone, MachineType: machineType})
+	if err != nil {
+		return 0, fmt.Errorf("could not get machine type %s: %v", machineType, err)
+	}
+	return int64(mt.GetMemoryMb()) * 1024, nil
+}
+
+// TestMemoryHotAdd validates that the guest sees the new memory after the
+// instance is resized to a machine type with more memory. TestMemoryResizer
+// performs the resize from another instance.
+func TestMemoryHotAdd(t *testing.T) {
+	ctx := utils.Context(t)
+	target, err := utils.GetMetadata(ctx, "instance", "attributes", "memory-resize-machine-type")
+	if err != nil {
+		t.Skip("instance is not set up to have its memory resized")
+	}
+	guard := utils.DefaultRebootGuard()
+	state, err := guard.State(t.Name())
+	if err != nil {
+		t.Fatalf("could not get reboot state: %v", err)
+	}
+
+	switch state {
+	case utils.RebootNone:
+		// first boot
+		var before memoryResizeState
+		if before.MachineType, err = currentMachineType(ctx); err != nil {
+			t.Fatalf("before resize: %v", err)
+		}
+		if before.MemTotalKB, err = memTotalKB(); err != nil {
+			t.Fatalf("before resize: %v", err)
+		}
+		data, err := json.Marshal(before)
+		if err != nil {
+			t.Fatalf("before resize: could not marshal memory state: %v", err)
+		}
+		if err := os.WriteFile(memoryResizeMarker, data, 0644); err != nil {
+			t.Fatalf("before resize: could not write memory state: %v", err)
+		}
+		if err := guard.Begin(t.Name()); err != nil {
+			t.Fatalf("before resize: %v", err)
+		}
+		if err := utils.PutMetadata(ctx, path.Join("instance", "guest-attributes", memoryResizeNamespace, "ready"), string(data)); err != nil {
+			t.Fatalf("before resize: could not signal readiness for resize: %v", err)
+		}
+		t.Logf("%s has %d KB of memory, waiting to be resized to %s", before.MachineType, before.MemTotalKB, target)
+		// The resizer stops the instance, so this only returns if the
+		// resize never happened.
+		time.Sleep(memoryResizeTimeout)
+		t.Fatalf("instance was not stopped for resize within %v", memoryResizeTimeout)
+	case utils.RebootPending:
+		t.Fatal("during resize: instance was not restarted")
+	}
+
+	// second boot
+	t.Cleanup(func() { guard.Release(t.Name()) })
+	data, err := os.ReadFile(memoryResizeMarker)
+	if err != nil {
+		t.Fatalf("after resize: could not read memory state from before resize: %v", err)
+	}
+	var before memoryResizeState
+	if err := json.Unmarshal(data, &before); err != nil {
+		t.Fatalf("after resize: could not parse memory state from before resize: %v", err)
+	}
+	machineType, err := currentMachineType(ctx)
+	if err != nil {
+		t.Fatalf("after resize: %v", err)
+	}
+	if machineType != target {
+		t.Fatalf("after resize: machine type is %s, want %s", machineType, target)
+	}
+	after, err := memTotalKB()
+	if err != nil {
+		t.Fatalf("after resize: %v", err)
+	}
+	t.Logf("memory went from %d KB on %s to %d KB on %s", before.MemTotalKB, before.MachineType, after, machineType)
+	fromKB, err := machineTypeMemoryKB(ctx, before.MachineType)
+	if err != nil {
+		t.Fatalf("after resize: %v", err)
+	}
+	toKB, err := machineTypeMemoryKB(ctx, machineType)
+	if err != nil {
+		t.Fatalf("after resize: %v", err)
+	}
+	// The kernel reserves some memory, so allow the guest to see a little
+	// less than the full increase.
+	if want := (toKB - fromKB) * 9 / 10; after-before.MemTotalKB < want {
+		t.Errorf("after resize: memory grew by %d KB, want at least %d KB", after-before.MemTotalKB, want)
+	}
+}
+
+// TestMemoryResizer stops the TestMemoryHotAdd instance once it is ready,
+// resizes it, and starts it again.
+func TestMemoryResizer(t *testing.T) {
+	ctx := utils.Context(t)
+	machineType, err := utils.GetMetadata(ctx, "instance", "attributes", "memory-resize-machine-type")
+	if err != nil {
+		t.Skip("no memory resize is set up")
+	}
+	instance, err := utils.GetRealVMName(memoryResizeVM)
+	if err != nil {
+		t.Fatalf("could not get name of instance to resize: %v", err)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	ready := false
+	for start := time.Now(); time.Since(start) < memoryResizeTimeout; time.Sleep(10 * time.Second) {
+		_, err := client.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+			Project:   prj,
+			Zone:      zone,
+			Instance:  instance,
+			QueryPath: proto.String(memoryResizeNamespace + "/"),
+		})
+		if err == nil {
+			ready = true
+			break
+		}
+	}
+	if !ready {
+		t.Fatalf("%s did not signal it was ready to be resized within %v", instance, memoryResizeTimeout)
+	}
+
+	stop, err := client.Stop(ctx, &computepb.StopInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err != nil {
+		t.Fatalf("could not stop %s: %v", instance, err)
+	}
+	if err := stop.Wait(ctx); err != nil {
+		t.Fatalf("could not stop %s: %v", instance, err)
+	}
+	resize, err := client.SetMachineType(ctx, &computepb.SetMachineTypeInstanceRequest{
+		Project:  prj,
+		Zone:     zone,
+		Instance: instance,
+		InstancesSetMachineTypeRequestResource: &computepb.InstancesSetMachineTypeRequest{
+			MachineType: proto.String(fmt.Sprintf("zones/%s/machineTypes/%s", zone, machineType)),
+		},
+	})
+	if err != nil {
+		t.Errorf("could not resize %s to %s: %v", instance, machineType, err)
+	} else if err := resize.Wait(ctx); err != nil {
+		t.Errorf("could not resize %s to %s: %v", instance, machineType, err)
+	}
+	// Start the instance even if the resize failed so it can report results.
+	start, err := client.Start(ctx, &computepb.StartInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+	if err != nil {
+		t.Fatalf("could not start %s: %v", instance, err)
+	}
+	if err := start.Wait(ctx); err != nil {
+		t.Fatalf("could not start %s: %v", instance, err)
+	}
+	t.Logf("resized %s to %s", instance, machineType)
+}
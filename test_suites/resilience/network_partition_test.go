@@ -0,0 +1,177 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// networkPartitionOutage is how long the primary interface is down.
+	networkPartitionOutage = time.Minute
+	// networkPartitionRecoveryTimeout is how long services have to recover
+	// after the interface comes back up.
+	networkPartitionRecoveryTimeout = 3 * time.Minute
+)
+
+// recoveryCheck is a service which must work again after the partition.
+type recoveryCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// partitionRecoveryChecks returns the services checked after the partition
+// of the interface with the given metadata ip.
+func partitionRecoveryChecks(iface, ip string) []recoveryCheck {
+	return []recoveryCheck{
+		{"address", func(ctx context.Context) error {
+			out, err := exec.CommandContext(ctx, "ip", "-4", "-o", "addr", "show", "dev", iface).Output()
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(out), " "+ip+"/") {
+				return fmt.Errorf("%s does not have address %s", iface, ip)
+			}
+			return nil
+		}},
+		{"default route", func(ctx context.Context) error {
+			out, err := exec.CommandContext(ctx, "ip", "route", "show", "default").Output()
+			if err != nil {
+				return err
+			}
+			if len(strings.TrimSpace(string(out))) == 0 {
+				return fmt.Errorf("no default route")
+			}
+			return nil
+		}},
+		{"metadata server", func(ctx context.Context) error {
+			_, err := utils.GetMetadata(ctx, "instance", "id")
+			return err
+		}},
+		{"service account token", func(ctx context.Context) error {
+			_, err := utils.GetMetadata(ctx, "instance", "service-accounts", "default", "token")
+			return err
+		}},
+		{"dns", func(ctx context.Context) error {
+			_, err := net.DefaultResolver.LookupHost(ctx, "www.googleapis.com")
+			return err
+		}},
+		{"external connection", func(ctx context.Context) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", "www.googleapis.com:443")
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}},
+		{"guest agent", func(ctx context.Context) error {
+			return exec.CommandContext(ctx, "systemctl", "is-active", "--quiet", "google-guest-agent").Run()
+		}},
+	}
+}
+
+// TestNetworkPartitionRecovery validates that networking and the services
+// which depend on it recover after the primary interface is down for a while.
+func TestNetworkPartitionRecovery(t *testing.T) {
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "network-partition"); err != nil || enabled != "true" {
+		t.Skip("network partition is not enabled")
+	}
+	iface, err := utils.GetInterface(ctx, 0)
+	if err != nil {
+		t.Fatalf("could not find primary interface: %v", err)
+	}
+	ip, err := utils.GetMetadata(ctx, "instance", "network-interfaces", "0", "ip")
+	if err != nil {
+		t.Fatalf("could not get primary interface ip from metadata: %v", err)
+	}
+	checks := partitionRecoveryChecks(iface.Name, ip)
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			t.Fatalf("%s does not work before the partition: %v", c.name, err)
+		}
+	}
+
+	// Schedule the interface to come back up independently of this process,
+	// so the instance isn't stranded if the test binary is killed mid
+	// partition.
+	backstop := fmt.Sprintf("--on-active=%d", int((networkPartitionOutage + time.Minute).Seconds()))
+	if out, err := exec.Command("systemd-run", "--unit=cit-partition-restore", backstop, "ip", "link", "set", "dev", iface.Name, "up").CombinedOutput(); err != nil {
+		t.Fatalf("could not schedule interface restore: %v %s", err, out)
+	}
+	t.Cleanup(func() {
+		exec.Command("systemctl", "stop", "cit-partition-restore.timer").Run()
+	})
+	if out, err := exec.Command("ip", "link", "set", "dev", iface.Name, "down").CombinedOutput(); err != nil {
+		t.Fatalf("could not bring %s down: %v %s", iface.Name, err, out)
+	}
+	restored := false
+	t.Cleanup(func() {
+		if !restored {
+			if out, err := exec.Command("ip", "link", "set", "dev", iface.Name, "up").CombinedOutput(); err != nil {
+				t.Errorf("could not bring %s back up: %v %s", iface.Name, err, out)
+			}
+		}
+	})
+	partitioned := time.Now()
+	t.Logf("brought %s down at %s", iface.Name, partitioned.Format(time.RFC3339))
+
+	shortCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	_, err = utils.GetMetadata(shortCtx, "instance", "id")
+	cancel()
+	if err == nil {
+		t.Error("metadata server is still reachable with the primary interface down")
+	}
+	time.Sleep(networkPartitionOutage - time.Since(partitioned))
+
+	if out, err := exec.Command("ip", "link", "set", "dev", iface.Name, "up").CombinedOutput(); err != nil {
+		t.Fatalf("could not bring %s back up: %v %s", iface.Name, err, out)
+	}
+	restored = true
+	up := time.Now()
+	t.Logf("brought %s back up after %v", iface.Name, up.Sub(partitioned).Round(time.Second))
+
+	recovered := make(map[string]time.Duration)
+	lastErr := make(map[string]error)
+	for time.Since(up) < networkPartitionRecoveryTimeout && len(recovered) < len(checks) {
+		for _, c := range checks {
+			if _, ok := recovered[c.name]; ok {
+				continue
+			}
+			shortCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			lastErr[c.name] = c.check(shortCtx)
+			cancel()
+			if lastErr[c.name] == nil {
+				recovered[c.name] = time.Since(up)
+			}
+		}
+		time.Sleep(time.Second)
+	}
+	for _, c := range checks {
+		if d, ok := recovered[c.name]; ok {
+			t.Logf("%s recovered %v after the interface came up", c.name, d.Round(time.Second))
+		} else {
+			t.Errorf("%s did not recover within %v: %v", c.name, networkPartitionRecoveryTimeout, lastErr[c.name])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const oomUnit = "cit-oom-test"
+
+// criticalServices are the services which must survive an OOM elsewhere on
+// the system. Services which are not installed are ignored.
+var criticalServices = []string{"google-guest-agent", "sshd", "ssh", "systemd-journald"}
+
+var oomKillRe = regexp.MustCompile(`Killed process (\d+) \(([^)]+)\)`)
+
+// serviceMainPID returns the main PID of an active service, or an empty
+// string if the service isn't active.
+func serviceMainPID(service string) string {
+	out, err := exec.Command("systemctl", "show", "--property=ActiveState,MainPID", service).Output()
+	if err != nil {
+		return ""
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		k, v, _ := strings.Cut(line, "=")
+		props[k] = v
+	}
+	if props["ActiveState"] != "active" {
+		return ""
+	}
+	return props["MainPID"]
+}
+
+// TestOOMResilience validates that the OOM killer reaps a process which runs
+// out of memory in its own cgroup without affecting the rest of the system.
+func TestOOMResilience(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("systemd-run") {
+		t.Skip("systemd-run is not available to create a memory limited cgroup")
+	}
+	before := make(map[string]string)
+	for _, service := range criticalServices {
+		if pid := serviceMainPID(service); pid != "" {
+			before[service] = pid
+		}
+	}
+	t.Logf("critical services before OOM: %v", before)
+	t.Cleanup(func() { exec.Command("systemctl", "stop", oomUnit+".scope").Run() })
+
+	// tail buffers /dev/zero looking for a newline, growing without bound.
+	start := time.Now()
+	cmd := exec.Command("systemd-run", "--scope", "--unit="+oomUnit, "-p", "MemoryMax=64M", "-p", "MemorySwapMax=0", "tail", "/dev/zero")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("memory hog exited without being killed: %v %s", err, out)
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() != syscall.SIGKILL {
+		t.Errorf("memory hog was killed by %v, want %v", status.Signal(), syscall.SIGKILL)
+	}
+
+	kernelLog, err := exec.Command("journalctl", "-k", "--no-pager", "-o", "cat", "--since", fmt.Sprintf("@%d", start.Unix())).Output()
+	if err != nil {
+		kernelLog, _ = exec.Command("dmesg").Output()
+	}
+	var killed []string
+	for _, m := range oomKillRe.FindAllStringSubmatch(string(kernelLog), -1) {
+		killed = append(killed, fmt.Sprintf("%s (pid %s)", m[2], m[1]))
+	}
+	t.Logf("OOM killer killed: %v", killed)
+	if len(killed) == 0 {
+		t.Error("no OOM kill was logged by the kernel")
+	}
+	for _, k := range killed {
+		if !strings.HasPrefix(k, "tail ") {
+			t.Errorf("OOM killer killed %s, want only the memory hog", k)
+		}
+	}
+
+	for service, pid := range before {
+		if after := serviceMainPID(service); after != pid {
+			t.Errorf("service %s had main pid %s before OOM, now %q", service, pid, after)
+		}
+	}
+	state, _ := exec.Command("systemctl", "is-system-running").Output()
+	t.Logf("system state after OOM is %s", strings.TrimSpace(string(state)))
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// panicRecoveryMarker records the panic so the next boot can confirm it
+	// is the recovery from it.
+	panicRecoveryMarker = "/var/cit-panic-recovery"
+	// panicTimeout is how long to wait for the panic to take the instance
+	// down before giving up.
+	panicTimeout = 2 * time.Minute
+)
+
+// panicRecoveryState is what the guest records before the panic.
+type panicRecoveryState struct {
+	PanicTime time.Time
+	// PanicReboot is kernel.panic, the seconds before rebooting on panic.
+	PanicReboot int
+	// Kdump is whether a crash kernel was loaded to capture a dump.
+	Kdump bool
+}
+
+// kdumpLoaded reports whether a crash kernel is loaded.
+func kdumpLoaded() bool {
+	data, err := os.ReadFile("/sys/kernel/kexec_crash_loaded")
+	return err == nil && strings.TrimSpace(string(data)) == "1"
+}
+
+// bootTime returns when the current boot started.
+func bootTime() (time.Time, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Time{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return time.Time{}, os.ErrInvalid
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-time.Duration(uptime * float64(time.Second))), nil
+}
+
+// TestPanicRecovery validates that the instance reboots by itself after a
+// kernel panic and comes back healthy.
+func TestPanicRecovery(t *testing.T) {
+	ctx := utils.Context(t)
+	if enabled, err := utils.GetMetadata(ctx, "instance", "attributes", "panic-recovery"); err != nil || enabled != "true" {
+		t.Skip("panic recovery is not enabled")
+	}
+	guard := utils.DefaultRebootGuard()
+	state, err := guard.State(t.Name())
+	if err != nil {
+		t.Fatalf("could not get reboot state: %v", err)
+	}
+
+	switch state {
+	case utils.RebootNone:
+		// first boot
+		value, err := os.ReadFile("/proc/sys/kernel/panic")
+		if err != nil {
+			t.Fatalf("before panic: could not read kernel.panic: %v", err)
+		}
+		before := panicRecoveryState{Kdump: kdumpLoaded()}
+		if before.PanicReboot, err = strconv.Atoi(strings.TrimSpace(string(value))); err != nil {
+			t.Fatalf("before panic: kernel.panic %q is not a number: %v", value, err)
+		}
+		// Without kdump, kernel.panic is all that brings the instance back.
+		if before.PanicReboot <= 0 && !before.Kdump {
+			t.Fatalf("before panic: kernel.panic is %d, the instance would not reboot after a panic", before.PanicReboot)
+		}
+		before.PanicTime = time.Now()
+		data, err := json.Marshal(before)
+		if err != nil {
+			t.Fatalf("before panic: could not marshal panic state: %v", err)
+		}
+		if err := os.WriteFile(panicRecoveryMarker, data, 0644); err != nil {
+			t.Fatalf("before panic: could not write panic state: %v", err)
+		}
+		if err := guard.Begin(t.Name()); err != nil {
+			t.Fatalf("before panic: %v", err)
+		}
+		if err := exec.Command("sync").Run(); err != nil {
+			t.Fatalf("before panic: could not sync filesystems: %v", err)
+		}
+		t.Logf("triggering panic with kernel.panic=%d, kdump loaded: %t", before.PanicReboot, before.Kdump)
+		if err := os.WriteFile("/proc/sysrq-trigger", []byte("c"), 0200); err != nil {
+			t.Fatalf("before panic: could not trigger panic: %v", err)
+		}
+		time.Sleep(panicTimeout)
+		t.Fatalf("before panic: instance still running %v after triggering a panic", panicTimeout)
+	case utils.RebootPending:
+		t.Fatal("after panic: instance did not reboot")
+	}
+
+	// after the panic
+	t.Cleanup(func() { guard.Release(t.Name()) })
+	data, err := os.ReadFile(panicRecoveryMarker)
+	if err != nil {
+		t.Fatalf("after panic: could not read panic state: %v", err)
+	}
+	var before panicRecoveryState
+	if err := json.Unmarshal(data, &before); err != nil {
+		t.Fatalf("after panic: could not parse panic state: %v", err)
+	}
+	booted, err := bootTime()
+	if err != nil {
+		t.Fatalf("after panic: could not get boot time: %v", err)
+	}
+	t.Logf("panic at %s, booted again at %s, %v later", before.PanicTime.Format(time.RFC3339), booted.Format(time.RFC3339), booted.Sub(before.PanicTime).Round(time.Second))
+
+	out, _ := exec.Command("systemctl", "is-system-running").Output()
+	switch status := strings.TrimSpace(string(out)); status {
+	case "running":
+	case "degraded":
+		failed, _ := exec.Command("systemctl", "--failed", "--no-legend", "--no-pager").Output()
+		t.Logf("after panic: system is degraded, failed units:\n%s", failed)
+	default:
+		t.Errorf("after panic: system state is %s, want running", status)
+	}
+	if _, err := utils.GetMetadata(ctx, "instance", "id"); err != nil {
+		t.Errorf("after panic: metadata server is unreachable: %v", err)
+	}
+
+	if !before.Kdump {
+		return
+	}
+	var dumps []string
+	for _, dir := range []string{"/var/crash", "/var/lib/kdump"} {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && info.ModTime().After(before.PanicTime) {
+				dumps = append(dumps, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	if len(dumps) == 0 {
+		t.Errorf("after panic: kdump was loaded but no crash dump was written after the panic")
+	} else {
+		t.Logf("after panic: crash dumps written: %v", dumps)
+	}
+}
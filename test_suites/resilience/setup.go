@@ -0,0 +1,203 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resilience is a CIT suite for testing that the guest stays healthy
+// under resource pressure and disruptive events.
+package resilience
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	daisy "github.com/GoogleCloudPlatform/compute-daisy"
+	"google.golang.org/api/compute/v1"
+)
+
+// Name is the name of the test package. It must match the directory name.
+var Name = "resilience"
+
+const (
+	// memoryResizeVM is the VM whose memory is resized by TestMemoryResizer.
+	memoryResizeVM = "memhotadd"
+	// memoryResizeNamespace is the guest attribute namespace memoryResizeVM
+	// uses to signal it is ready to be resized.
+	memoryResizeNamespace = "citMemoryResize"
+	// stopStartVM is the VM which is stopped and started by
+	// TestStopStartCycler.
+	stopStartVM = "stopstart"
+	// stopStartNamespace is the guest attribute namespace stopStartVM uses to
+	// signal it is ready to be stopped.
+	stopStartNamespace = "citStopStart"
+	// hostErrorVM is the VM which TestHostErrorTrigger terminates with a
+	// simulated host event.
+	hostErrorVM = "hosterror"
+	// hostErrorNamespace is the guest attribute namespace hostErrorVM uses to
+	// signal it is ready to be terminated and that it has recovered.
+	hostErrorNamespace = "citHostError"
+)
+
+var stopStartCycles = flag.Int("resilience_stop_start_cycles", 0, "number of times TestStopStartCycles stops and starts its instance. The test is skipped if unset, as each cycle adds several minutes and the workflow timeout may need to be raised")
+
+var networkPartition = flag.Bool("resilience_network_partition", false, "run TestNetworkPartitionRecovery, which brings the primary interface of its instance down for a minute")
+
+var panicRecovery = flag.Bool("resilience_panic_recovery", false, "run TestPanicRecovery, which panics the kernel of its instance and checks that it reboots and recovers")
+
+var lowDiskBoot = flag.Bool("resilience_low_disk", false, "run TestLowDiskBoot, which fills the root filesystem of its instance and reboots it")
+
+var hostError = flag.Bool("resilience_host_error", false, "run TestHostErrorRestart, which terminates its instance with a simulated host event and checks that it is restarted automatically")
+
+var idleDuration = flag.Duration("resilience_idle_duration", 0, "how long TestIdleStability leaves its instance idle. The test is skipped if unset, and the workflow timeout may need to be raised for long durations")
+
+// memoryResizeMachineTypes are the machine types memoryResizeVM starts and
+// ends with, by image architecture.
+var memoryResizeMachineTypes = map[string][2]string{
+	"X86_64": {"n2-standard-2", "n2-standard-4"},
+	"ARM64":  {"t2a-standard-2", "t2a-standard-4"},
+}
+
+// TestSetup sets up the test workflow.
+func TestSetup(t *imagetest.TestWorkflow) error {
+	if utils.HasFeature(t.Image, "WINDOWS") {
+		t.Skip("resilience tests are only implemented for linux")
+		return nil
+	}
+	oomvm, err := t.CreateTestVM("oom")
+	if err != nil {
+		return err
+	}
+	oomvm.RunTests("TestOOMResilience")
+
+	if *lowDiskBoot {
+		lowDiskInst := &daisy.Instance{}
+		lowDiskInst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
+		lowdiskvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "lowdisk"}}, lowDiskInst)
+		if err != nil {
+			return err
+		}
+		lowdiskvm.AddMetadata("low-disk-boot", "true")
+		if err := lowdiskvm.Reboot(); err != nil {
+			return err
+		}
+		lowdiskvm.RunTests("TestLowDiskBoot")
+	}
+
+	if machineTypes, ok := memoryResizeMachineTypes[t.Image.Architecture]; ok {
+		cpuvm, err := t.CreateTestVM("cpuoffline")
+		if err != nil {
+			return err
+		}
+		// CPU 0 usually can't be taken offline, so this needs a second vCPU.
+		cpuvm.ForceMachineType(machineTypes[0])
+		cpuvm.RunTests("TestCPUOffline")
+
+		resizeInst := &daisy.Instance{}
+		resizeInst.Name = memoryResizeVM
+		resizeInst.Scopes = append(resizeInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+		resizevm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: resizeInst.Name}}, resizeInst)
+		if err != nil {
+			return err
+		}
+		resizevm.ForceMachineType(machineTypes[0])
+		resizevm.AddMetadata("enable-guest-attributes", "true")
+		resizevm.AddMetadata("memory-resize-machine-type", machineTypes[1])
+		resizevm.RunTests("TestMemoryHotAdd")
+
+		resizerInst := &daisy.Instance{}
+		resizerInst.Name = "memresizer"
+		resizerInst.Scopes = append(resizerInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+		resizervm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: resizerInst.Name}}, resizerInst)
+		if err != nil {
+			return err
+		}
+		resizervm.AddMetadata("memory-resize-machine-type", machineTypes[1])
+		resizervm.RunTests("TestMemoryResizer")
+	}
+
+	if *stopStartCycles > 0 {
+		cycles := strconv.Itoa(*stopStartCycles)
+		stopStartInst := &daisy.Instance{}
+		stopStartInst.Name = stopStartVM
+		stopStartvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: stopStartInst.Name}, {Name: "stopstartdata", Type: imagetest.PdBalanced, SizeGb: 10}}, stopStartInst)
+		if err != nil {
+			return err
+		}
+		stopStartvm.AddMetadata("enable-guest-attributes", "true")
+		stopStartvm.AddMetadata("stop-start-cycles", cycles)
+		stopStartvm.RunTests("TestStopStartCycles")
+
+		cyclerInst := &daisy.Instance{}
+		cyclerInst.Name = "stopstartcycler"
+		cyclerInst.Scopes = append(cyclerInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+		cyclervm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: cyclerInst.Name}}, cyclerInst)
+		if err != nil {
+			return err
+		}
+		cyclervm.AddMetadata("stop-start-cycles", cycles)
+		cyclervm.RunTests("TestStopStartCycler")
+	}
+
+	if *networkPartition {
+		partitionvm, err := t.CreateTestVM("netpartition")
+		if err != nil {
+			return err
+		}
+		partitionvm.AddMetadata("network-partition", "true")
+		partitionvm.RunTests("TestNetworkPartitionRecovery")
+	}
+
+	if *panicRecovery {
+		panicvm, err := t.CreateTestVM("panicrecovery")
+		if err != nil {
+			return err
+		}
+		panicvm.AddMetadata("panic-recovery", "true")
+		panicvm.RunTests("TestPanicRecovery")
+	}
+
+	if *hostError {
+		automaticRestart := true
+		hostErrorInst := &daisy.Instance{}
+		hostErrorInst.Name = hostErrorVM
+		hostErrorInst.Scheduling = &compute.Scheduling{OnHostMaintenance: "TERMINATE", AutomaticRestart: &automaticRestart}
+		hostErrorvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: hostErrorInst.Name}, {Name: "hosterrordata", Type: imagetest.PdBalanced, SizeGb: 10}}, hostErrorInst)
+		if err != nil {
+			return err
+		}
+		hostErrorvm.AddMetadata("enable-guest-attributes", "true")
+		hostErrorvm.AddMetadata("host-error-restart", "true")
+		hostErrorvm.RunTests("TestHostErrorRestart")
+
+		triggerInst := &daisy.Instance{}
+		triggerInst.Name = "hosterrortrigger"
+		triggerInst.Scopes = append(triggerInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+		triggervm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: triggerInst.Name}}, triggerInst)
+		if err != nil {
+			return err
+		}
+		triggervm.AddMetadata("host-error-restart", "true")
+		triggervm.RunTests("TestHostErrorTrigger")
+	}
+
+	if *idleDuration > 0 {
+		idlevm, err := t.CreateTestVM("idle")
+		if err != nil {
+			return err
+		}
+		idlevm.AddMetadata("idle-duration", idleDuration.String())
+		idlevm.RunTests("TestIdleStability")
+	}
+	return nil
+}
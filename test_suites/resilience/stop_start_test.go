@@ -0,0 +1,192 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	stopStartMarker = "/var/cit-stop-start"
+	// stopStartTimeout is how long each side waits for the other in each
+	// cycle.
+	stopStartTimeout = 10 * time.Minute
+)
+
+// stopStartState is carried across boots by TestStopStartCycles. Results of
+// intermediate boots are never uploaded, so their failures are recorded here
+// and reported on the last boot.
+type stopStartState struct {
+	Boots    int      `json:"boots"`
+	Failures []string `json:"failures"`
+}
+
+// cyclesFromMetadata returns the number of stop/start cycles configured for the
+// instance, or zero if none are.
+func cyclesFromMetadata(ctx context.Context) int {
+	value, err := utils.GetMetadata(ctx, "instance", "attributes", "stop-start-cycles")
+	if err != nil {
+		return 0
+	}
+	cycles, _ := strconv.Atoi(value)
+	return cycles
+}
+
+// bootHealth returns the problems found with the current boot: units which
+// failed to start, disks which are not attached, and lost connectivity.
+func bootHealth(ctx context.Context) []string {
+	var problems []string
+	out, _ := exec.CommandContext(ctx, "systemctl", "is-system-running", "--wait").Output()
+	if state := strings.TrimSpace(string(out)); state != "running" {
+		failed, _ := exec.CommandContext(ctx, "systemctl", "--failed", "--no-legend", "--plain").Output()
+		problems = append(problems, fmt.Sprintf("system is %s, failed units: %s", state, strings.Join(strings.Fields(string(failed)), " ")))
+	}
+	for i := 0; ; i++ {
+		name, err := utils.GetMetadata(ctx, "instance", "disks", strconv.Itoa(i), "device-name")
+		if err != nil {
+			break
+		}
+		if _, err := os.Stat("/dev/disk/by-id/google-" + name); err != nil {
+			problems = append(problems, fmt.Sprintf("disk %s is not attached: %v", name, err))
+		}
+	}
+	conn, err := net.DialTimeout("tcp", "www.googleapis.com:443", 30*time.Second)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("could not reach www.googleapis.com: %v", err))
+	} else {
+		conn.Close()
+	}
+	return problems
+}
+
+// TestStopStartCycles validates that the instance boots cleanly, with all of
+// its disks and network connectivity, every time it is stopped and started.
+// TestStopStartCycler stops and starts it from another instance.
+func TestStopStartCycles(t *testing.T) {
+	ctx := utils.Context(t)
+	cycles := cyclesFromMetadata(ctx)
+	if cycles == 0 {
+		t.Skip("no stop/start cycles are configured")
+	}
+	var state stopStartState
+	data, err := os.ReadFile(stopStartMarker)
+	if err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Fatalf("could not parse stop/start state: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("could not read stop/start state: %v", err)
+	}
+	state.Boots++
+	problems := bootHealth(ctx)
+	for _, p := range problems {
+		state.Failures = append(state.Failures, fmt.Sprintf("boot %d: %s", state.Boots, p))
+	}
+	t.Logf("boot %d of %d found %d problems", state.Boots, cycles+1, len(problems))
+	if data, err = json.Marshal(state); err != nil {
+		t.Fatalf("could not marshal stop/start state: %v", err)
+	}
+	if err := os.WriteFile(stopStartMarker, data, 0644); err != nil {
+		t.Fatalf("could not write stop/start state: %v", err)
+	}
+
+	if state.Boots <= cycles {
+		if err := utils.PutMetadata(ctx, path.Join("instance", "guest-attributes", stopStartNamespace, fmt.Sprintf("boot%d", state.Boots)), "ready"); err != nil {
+			t.Fatalf("boot %d: could not signal readiness to be stopped: %v", state.Boots, err)
+		}
+		// The cycler stops the instance, so this only returns if the cycle
+		// never happened.
+		time.Sleep(stopStartTimeout)
+		t.Fatalf("boot %d: instance was not stopped within %v", state.Boots, stopStartTimeout)
+	}
+
+	// last boot
+	for _, f := range state.Failures {
+		t.Error(f)
+	}
+	t.Logf("instance booted %d times across %d stop/start cycles", state.Boots, cycles)
+}
+
+// TestStopStartCycler stops and starts the TestStopStartCycles instance each
+// time it is ready.
+func TestStopStartCycler(t *testing.T) {
+	ctx := utils.Context(t)
+	cycles := cyclesFromMetadata(ctx)
+	if cycles == 0 {
+		t.Skip("no stop/start cycles are configured")
+	}
+	instance, err := utils.GetRealVMName(stopStartVM)
+	if err != nil {
+		t.Fatalf("could not get name of instance to stop and start: %v", err)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	for cycle := 1; cycle <= cycles; cycle++ {
+		ready := false
+		for start := time.Now(); time.Since(start) < stopStartTimeout; time.Sleep(10 * time.Second) {
+			_, err := client.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+				Project:     prj,
+				Zone:        zone,
+				Instance:    instance,
+				VariableKey: proto.String(fmt.Sprintf("%s/boot%d", stopStartNamespace, cycle)),
+			})
+			if err == nil {
+				ready = true
+				break
+			}
+		}
+		if !ready {
+			t.Fatalf("cycle %d: %s did not boot and signal it was ready within %v", cycle, instance, stopStartTimeout)
+		}
+		stop, err := client.Stop(ctx, &computepb.StopInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+		if err == nil {
+			err = stop.Wait(ctx)
+		}
+		if err != nil {
+			t.Fatalf("cycle %d: could not stop %s: %v", cycle, instance, err)
+		}
+		start, err := client.Start(ctx, &computepb.StartInstanceRequest{Project: prj, Zone: zone, Instance: instance})
+		if err == nil {
+			err = start.Wait(ctx)
+		}
+		if err != nil {
+			t.Fatalf("cycle %d: could not start %s: %v", cycle, instance, err)
+		}
+		t.Logf("cycle %d: stopped and started %s", cycle, instance)
+	}
+}
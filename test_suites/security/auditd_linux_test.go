@@ -0,0 +1,136 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const auditLog = "/var/log/audit/audit.log"
+
+// auditBaseline is the audit configuration an image is expected to load.
+type auditBaseline struct {
+	// status holds expected values of fields from auditctl -s.
+	status map[string]string
+	// rules holds rules expected in the output of auditctl -l.
+	rules []string
+}
+
+// auditdImages maps a substring of the image name to the audit baseline of
+// images which ship auditd.
+var auditdImages = map[string]auditBaseline{
+	"rhel": {
+		status: map[string]string{"enabled": "1", "failure": "1", "backlog_limit": "8192"},
+	},
+	"centos": {
+		status: map[string]string{"enabled": "1", "failure": "1", "backlog_limit": "8192"},
+	},
+	"rocky-linux": {
+		status: map[string]string{"enabled": "1", "failure": "1", "backlog_limit": "8192"},
+	},
+	"almalinux": {
+		status: map[string]string{"enabled": "1", "failure": "1", "backlog_limit": "8192"},
+	},
+	"sles": {
+		status: map[string]string{"enabled": "1"},
+	},
+}
+
+// TestAuditd validates that auditd is running with the image's baseline
+// configuration and writing the audit log.
+func TestAuditd(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	var baseline auditBaseline
+	expected := false
+	for substr, b := range auditdImages {
+		if strings.Contains(image, substr) {
+			baseline, expected = b, true
+		}
+	}
+	if !utils.CheckLinuxCmdExists("auditctl") {
+		if expected {
+			t.Fatalf("auditctl not found, image %s should ship auditd", image)
+		}
+		t.Skip("auditd is not installed")
+	}
+
+	if out, err := exec.Command("systemctl", "is-active", "auditd.service").Output(); err != nil {
+		t.Fatalf("auditd is not active: %s %v", strings.TrimSpace(string(out)), err)
+	}
+
+	out, err := exec.Command("auditctl", "-s").Output()
+	if err != nil {
+		t.Fatalf("auditctl -s failed: %v", err)
+	}
+	status := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			status[fields[0]] = fields[1]
+		}
+	}
+	for field, want := range baseline.status {
+		if got := status[field]; got != want {
+			t.Errorf("audit %s is %q, want %q", field, got, want)
+		}
+	}
+
+	out, err = exec.Command("auditctl", "-l").Output()
+	if err != nil {
+		t.Fatalf("auditctl -l failed: %v", err)
+	}
+	loaded := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" && line != "No rules" {
+			loaded[line] = true
+		}
+	}
+	t.Logf("%d audit rules loaded", len(loaded))
+	for _, rule := range baseline.rules {
+		if !loaded[rule] {
+			t.Errorf("audit rule %q is not loaded", rule)
+		}
+	}
+
+	// Write a user message and check it reaches the audit log.
+	marker := fmt.Sprintf("cit-audit-%d", time.Now().UnixNano())
+	if out, err := exec.Command("auditctl", "-m", marker).CombinedOutput(); err != nil {
+		t.Fatalf("auditctl -m failed: %s %v", out, err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		data, err := os.ReadFile(auditLog)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", auditLog, err)
+		}
+		if strings.Contains(string(data), marker) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("audit message %q was not written to %s", marker, auditLog)
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const cryptoPolicyBackends = "/etc/crypto-policies/back-ends"
+
+// cryptoPolicyImages are the images which use system-wide crypto policies.
+var cryptoPolicyImages = []string{"rhel", "centos", "rocky-linux", "almalinux", "fedora"}
+
+// expectedCryptoPolicies maps a substring of the image name to its crypto
+// policy, for images whose policy is not DEFAULT.
+var expectedCryptoPolicies = map[string]string{
+	"fips": "FIPS",
+}
+
+// TestCryptoPolicy validates that the system-wide crypto policy is the one
+// expected for the image, and that the OpenSSL and GnuTLS back-ends apply it.
+func TestCryptoPolicy(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	usesPolicies := false
+	for _, family := range cryptoPolicyImages {
+		if strings.Contains(image, family) {
+			usesPolicies = true
+		}
+	}
+	if !usesPolicies {
+		t.Skipf("image %s does not use system-wide crypto policies", image)
+	}
+	if !utils.CheckLinuxCmdExists("update-crypto-policies") {
+		t.Skip("update-crypto-policies is not installed")
+	}
+	expected := "DEFAULT"
+	for substr, policy := range expectedCryptoPolicies {
+		if strings.Contains(image, substr) {
+			expected = policy
+		}
+	}
+
+	out, err := exec.Command("update-crypto-policies", "--show").Output()
+	if err != nil {
+		t.Fatalf("update-crypto-policies --show failed: %v", err)
+	}
+	active := strings.TrimSpace(string(out))
+	t.Logf("active crypto policy is %s, expected %s", active, expected)
+	if active != expected {
+		t.Errorf("crypto policy is %s, want %s", active, expected)
+	}
+	if current, err := os.ReadFile("/etc/crypto-policies/state/current"); err == nil {
+		if applied := strings.TrimSpace(string(current)); applied != active {
+			t.Errorf("applied crypto policy is %s, configured policy is %s", applied, active)
+		}
+	}
+	// --check is missing from older versions.
+	if help, _ := exec.Command("update-crypto-policies", "--help").CombinedOutput(); !strings.Contains(string(help), "--check") {
+		t.Log("update-crypto-policies does not support --check")
+	} else if out, err := exec.Command("update-crypto-policies", "--check").CombinedOutput(); err != nil {
+		t.Errorf("generated crypto policy back-ends don't match the configured policy: %v %s", err, out)
+	}
+
+	// Unmodified policies link each back-end to the policy's copy in
+	// /usr/share/crypto-policies.
+	policy, _, _ := strings.Cut(active, ":")
+	for _, backend := range []string{"opensslcnf.config", "gnutls.config"} {
+		path := filepath.Join(cryptoPolicyBackends, backend)
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			t.Errorf("crypto policy back-end %s is missing: %v", path, err)
+			continue
+		}
+		t.Logf("%s is %s", path, target)
+		if dir := filepath.Base(filepath.Dir(target)); strings.HasPrefix(target, "/usr/share/crypto-policies/") && dir != policy {
+			t.Errorf("%s applies the %s policy, want %s", path, dir, policy)
+		}
+	}
+	opensslCnf, err := os.ReadFile("/etc/pki/tls/openssl.cnf")
+	if err != nil {
+		t.Fatalf("could not read openssl configuration: %v", err)
+	}
+	if !strings.Contains(string(opensslCnf), filepath.Join(cryptoPolicyBackends, "opensslcnf.config")) {
+		t.Errorf("/etc/pki/tls/openssl.cnf does not include the crypto policy back-end")
+	}
+}
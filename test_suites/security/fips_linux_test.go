@@ -0,0 +1,135 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// cryptoSelftests returns the names of kernel crypto algorithms in
+// /proc/crypto whose self test did not pass.
+func cryptoSelftests() ([]string, int, error) {
+	data, err := os.ReadFile("/proc/crypto")
+	if err != nil {
+		return nil, 0, err
+	}
+	var failed []string
+	var count int
+	for _, block := range strings.Split(string(data), "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(block, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if ok {
+				fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		if fields["driver"] == "" {
+			continue
+		}
+		count++
+		if selftest, ok := fields["selftest"]; ok && selftest != "passed" {
+			failed = append(failed, fields["driver"])
+		}
+	}
+	return failed, count, nil
+}
+
+// opensslProviderStatus returns the status of the provider with the given id
+// from the output of openssl list -providers.
+func opensslProviderStatus(out, id string) (string, bool) {
+	var current string
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "Providers:" {
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			current = trimmed
+			if current == id {
+				found = true
+			}
+			continue
+		}
+		if current == id && strings.TrimSpace(key) == "status" {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", found
+}
+
+// TestFIPSMode validates that the kernel and OpenSSL of a FIPS image are in
+// FIPS mode.
+func TestFIPSMode(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	if !strings.Contains(image, "fips") {
+		t.Skipf("image %s is not a FIPS image", image)
+	}
+
+	enabled, err := sysctlGet("crypto.fips_enabled")
+	if err != nil {
+		t.Fatalf("could not read crypto.fips_enabled: %v", err)
+	}
+	t.Logf("kernel: crypto.fips_enabled = %s", enabled)
+	if enabled != "1" {
+		t.Errorf("kernel is not in FIPS mode, crypto.fips_enabled = %s", enabled)
+	}
+	cmdline, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		t.Fatalf("could not read kernel command line: %v", err)
+	}
+	t.Logf("kernel: fips=1 on command line: %t", strings.Contains(string(cmdline), "fips=1"))
+
+	failed, count, err := cryptoSelftests()
+	if err != nil {
+		t.Fatalf("could not read /proc/crypto: %v", err)
+	}
+	t.Logf("kernel crypto: %d algorithms registered, %d failed self test", count, len(failed))
+	if len(failed) > 0 {
+		t.Errorf("kernel crypto algorithms failed self test: %s", strings.Join(failed, ", "))
+	}
+
+	if !utils.CheckLinuxCmdExists("openssl") {
+		t.Log("openssl: not installed")
+		return
+	}
+	if out, err := exec.Command("openssl", "list", "-providers").Output(); err == nil {
+		// OpenSSL 3, FIPS is a provider.
+		t.Logf("openssl: providers:\n%s", out)
+		if status, ok := opensslProviderStatus(string(out), "fips"); !ok {
+			t.Errorf("openssl FIPS provider is not loaded")
+		} else if status != "active" {
+			t.Errorf("openssl FIPS provider status is %q, want active", status)
+		}
+	}
+	// MD5 is not an approved algorithm and must be refused in FIPS mode.
+	cmd := exec.Command("openssl", "dgst", "-md5")
+	cmd.Stdin = strings.NewReader("cit")
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("openssl computed an MD5 digest in FIPS mode: %s", strings.TrimSpace(string(out)))
+	} else {
+		t.Log("openssl: MD5 refused")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	backendNFTables = "nf_tables"
+	backendLegacy   = "legacy"
+)
+
+// expectedFirewallBackends maps image names to the netfilter backend of their
+// iptables. Images which match none are only reported.
+var expectedFirewallBackends = []struct {
+	image   *regexp.Regexp
+	backend string
+}{
+	{regexp.MustCompile(`^debian-1[0-9]`), backendNFTables},
+	{regexp.MustCompile(`^ubuntu(-pro)?(-minimal)?-2004`), backendLegacy},
+	{regexp.MustCompile(`^ubuntu(-pro)?(-minimal)?-2[2-9][0-9]{2}`), backendNFTables},
+	{regexp.MustCompile(`^(rhel|centos|rocky-linux|almalinux)-[89]`), backendNFTables},
+	{regexp.MustCompile(`^(rhel|centos)-7`), backendLegacy},
+}
+
+var iptablesBackendRe = regexp.MustCompile(`\((nf_tables|legacy)\)`)
+
+// iptablesBackend returns the backend reported by iptables --version.
+// Versions before 1.8 have no nf_tables backend and don't report one.
+func iptablesBackend() (string, string, error) {
+	out, err := exec.Command("iptables", "--version").Output()
+	if err != nil {
+		return "", "", err
+	}
+	version := strings.TrimSpace(string(out))
+	if m := iptablesBackendRe.FindStringSubmatch(version); m != nil {
+		return m[1], version, nil
+	}
+	return backendLegacy, version, nil
+}
+
+// TestFirewallBackend validates that iptables uses the netfilter backend
+// expected for the image, and that no rules are loaded through the other one.
+func TestFirewallBackend(t *testing.T) {
+	utils.LinuxOnly(t)
+	if !utils.CheckLinuxCmdExists("iptables") {
+		t.Skip("iptables is not installed")
+	}
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	image = filepath.Base(image)
+
+	backend, version, err := iptablesBackend()
+	if err != nil {
+		t.Fatalf("iptables --version failed: %v", err)
+	}
+	t.Logf("iptables backend is %s (%s)", backend, version)
+	if path, err := exec.LookPath("iptables"); err == nil {
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			t.Logf("%s resolves to %s", path, target)
+		}
+	}
+	if utils.CheckLinuxCmdExists("update-alternatives") {
+		if out, err := exec.Command("update-alternatives", "--query", "iptables").Output(); err == nil {
+			for _, line := range strings.Split(string(out), "\n") {
+				if strings.HasPrefix(line, "Value:") {
+					t.Logf("iptables alternative is %s", strings.TrimSpace(strings.TrimPrefix(line, "Value:")))
+				}
+			}
+		}
+	}
+
+	for _, e := range expectedFirewallBackends {
+		if e.image.MatchString(image) {
+			if backend != e.backend {
+				t.Errorf("iptables backend is %s, want %s", backend, e.backend)
+			}
+			break
+		}
+	}
+
+	// Rules loaded through the other backend are invisible to this iptables.
+	var other, save string
+	switch {
+	case backend == backendNFTables && utils.CheckLinuxCmdExists("iptables-legacy-save"):
+		other, save = backendLegacy, "iptables-legacy-save"
+	case backend == backendLegacy && utils.CheckLinuxCmdExists("iptables-nft-save"):
+		other, save = backendNFTables, "iptables-nft-save"
+	default:
+		return
+	}
+	out, err := exec.Command(save).Output()
+	if err != nil {
+		t.Fatalf("%s failed: %v", save, err)
+	}
+	var rules []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "-A ") {
+			rules = append(rules, line)
+		}
+	}
+	if len(rules) > 0 {
+		t.Errorf("%d rules are loaded through the %s backend while iptables uses %s:\n%s", len(rules), other, backend, strings.Join(rules, "\n"))
+	}
+}
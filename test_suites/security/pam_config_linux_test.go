@@ -0,0 +1,221 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// pamDirs are searched in order for PAM service files. Images using the
+// vendor directory only put local overrides in /etc/pam.d.
+var pamDirs = []string{"/etc/pam.d", "/usr/lib/pam.d", "/usr/etc/pam.d"}
+
+// pamServices are the services whose stacks are checked.
+var pamServices = []string{"su", "sudo", "sshd"}
+
+// pamEntry is a module in an effective PAM stack.
+type pamEntry struct {
+	control string
+	module  string
+	args    []string
+	// from is the file the entry is defined in.
+	from string
+}
+
+// findPAMFile returns the path of a PAM service or included file.
+func findPAMFile(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	for _, dir := range pamDirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %v", name, pamDirs)
+}
+
+// pamStack returns the effective stack of the given type, such as auth, for
+// a PAM service, expanding includes and substacks.
+func pamStack(name, stackType string, depth int) ([]pamEntry, error) {
+	if depth > 10 {
+		return nil, fmt.Errorf("PAM includes nested too deep at %s", name)
+	}
+	path, err := findPAMFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var stack []pamEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if included, ok := strings.CutPrefix(line, "@include"); ok {
+			entries, err := pamStack(strings.TrimSpace(included), stackType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, entries...)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.TrimPrefix(fields[0], "-") != stackType {
+			continue
+		}
+		// Bracketed controls can contain spaces.
+		control, rest := fields[1], fields[2:]
+		if strings.HasPrefix(control, "[") {
+			for len(rest) > 0 && !strings.HasSuffix(control, "]") {
+				control += " " + rest[0]
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				continue
+			}
+		}
+		if control == "include" || control == "substack" {
+			entries, err := pamStack(rest[0], stackType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, entries...)
+			continue
+		}
+		stack = append(stack, pamEntry{control: control, module: filepath.Base(rest[0]), args: rest[1:], from: path})
+	}
+	return stack, scanner.Err()
+}
+
+// pamIndex returns the index of the first entry for module in stack, or -1.
+func pamIndex(stack []pamEntry, module string, args ...string) int {
+Entries:
+	for i, e := range stack {
+		if e.module != module {
+			continue
+		}
+		for _, arg := range args {
+			found := false
+			for _, a := range e.args {
+				if a == arg {
+					found = true
+				}
+			}
+			if !found {
+				continue Entries
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// TestPAMConfig validates the PAM stacks of su, sudo and sshd are ordered so
+// that OS Login and account lockout work and authentication can't be bypassed.
+func TestPAMConfig(t *testing.T) {
+	utils.LinuxOnly(t)
+	if policy, err := os.ReadFile("/etc/security/faillock.conf"); err == nil {
+		for _, line := range strings.Split(string(policy), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				t.Logf("faillock.conf: %s", line)
+			}
+		}
+	}
+	for _, service := range pamServices {
+		if _, err := findPAMFile(service); err != nil {
+			t.Logf("skipping %s: %v", service, err)
+			continue
+		}
+		stacks := make(map[string][]pamEntry)
+		for _, stackType := range []string{"auth", "account", "password", "session"} {
+			stack, err := pamStack(service, stackType, 0)
+			if err != nil {
+				t.Fatalf("could not read %s %s stack: %v", service, stackType, err)
+			}
+			stacks[stackType] = stack
+			var order []string
+			for _, e := range stack {
+				order = append(order, e.control+" "+e.module)
+			}
+			t.Logf("%s %s: %s", service, stackType, strings.Join(order, ", "))
+		}
+		auth, account := stacks["auth"], stacks["account"]
+
+		// pam_permit succeeds for anyone, so a deny must come first.
+		if permit := pamIndex(auth, "pam_permit.so"); permit >= 0 {
+			if deny := pamIndex(auth, "pam_deny.so"); deny < 0 || deny > permit {
+				t.Errorf("%s auth stack reaches pam_permit.so (%s) without a pam_deny.so before it", service, auth[permit].from)
+			}
+		}
+		if len(auth) == 0 {
+			t.Errorf("%s has no auth modules", service)
+		}
+
+		// Lockout only works if failures are checked before and recorded
+		// after the password check.
+		if unix := pamIndex(auth, "pam_unix.so"); unix >= 0 {
+			if preauth := pamIndex(auth, "pam_faillock.so", "preauth"); preauth > unix {
+				t.Errorf("%s auth stack runs pam_faillock.so preauth after pam_unix.so", service)
+			}
+			if authfail := pamIndex(auth, "pam_faillock.so", "authfail"); authfail >= 0 && authfail < unix {
+				t.Errorf("%s auth stack runs pam_faillock.so authfail before pam_unix.so", service)
+			}
+		}
+
+		if faillock := pamIndex(auth, "pam_faillock.so"); faillock >= 0 {
+			t.Logf("%s locks accounts with pam_faillock.so %s", service, strings.Join(auth[faillock].args, " "))
+		}
+
+		switch service {
+		case "su":
+			// Root may su without a password, but only as the first check.
+			if rootok := pamIndex(auth, "pam_rootok.so"); rootok < 0 {
+				t.Errorf("su auth stack has no pam_rootok.so")
+			} else if rootok != 0 {
+				t.Errorf("su auth stack runs %s before pam_rootok.so", auth[0].module)
+			}
+			if wheel := pamIndex(auth, "pam_wheel.so"); wheel >= 0 {
+				t.Logf("su is restricted by pam_wheel.so %s", strings.Join(auth[wheel].args, " "))
+			}
+		case "sshd":
+			// OS Login decides access for its users, so it must run before
+			// the local account checks.
+			login := pamIndex(account, "pam_oslogin_login.so")
+			if login < 0 {
+				break
+			}
+			if unix := pamIndex(account, "pam_unix.so"); unix >= 0 && unix < login {
+				t.Errorf("sshd account stack runs pam_unix.so before pam_oslogin_login.so")
+			}
+			if admin := pamIndex(account, "pam_oslogin_admin.so"); admin >= 0 && admin < login {
+				t.Errorf("sshd account stack runs pam_oslogin_admin.so before pam_oslogin_login.so")
+			}
+		}
+	}
+}
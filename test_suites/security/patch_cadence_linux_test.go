@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// patchSchedule is the job expected to apply security updates on an image.
+type patchSchedule struct {
+	// images are substrings of the image names the schedule applies to.
+	images []string
+	// timers are the systemd timers which may apply updates. The first one
+	// enabled is checked.
+	timers []string
+	// cronJob is the cron script which applies updates on images without a
+	// timer.
+	cronJob string
+	// maxInterval is the longest time allowed between runs.
+	maxInterval time.Duration
+}
+
+// patchSchedules are checked in order, so more specific images come first.
+var patchSchedules = []patchSchedule{
+	{images: []string{"rhel-7", "centos-7"}, cronJob: "/etc/cron.daily/0yum-daily.cron", maxInterval: 24 * time.Hour},
+	{images: []string{"debian", "ubuntu"}, timers: []string{"apt-daily-upgrade.timer"}, maxInterval: 24 * time.Hour},
+	{images: []string{"rhel", "centos", "rocky-linux", "almalinux"}, timers: []string{"dnf-automatic.timer", "dnf-automatic-install.timer", "dnf5-automatic.timer"}, maxInterval: 24 * time.Hour},
+}
+
+var (
+	// onCalendarRe matches the calendar expressions in systemctl's
+	// TimersCalendar property.
+	onCalendarRe = regexp.MustCompile(`OnCalendar=(.+?) ;`)
+	// calendarElapseRe matches the first two elapses printed by
+	// systemd-analyze calendar --iterations=2.
+	calendarElapseRe = regexp.MustCompile(`(?m)^\s*(?:Next elapse|Iter\. #2): \w+ (\d{4}-\d\d-\d\d \d\d:\d\d:\d\d)`)
+	// dailyCalendarRe matches calendar expressions which elapse once a day.
+	dailyCalendarRe = regexp.MustCompile(`^\*-\*-\* \d+:\d+(:\d+)?$`)
+)
+
+// calendarShorthands are the intervals of systemd's calendar shorthands.
+var calendarShorthands = map[string]time.Duration{
+	"minutely": time.Minute,
+	"hourly":   time.Hour,
+	"daily":    24 * time.Hour,
+	"weekly":   7 * 24 * time.Hour,
+	"monthly":  31 * 24 * time.Hour,
+}
+
+// calendarInterval returns the time between elapses of a systemd calendar
+// expression.
+func calendarInterval(expr string) (time.Duration, error) {
+	if interval, ok := calendarShorthands[expr]; ok {
+		return interval, nil
+	}
+	// --iterations is missing from older versions of systemd.
+	if out, err := exec.Command("systemd-analyze", "calendar", "--iterations=2", expr).Output(); err == nil {
+		if m := calendarElapseRe.FindAllStringSubmatch(string(out), -1); len(m) == 2 {
+			first, err1 := time.Parse(time.DateTime, m[0][1])
+			second, err2 := time.Parse(time.DateTime, m[1][1])
+			if err1 == nil && err2 == nil {
+				return second.Sub(first), nil
+			}
+		}
+	}
+	if dailyCalendarRe.MatchString(expr) {
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("cannot determine the interval of %q", expr)
+}
+
+// systemdProperty returns the value of a property of a systemd unit.
+func systemdProperty(unit, property string) string {
+	out, _ := exec.Command("systemctl", "show", "-p", property, "--value", unit).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// TestPatchCadence validates that the job which applies security updates is
+// enabled and scheduled at least as often as expected for the image, without
+// running it.
+func TestPatchCadence(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	var schedule *patchSchedule
+Schedules:
+	for i := range patchSchedules {
+		for _, substr := range patchSchedules[i].images {
+			if strings.Contains(image, substr) {
+				schedule = &patchSchedules[i]
+				break Schedules
+			}
+		}
+	}
+	if schedule == nil {
+		t.Skipf("no patch schedule is expected for image %s", image)
+	}
+
+	if schedule.cronJob != "" {
+		fi, err := os.Stat(schedule.cronJob)
+		if err != nil {
+			t.Fatalf("update job %s is missing: %v", schedule.cronJob, err)
+		}
+		if fi.Mode()&0111 == 0 {
+			t.Errorf("update job %s is not executable", schedule.cronJob)
+		}
+		t.Logf("updates are applied daily by %s", schedule.cronJob)
+		return
+	}
+
+	var timer string
+	for _, candidate := range schedule.timers {
+		if exec.Command("systemctl", "is-enabled", "--quiet", candidate).Run() == nil {
+			timer = candidate
+			break
+		}
+	}
+	if timer == "" {
+		t.Fatalf("none of %s is enabled", strings.Join(schedule.timers, ", "))
+	}
+	if state := systemdProperty(timer, "ActiveState"); state != "active" {
+		t.Errorf("%s is %s, want active", timer, state)
+	}
+	next := systemdProperty(timer, "NextElapseUSecRealtime")
+	t.Logf("%s next runs at %s, last ran at %s", timer, next, systemdProperty(timer, "LastTriggerUSec"))
+	if next == "" || next == "n/a" {
+		t.Errorf("%s has no next run scheduled", timer)
+	}
+
+	calendars := onCalendarRe.FindAllStringSubmatch(systemdProperty(timer, "TimersCalendar"), -1)
+	if len(calendars) == 0 {
+		t.Fatalf("%s has no OnCalendar schedule", timer)
+	}
+	var shortest time.Duration
+	for _, calendar := range calendars {
+		interval, err := calendarInterval(calendar[1])
+		if err != nil {
+			t.Errorf("%s: %v", timer, err)
+			continue
+		}
+		t.Logf("%s runs on %q, every %v", timer, calendar[1], interval)
+		if shortest == 0 || interval < shortest {
+			shortest = interval
+		}
+	}
+	if shortest > schedule.maxInterval {
+		t.Errorf("%s runs every %v, want at least every %v", timer, shortest, schedule.maxInterval)
+	}
+
+	if strings.HasPrefix(timer, "dnf") {
+		// dnf-automatic.timer only downloads updates unless configured to
+		// apply them, dnf-automatic-install.timer always applies them.
+		for _, conf := range []string{"/etc/dnf/automatic.conf", "/etc/dnf/dnf5-plugins/automatic.conf"} {
+			if data, err := os.ReadFile(conf); err == nil {
+				for _, line := range strings.Split(string(data), "\n") {
+					if key, _, ok := strings.Cut(line, "="); ok && (strings.TrimSpace(key) == "apply_updates" || strings.TrimSpace(key) == "upgrade_type") {
+						t.Logf("%s: %s", conf, strings.TrimSpace(line))
+					}
+				}
+			}
+		}
+	}
+}
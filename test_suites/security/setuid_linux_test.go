@@ -0,0 +1,161 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// setuidAllowlist maps a substring of the image name to patterns of binaries
+// which may be setuid or setgid on that image. The empty key applies to every
+// image. Patterns use filepath.Match syntax and are written under /usr;
+// binaries in /bin, /sbin and /lib on images without a merged /usr match the
+// same patterns.
+var setuidAllowlist = map[string][]string{
+	"": {
+		"/usr/bin/at",
+		"/usr/bin/chage",
+		"/usr/bin/chfn",
+		"/usr/bin/chsh",
+		"/usr/bin/crontab",
+		"/usr/bin/expiry",
+		"/usr/bin/fusermount",
+		"/usr/bin/fusermount3",
+		"/usr/bin/gpasswd",
+		"/usr/bin/mount",
+		"/usr/bin/newgidmap",
+		"/usr/bin/newgrp",
+		"/usr/bin/newuidmap",
+		"/usr/bin/passwd",
+		"/usr/bin/pkexec",
+		"/usr/bin/ssh-agent",
+		"/usr/bin/su",
+		"/usr/bin/sudo",
+		"/usr/bin/umount",
+		"/usr/bin/wall",
+		"/usr/bin/write",
+		"/usr/lib/*/utempter/utempter",
+		"/usr/lib/dbus-1.0/dbus-daemon-launch-helper",
+		"/usr/lib/openssh/ssh-keysign",
+		"/usr/lib/polkit-1/polkit-agent-helper-1",
+		"/usr/libexec/dbus-1/dbus-daemon-launch-helper",
+		"/usr/libexec/openssh/ssh-keysign",
+		"/usr/libexec/polkit-agent-helper-1",
+		"/usr/libexec/utempter/utempter",
+		"/usr/sbin/pam_timestamp_check",
+		"/usr/sbin/unix_chkpwd",
+	},
+	"debian": {
+		"/usr/bin/bsd-write",
+		"/usr/sbin/pam_extrausers_chkpwd",
+	},
+	"ubuntu": {
+		"/usr/bin/bsd-write",
+		"/usr/lib/snapd/snap-confine",
+		"/usr/sbin/pam_extrausers_chkpwd",
+	},
+	"rhel": {
+		"/usr/sbin/grub2-set-bootflag",
+		"/usr/sbin/userhelper",
+	},
+	"centos": {
+		"/usr/sbin/grub2-set-bootflag",
+		"/usr/sbin/userhelper",
+	},
+	"rocky-linux": {
+		"/usr/sbin/grub2-set-bootflag",
+		"/usr/sbin/userhelper",
+	},
+	"almalinux": {
+		"/usr/sbin/grub2-set-bootflag",
+		"/usr/sbin/userhelper",
+	},
+	"sles": {
+		"/usr/lib/utempter/utempter",
+		"/usr/sbin/postdrop",
+		"/usr/sbin/postqueue",
+	},
+	"-sap": {
+		"/usr/sbin/mount.nfs",
+	},
+}
+
+// setuidPatterns returns the allowlisted patterns which apply to image.
+func setuidPatterns(image string) []string {
+	var patterns []string
+	for substr, p := range setuidAllowlist {
+		if strings.Contains(image, substr) {
+			patterns = append(patterns, p...)
+		}
+	}
+	return patterns
+}
+
+// matchSetuid returns the pattern matching path, or an empty string.
+func matchSetuid(patterns []string, path string) string {
+	candidates := []string{path}
+	if !strings.HasPrefix(path, "/usr/") {
+		candidates = append(candidates, "/usr"+path)
+	}
+	for _, p := range patterns {
+		for _, c := range candidates {
+			if ok, _ := filepath.Match(p, c); ok {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// TestSetuidInventory validates that every setuid or setgid binary in the
+// system directories is allowlisted for the image.
+func TestSetuidInventory(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	patterns := setuidPatterns(image)
+	found := make(map[string]bool)
+	walkSystemPaths(t, func(path string, info fs.FileInfo) {
+		mode := info.Mode()
+		if !mode.IsRegular() || mode&(fs.ModeSetuid|fs.ModeSetgid) == 0 {
+			return
+		}
+		p := matchSetuid(patterns, path)
+		if p == "" {
+			t.Errorf("unexpected setuid or setgid binary %s, mode %s", path, mode)
+			return
+		}
+		t.Logf("%s has mode %s", path, mode)
+		found[p] = true
+	})
+	var missing []string
+	for _, p := range patterns {
+		if !found[p] {
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Logf("allowlisted binaries not found setuid or setgid: %s", strings.Join(missing, ", "))
+	}
+}
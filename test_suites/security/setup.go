@@ -15,7 +15,12 @@
 // Package security tests security related OS settings are configured correctly.
 package security
 
-import "github.com/GoogleCloudPlatform/cloud-image-tests"
+import (
+	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	daisy "github.com/GoogleCloudPlatform/compute-daisy"
+	"google.golang.org/api/compute/v1"
+)
 
 // Name is the name of the test package. It must match the directory name.
 var Name = "security"
@@ -23,7 +28,26 @@ var Name = "security"
 // TestSetup sets up the test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
 	vm, err := t.CreateTestVM("securitySetttings")
+	if err != nil {
+		return err
+	}
 	vm.AddMetadata("enable-windows-ssh", "true")
 	vm.AddMetadata("sysprep-specialize-script-cmd", "googet -noconfirm=true install google-compute-engine-ssh")
-	return err
+	vm.RunTests("TestKernelSecuritySettings|TestAutomaticUpdates|TestPasswordSecurity|TestSockets|TestWorldWritable|TestSetuidInventory|TestUmask|TestAuditd|TestFIPSMode|TestCryptoPolicy|TestFirewallBackend|TestPAMConfig|TestPatchCadence")
+
+	if !utils.HasFeature(t.Image, "UEFI_COMPATIBLE") {
+		return nil
+	}
+	integrityInst := &daisy.Instance{}
+	integrityInst.ShieldedInstanceConfig = &compute.ShieldedInstanceConfig{
+		EnableVtpm:                true,
+		EnableIntegrityMonitoring: true,
+	}
+	integrityVM, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "shieldedintegrity"}}, integrityInst)
+	if err != nil {
+		return err
+	}
+	integrityVM.AddScope("https://www.googleapis.com/auth/cloud-platform")
+	integrityVM.RunTests("TestShieldedIntegrity")
+	return nil
 }
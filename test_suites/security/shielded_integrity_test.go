@@ -0,0 +1,140 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	logging "google.golang.org/api/logging/v2"
+)
+
+// integrityReportTimeout is how long to wait for the integrity report of the
+// current boot to be written to Cloud Logging.
+const integrityReportTimeout = 10 * time.Minute
+
+// integrityMeasurement is a single PCR measurement in an integrity report.
+type integrityMeasurement struct {
+	PCRNum string `json:"pcrNum"`
+	Hash   string `json:"hash"`
+	Value  string `json:"value"`
+}
+
+// bootReport is the early or late boot report from the shielded VM integrity
+// log.
+type bootReport struct {
+	PolicyEvaluationPassed bool                   `json:"policyEvaluationPassed"`
+	ActualMeasurements     []integrityMeasurement `json:"actualMeasurements"`
+	PolicyMeasurements     []integrityMeasurement `json:"policyMeasurements"`
+}
+
+// integrityEntry is the payload of a shielded VM integrity log entry.
+type integrityEntry struct {
+	EarlyBootReportEvent *bootReport `json:"earlyBootReportEvent"`
+	LateBootReportEvent  *bootReport `json:"lateBootReportEvent"`
+}
+
+// latestLateBootReport returns the most recent late boot report for the
+// instance which was logged after since.
+func latestLateBootReport(t *testing.T, project, instanceID string, since time.Time) (*bootReport, error) {
+	t.Helper()
+	ctx := utils.Context(t)
+	svc, err := logging.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter := fmt.Sprintf(`resource.type="gce_instance" AND resource.labels.instance_id="%s" AND logName="projects/%s/logs/compute.googleapis.com%%2Fshielded_vm_integrity" AND jsonPayload.lateBootReportEvent:* AND timestamp>="%s"`, instanceID, project, since.UTC().Format(time.RFC3339))
+	resp, err := svc.Entries.List(&logging.ListLogEntriesRequest{
+		ResourceNames: []string{"projects/" + project},
+		Filter:        filter,
+		OrderBy:       "timestamp desc",
+		PageSize:      1,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Entries) == 0 {
+		return nil, nil
+	}
+	var entry integrityEntry
+	if err := json.Unmarshal(resp.Entries[0].JsonPayload, &entry); err != nil {
+		return nil, fmt.Errorf("could not parse integrity log entry: %v", err)
+	}
+	return entry.LateBootReportEvent, nil
+}
+
+// TestShieldedIntegrity validates the integrity monitoring report for the
+// current boot passes the baseline policy.
+func TestShieldedIntegrity(t *testing.T) {
+	ctx := utils.Context(t)
+	project, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: project, Zone: zone, Instance: name})
+	if err != nil {
+		t.Fatalf("could not get instance %s: %v", name, err)
+	}
+	shielded := inst.GetShieldedInstanceConfig()
+	if !shielded.GetEnableVtpm() || !shielded.GetEnableIntegrityMonitoring() {
+		t.Skip("integrity monitoring is not enabled on this instance")
+	}
+	started, err := time.Parse(time.RFC3339, inst.GetLastStartTimestamp())
+	if err != nil {
+		t.Fatalf("could not parse instance start time %q: %v", inst.GetLastStartTimestamp(), err)
+	}
+	instanceID := fmt.Sprint(inst.GetId())
+
+	var report *bootReport
+	for start := time.Now(); time.Since(start) < integrityReportTimeout; time.Sleep(30 * time.Second) {
+		report, err = latestLateBootReport(t, project, instanceID, started)
+		if err != nil {
+			t.Fatalf("could not read integrity log: %v", err)
+		}
+		if report != nil {
+			break
+		}
+	}
+	if report == nil {
+		t.Fatalf("no late boot integrity report was logged within %v of the instance starting", integrityReportTimeout)
+	}
+	if report.PolicyEvaluationPassed {
+		return
+	}
+	policy := make(map[string]string)
+	for _, m := range report.PolicyMeasurements {
+		policy[m.PCRNum] = m.Value
+	}
+	for _, m := range report.ActualMeasurements {
+		if want, ok := policy[m.PCRNum]; ok && want != m.Value {
+			t.Errorf("%s measured %s, baseline is %s", m.PCRNum, m.Value, want)
+		}
+	}
+	t.Error("late boot integrity report failed policy evaluation")
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const defaultUmask = 0022
+
+// imageUmask maps a substring of the image name to the default umask of the
+// image, for images which differ from defaultUmask.
+var imageUmask = map[string]uint64{}
+
+var (
+	loginDefsUmaskRe = regexp.MustCompile(`(?m)^\s*UMASK\s+([0-7]+)`)
+	pamUmaskRe       = regexp.MustCompile(`(?m)^[^#\n]*pam_umask\.so.*?\bumask=([0-7]+)`)
+	procUmaskRe      = regexp.MustCompile(`(?m)^Umask:\s+([0-7]+)`)
+)
+
+func parseUmask(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+}
+
+// umaskFromFile returns the umask in the first submatch of re in path.
+func umaskFromFile(path string, re *regexp.Regexp) (uint64, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	m := re.FindSubmatch(data)
+	if m == nil {
+		return 0, false, nil
+	}
+	umask, err := parseUmask(string(m[1]))
+	return umask, true, err
+}
+
+// shellUmask returns the umask printed by a shell started by cmd from a
+// parent with umask 0000, so that only the shell's own configuration counts.
+func shellUmask(cmd string) (uint64, error) {
+	out, err := exec.Command("sh", "-c", "umask 0000 && "+cmd).Output()
+	if err != nil {
+		return 0, fmt.Errorf("%q failed: %v", cmd, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return parseUmask(lines[len(lines)-1])
+}
+
+// TestUmask validates the default umask of login shells, non-login shells and
+// systemd services matches the image's default.
+func TestUmask(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	expected := uint64(defaultUmask)
+	for substr, umask := range imageUmask {
+		if strings.Contains(image, substr) {
+			expected = umask
+		}
+	}
+	check := func(context string, umask uint64) {
+		t.Logf("%s umask is %04o", context, umask)
+		if umask != expected {
+			t.Errorf("%s umask is %04o, want %04o", context, umask, expected)
+		}
+	}
+
+	if umask, ok, err := umaskFromFile("/etc/login.defs", loginDefsUmaskRe); err == nil && ok {
+		check("login.defs", umask)
+	} else if err != nil && !os.IsNotExist(err) {
+		t.Errorf("could not read login.defs umask: %v", err)
+	}
+
+	pamFiles, err := filepath.Glob("/etc/pam.d/*")
+	if err != nil {
+		t.Fatalf("could not list pam configuration: %v", err)
+	}
+	for _, f := range pamFiles {
+		umask, ok, err := umaskFromFile(f, pamUmaskRe)
+		if err != nil {
+			t.Errorf("could not read pam_umask options from %s: %v", f, err)
+			continue
+		}
+		if ok {
+			check("pam_umask in "+f, umask)
+		}
+	}
+
+	if umask, err := shellUmask("su -l root -s /bin/sh -c umask"); err != nil {
+		t.Errorf("could not get login shell umask: %v", err)
+	} else {
+		check("login shell", umask)
+	}
+
+	// A non-login shell inherits the umask of its parent, here the service
+	// running this test.
+	if out, err := exec.Command("sh", "-c", "umask").Output(); err != nil {
+		t.Errorf("could not get non-login shell umask: %v", err)
+	} else if umask, err := parseUmask(string(out)); err != nil {
+		t.Errorf("could not parse non-login shell umask %q: %v", out, err)
+	} else {
+		check("non-login shell", umask)
+	}
+
+	if umask, ok, err := umaskFromFile("/proc/1/status", procUmaskRe); err != nil {
+		t.Errorf("could not read init umask: %v", err)
+	} else if ok {
+		check("systemd manager", umask)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// systemDirs are the system directories scanned for world writable
+// and setuid entries. Each is scanned only on its own filesystem.
+var systemDirs = []string{"/bin", "/boot", "/etc", "/lib", "/lib64", "/opt", "/sbin", "/usr"}
+
+// worldWritableAllowlist maps a substring of the image name to paths which
+// are known to be world writable on that image. A path allows everything
+// beneath it.
+var worldWritableAllowlist = map[string][]string{}
+
+func isWorldWritableAllowed(image, path string) bool {
+	for substr, paths := range worldWritableAllowlist {
+		if !strings.Contains(image, substr) {
+			continue
+		}
+		for _, p := range paths {
+			if path == p || strings.HasPrefix(path, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// walkSystemPaths calls visit for every entry under the system directories,
+// without following symlinks or crossing into other filesystems.
+func walkSystemPaths(t *testing.T, visit func(path string, info fs.FileInfo)) {
+	t.Helper()
+	for _, root := range systemDirs {
+		rootInfo, err := os.Lstat(root)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("could not stat %s: %v", root, err)
+		}
+		if rootInfo.Mode()&fs.ModeSymlink != 0 {
+			// Merged /usr, scanned under /usr.
+			continue
+		}
+		rootDev := rootInfo.Sys().(*syscall.Stat_t).Dev
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				t.Logf("could not read %s: %v", path, err)
+				return nil
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				t.Logf("could not stat %s: %v", path, err)
+				return nil
+			}
+			if d.IsDir() && info.Sys().(*syscall.Stat_t).Dev != rootDev {
+				// A different filesystem, such as a mounted data disk.
+				return filepath.SkipDir
+			}
+			visit(path, info)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("could not scan %s: %v", root, err)
+		}
+	}
+}
+
+// TestWorldWritable validates that no file or directory under the system
+// directories is world writable without the sticky bit.
+func TestWorldWritable(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("couldn't get image from metadata")
+	}
+	walkSystemPaths(t, func(path string, info fs.FileInfo) {
+		mode := info.Mode()
+		if mode.Perm()&0002 == 0 || mode&fs.ModeSticky != 0 {
+			return
+		}
+		if isWorldWritableAllowed(image, path) {
+			t.Logf("%s is world writable (%s) but allowed on this image", path, mode)
+			return
+		}
+		t.Errorf("%s is world writable without the sticky bit, mode %s", path, mode)
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shapevalidation
+
+import (
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// minimalHeadroomMB is the least memory that must be available once the
+// image has booted on the smallest machine type.
+const minimalHeadroomMB = 128
+
+// linuxCriticalServices and windowsCriticalServices are the services which
+// must be running on the smallest machine type.
+var (
+	linuxCriticalServices   = []string{"google-guest-agent"}
+	windowsCriticalServices = []string{"GCEAgent", "EventLog", "Winmgmt"}
+)
+
+// serviceRunning reports whether the named service is running.
+func serviceRunning(name string) (bool, string) {
+	if utils.IsWindows() {
+		out, err := utils.RunPowershellCmd("(Get-Service " + name + ").Status")
+		if err != nil {
+			return false, strings.TrimSpace(out.Stderr)
+		}
+		status := strings.TrimSpace(out.Stdout)
+		return status == "Running", status
+	}
+	out, _ := exec.Command("systemctl", "is-active", name).Output()
+	status := strings.TrimSpace(string(out))
+	return status == "active", status
+}
+
+// TestMinimalResources validates that the image boots and starts its
+// critical services on the smallest machine type it supports.
+func TestMinimalResources(t *testing.T) {
+	ctx := utils.Context(t)
+	minimal, err := utils.GetMetadata(ctx, "instance", "attributes", "minimal_machine_type")
+	if err != nil {
+		t.Skip("not running on a minimal machine type")
+	}
+	machineType, err := utils.GetMetadata(ctx, "instance", "machine-type")
+	if err != nil {
+		t.Fatalf("could not get machine type from metadata: %v", err)
+	}
+	if path.Base(machineType) != minimal {
+		t.Fatalf("running on %s, want %s", path.Base(machineType), minimal)
+	}
+
+	services := linuxCriticalServices
+	if utils.IsWindows() {
+		services = windowsCriticalServices
+	}
+	for _, service := range services {
+		if running, status := serviceRunning(service); !running {
+			t.Errorf("service %s is %s on %s", service, status, minimal)
+		}
+	}
+	if !utils.IsWindows() {
+		out, _ := exec.Command("systemctl", "list-units", "--failed", "--no-legend", "--plain").Output()
+		if failed := strings.TrimSpace(string(out)); failed != "" {
+			t.Errorf("units failed on %s:\n%s", minimal, failed)
+		}
+		if out, err := exec.Command("journalctl", "-k", "-b", "--grep", "Out of memory").Output(); err == nil && len(out) > 0 {
+			t.Errorf("processes were killed for lack of memory on %s:\n%s", minimal, out)
+		}
+	}
+
+	available, err := memAvailableMB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d MB memory available on %s", available, minimal)
+	if available < minimalHeadroomMB {
+		t.Errorf("only %d MB memory available on %s, want at least %d MB", available, minimal, minimalHeadroomMB)
+	}
+}
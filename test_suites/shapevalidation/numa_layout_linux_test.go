@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shapevalidation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// nodeMemTotalRe matches the total memory line of a NUMA node's meminfo.
+var nodeMemTotalRe = regexp.MustCompile(`MemTotal:\s+(\d+) kB`)
+
+// numaNode is the CPUs and memory of a NUMA node.
+type numaNode struct {
+	id    string
+	cpus  int
+	memKB uint64
+}
+
+// numaLayout returns the CPUs and memory of each online NUMA node.
+func numaLayout() ([]numaNode, error) {
+	dirs, err := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	var nodes []numaNode
+	for _, dir := range dirs {
+		node := numaNode{id: strings.TrimPrefix(filepath.Base(dir), "node")}
+		cpulist, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		if list := strings.TrimSpace(string(cpulist)); list != "" {
+			if node.cpus, err = countKernelList(list); err != nil {
+				return nil, err
+			}
+		}
+		meminfo, err := os.ReadFile(filepath.Join(dir, "meminfo"))
+		if err != nil {
+			return nil, err
+		}
+		m := nodeMemTotalRe.FindSubmatch(meminfo)
+		if m == nil {
+			return nil, fmt.Errorf("no MemTotal in %s/meminfo", dir)
+		}
+		if node.memKB, err = strconv.ParseUint(string(m[1]), 10, 64); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// TestNUMALayout validates that CPUs and memory are spread evenly across the
+// NUMA nodes of a multi node machine type.
+func TestNUMALayout(t *testing.T) {
+	expectedNuma, err := utils.GetMetadata(utils.Context(t), "instance", "attributes", "expected_numa")
+	if err != nil {
+		t.Fatalf("could not get expected numa node count from metadata: %v", err)
+	}
+	enuma, err := strconv.Atoi(expectedNuma)
+	if err != nil {
+		t.Fatalf("could not parse int from %s", expectedNuma)
+	}
+	if enuma < 2 {
+		t.Skipf("machine type has %d numa node", enuma)
+	}
+	if utils.CheckLinuxCmdExists("numactl") {
+		if out, err := exec.Command("numactl", "-H").CombinedOutput(); err == nil {
+			t.Logf("numactl -H:\n%s", out)
+		}
+	}
+	nodes, err := numaLayout()
+	if err != nil {
+		t.Fatalf("could not read numa layout: %v", err)
+	}
+	if len(nodes) != enuma {
+		t.Fatalf("got %d numa nodes, want %d", len(nodes), enuma)
+	}
+
+	var cpus int
+	var memKB uint64
+	for _, node := range nodes {
+		t.Logf("node %s: %d CPUs, %d MB memory", node.id, node.cpus, node.memKB/1024)
+		cpus += node.cpus
+		memKB += node.memKB
+	}
+	// Nodes of GCE machine types are the same size, so allow for memory
+	// reserved by the kernel and firmware but not for a lopsided layout.
+	for _, node := range nodes {
+		if node.cpus == 0 {
+			t.Errorf("node %s has no CPUs", node.id)
+		} else if node.cpus != cpus/len(nodes) {
+			t.Errorf("node %s has %d CPUs, want %d of %d", node.id, node.cpus, cpus/len(nodes), cpus)
+		}
+		if share := memKB / uint64(len(nodes)); node.memKB < share*9/10 {
+			t.Errorf("node %s has %d MB memory, want at least 90%% of an even share of %d MB", node.id, node.memKB/1024, share/1024)
+		}
+	}
+}
@@ -145,8 +145,32 @@ var armshapes = map[string]*shape{
 	},
 }
 
+// minimalMachineTypes are the smallest machine types images are expected to
+// run on, by architecture.
+var minimalMachineTypes = map[string]string{
+	"X86_64": "e2-micro",
+	"ARM64":  "t2a-standard-1",
+}
+
+// minimalWindowsMachineType is the smallest machine type windows images are
+// expected to run on.
+const minimalWindowsMachineType = "e2-medium"
+
 // TestSetup sets up the test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
+	minimal, ok := minimalMachineTypes[t.Image.Architecture]
+	if utils.HasFeature(t.Image, "WINDOWS") {
+		minimal, ok = minimalWindowsMachineType, true
+	}
+	if ok {
+		vm, err := t.CreateTestVM("minimal")
+		if err != nil {
+			return err
+		}
+		vm.ForceMachineType(minimal)
+		vm.AddMetadata("minimal_machine_type", minimal)
+		vm.RunTests("TestMinimalResources")
+	}
 	if t.Image.Architecture == "ARM64" {
 		return testFamily(t, armshapes)
 	}
@@ -197,7 +221,7 @@ Familyloop:
 		vm.AddMetadata("expected_memory", fmt.Sprintf("%d", shape.mem))
 		vm.AddMetadata("expected_cpu", fmt.Sprintf("%d", shape.cpu))
 		vm.AddMetadata("expected_numa", fmt.Sprintf("%d", shape.numa))
-		vm.RunTests("(TestCpu)|(TestMem)|(TestNuma)")
+		vm.RunTests("(TestCpu)|(TestMem)|(TestNuma)|(TestNUMALayout)")
 	}
 	return nil
 }
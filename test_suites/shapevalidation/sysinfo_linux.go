@@ -35,6 +35,21 @@ func memTotal() (uint64, error) {
 	return (info.Totalram / 1_000_000_000), nil
 }
 
+// memAvailableMB returns the memory available for new allocations in MB.
+func memAvailableMB() (uint64, error) {
+	meminfo, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(meminfo), "\n") {
+		if value, ok := strings.CutPrefix(line, "MemAvailable:"); ok {
+			kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			return kb * 1024 / 1_000_000, err
+		}
+	}
+	return 0, fmt.Errorf("no MemAvailable in /proc/meminfo")
+}
+
 func numCpus() (int, error) {
 	cpus, err := os.ReadFile("/sys/devices/system/cpu/online")
 	if err != nil {
@@ -86,6 +86,23 @@ func memTotal() (uint64, error) {
 	return (msx.ullTotalPhys / 1_000_000_000), nil
 }
 
+// memAvailableMB returns the memory available for new allocations in MB.
+func memAvailableMB() (uint64, error) {
+	var msx memoryStatusEx
+	msx.dwLength = uint32(unsafe.Sizeof(msx))
+
+	globalMemoryStatusEx, err := k32Proc("GlobalMemoryStatusEx")
+	if err != nil {
+		return 0, err
+	}
+
+	r, _, err := globalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&msx)))
+	if r == 0 {
+		return 0, err
+	}
+	return (msx.ullAvailPhys / 1_000_000), nil
+}
+
 func numCpus() (int, error) {
 	getActiveProcessorCount, err := k32Proc("GetActiveProcessorCount")
 	if err != nil {
@@ -0,0 +1,168 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// The time between the preemption notice and the instance being stopped.
+	// https://cloud.google.com/compute/docs/instances/spot#preemption-process
+	preemptionGracePeriod = 30 * time.Second
+	// Extra time allowed for the shutdown script to start after the grace
+	// period, to account for the delay in observing the notice.
+	preemptionGraceSlack = 15 * time.Second
+	// The shutdown script may start before the test records the notice, as
+	// both are triggered at the same time and are recorded with one second
+	// resolution.
+	preemptionNoticeSkew = 5 * time.Second
+)
+
+func preemptionMarkers() (trigger, notice, shutdown string) {
+	if utils.IsWindows() {
+		return `C:\preemption-trigger`, `C:\preemption-notice`, `C:\preemption-shutdown`
+	}
+	return "/var/preemption-trigger", "/var/preemption-notice", "/var/preemption-shutdown"
+}
+
+func skipIfNotPreemptible(ctx context.Context, t *testing.T) {
+	t.Helper()
+	preemptible, err := utils.GetMetadata(ctx, "instance", "scheduling", "preemptible")
+	if err != nil {
+		t.Fatalf("could not get instance scheduling from metadata: %v", err)
+	}
+	if preemptible != "TRUE" {
+		t.Skipf("instance is not preemptible, instance/scheduling/preemptible is %q", preemptible)
+	}
+}
+
+// TestPreemptionNotice validates that the guest observes a preemption through
+// the metadata server and that shutdown scripts run within the grace period.
+func TestPreemptionNotice(t *testing.T) {
+	ctx := utils.Context(t)
+	skipIfNotPreemptible(ctx, t)
+	triggerMarker, noticeMarker, shutdownMarker := preemptionMarkers()
+
+	if _, err := os.Stat(triggerMarker); os.IsNotExist(err) {
+		// first boot
+		if err := os.WriteFile(triggerMarker, []byte(strconv.FormatInt(time.Now().Unix(), 10)), 0644); err != nil {
+			t.Fatalf("could not write preemption trigger marker: %v", err)
+		}
+		if err := triggerPreemption(ctx, t, noticeMarker); err != nil {
+			t.Fatal(err)
+		}
+		return
+	} else if err != nil {
+		t.Fatalf("could not stat preemption trigger marker: %v", err)
+	}
+
+	// second boot
+	notice, err := os.ReadFile(noticeMarker)
+	if err != nil {
+		t.Fatalf("preemption was triggered on the previous boot but the notice was not recorded before shutdown: %v", err)
+	}
+	shutdown, err := os.ReadFile(shutdownMarker)
+	if err != nil {
+		t.Fatalf("shutdown script did not run during preemption: %v", err)
+	}
+	noticeTime, err := strconv.ParseInt(strings.TrimSpace(string(notice)), 10, 64)
+	if err != nil {
+		t.Fatalf("could not parse preemption notice time %q: %v", notice, err)
+	}
+	fields := strings.Fields(string(shutdown))
+	if len(fields) != 2 {
+		t.Fatalf("unexpected shutdown script marker contents %q", shutdown)
+	}
+	shutdownTime, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		t.Fatalf("could not parse shutdown script time %q: %v", fields[0], err)
+	}
+	if fields[1] != "TRUE" {
+		t.Errorf("shutdown script saw instance/preempted %q, want TRUE", fields[1])
+	}
+	elapsed := time.Duration(shutdownTime-noticeTime) * time.Second
+	t.Logf("shutdown script ran %v after the preemption notice was observed", elapsed)
+	if elapsed < -preemptionNoticeSkew || elapsed > preemptionGracePeriod+preemptionGraceSlack {
+		t.Errorf("shutdown script ran %v after the preemption notice, want between %v and %v", elapsed, -preemptionNoticeSkew, preemptionGracePeriod+preemptionGraceSlack)
+	}
+}
+
+// triggerPreemption simulates a maintenance event, which preempts Spot
+// instances, and records when the guest observed instance/preempted change.
+func triggerPreemption(ctx context.Context, t *testing.T, noticeMarker string) error {
+	t.Helper()
+	// Record the ETag before triggering the preemption so a notice delivered
+	// before the wait starts is not missed.
+	preempted, headers, err := utils.GetMetadataWithHeaders(ctx, "instance", "preempted")
+	if err != nil {
+		return fmt.Errorf("could not get instance/preempted: %v", err)
+	}
+	if preempted != "FALSE" {
+		return fmt.Errorf("instance/preempted is %q before preemption, want FALSE", preempted)
+	}
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		return fmt.Errorf("could not find project and zone: %v", err)
+	}
+	inst, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get instance: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+	// Don't wait on the operation, the instance is stopped shortly after the
+	// notice is delivered.
+	if _, err := client.SimulateMaintenanceEvent(ctx, &computepb.SimulateMaintenanceEventInstanceRequest{
+		Project:  prj,
+		Zone:     zone,
+		Instance: inst,
+	}); err != nil {
+		return fmt.Errorf("could not simulate preemption: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	start := time.Now()
+	etag := headers.Get("ETag")
+	for preempted != "TRUE" {
+		value, newETag, err := utils.GetMetadataWaitForChangeETag(waitCtx, etag, 60, "instance", "preempted")
+		if waitCtx.Err() != nil {
+			return fmt.Errorf("instance/preempted never changed to TRUE after simulating preemption: %v", waitCtx.Err())
+		}
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		preempted, etag = value, newETag
+	}
+	if err := os.WriteFile(noticeMarker, []byte(strconv.FormatInt(time.Now().Unix(), 10)), 0644); err != nil {
+		return fmt.Errorf("could not write preemption notice marker: %v", err)
+	}
+	t.Logf("observed preemption notice after %v", time.Since(start))
+	return nil
+}
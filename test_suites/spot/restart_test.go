@@ -0,0 +1,180 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// spotRestartState is what the guest records before preemption to compare
+// against after the restart.
+type spotRestartState struct {
+	Disks []string `json:"disks"`
+	IP    string   `json:"ip"`
+}
+
+func spotRestartMarkers() (notice, state string) {
+	if utils.IsWindows() {
+		return `C:\spot-restart-notice`, `C:\spot-restart-state`
+	}
+	return "/var/spot-restart-notice", "/var/spot-restart-state"
+}
+
+// TestSpotRestart validates that a Spot instance comes back with its disks and
+// network after being preempted and restarted.
+func TestSpotRestart(t *testing.T) {
+	ctx := utils.Context(t)
+	skipIfNotPreemptible(ctx, t)
+	noticeMarker, stateMarker := spotRestartMarkers()
+	guard := utils.DefaultRebootGuard()
+	state, err := guard.State(t.Name())
+	if err != nil {
+		t.Fatalf("could not get reboot state: %v", err)
+	}
+
+	switch state {
+	case utils.RebootNone:
+		// first boot
+		current, err := getSpotRestartState(ctx)
+		if err != nil {
+			t.Fatalf("before preemption: %v", err)
+		}
+		data, err := json.Marshal(current)
+		if err != nil {
+			t.Fatalf("before preemption: could not marshal instance state: %v", err)
+		}
+		if err := os.WriteFile(stateMarker, data, 0644); err != nil {
+			t.Fatalf("before preemption: could not write instance state: %v", err)
+		}
+		if err := guard.Begin(t.Name()); err != nil {
+			t.Fatalf("before preemption: %v", err)
+		}
+		if err := triggerPreemption(ctx, t, noticeMarker); err != nil {
+			t.Fatalf("during preemption: %v", err)
+		}
+		return
+	case utils.RebootPending:
+		t.Fatal("during preemption: instance was not restarted after preemption")
+	}
+
+	// second boot
+	t.Cleanup(func() { guard.Release(t.Name()) })
+	if _, err := os.Stat(noticeMarker); err != nil {
+		t.Errorf("during preemption: preemption notice was not observed before shutdown: %v", err)
+	}
+	if err := checkInstanceRunning(ctx); err != nil {
+		t.Fatalf("after restart: %v", err)
+	}
+	data, err := os.ReadFile(stateMarker)
+	if err != nil {
+		t.Fatalf("after restart: could not read instance state from before preemption: %v", err)
+	}
+	var before spotRestartState
+	if err := json.Unmarshal(data, &before); err != nil {
+		t.Fatalf("after restart: could not parse instance state from before preemption: %v", err)
+	}
+	after, err := getSpotRestartState(ctx)
+	if err != nil {
+		t.Fatalf("after restart: %v", err)
+	}
+	if fmt.Sprint(before.Disks) != fmt.Sprint(after.Disks) {
+		t.Errorf("after restart: disks are %v, want %v", after.Disks, before.Disks)
+	}
+	if before.IP != after.IP {
+		t.Errorf("after restart: primary IP is %s, want %s", after.IP, before.IP)
+	}
+	if _, err := net.LookupHost("metadata.google.internal"); err != nil {
+		t.Errorf("after restart: could not resolve metadata server: %v", err)
+	}
+}
+
+// getSpotRestartState returns the disks and primary IP of the instance as seen
+// by the guest.
+func getSpotRestartState(ctx context.Context) (spotRestartState, error) {
+	var state spotRestartState
+	disks, err := utils.GetMetadata(ctx, "instance", "disks")
+	if err != nil {
+		return state, fmt.Errorf("could not get disks from metadata: %v", err)
+	}
+	for _, index := range strings.Fields(disks) {
+		name, err := utils.GetMetadata(ctx, "instance", "disks", strings.TrimSuffix(index, "/"), "device-name")
+		if err != nil {
+			return state, fmt.Errorf("could not get name of disk %s from metadata: %v", index, err)
+		}
+		state.Disks = append(state.Disks, name)
+	}
+	sort.Strings(state.Disks)
+	ip, err := utils.GetMetadata(ctx, "instance", "network-interfaces", "0", "ip")
+	if err != nil {
+		return state, fmt.Errorf("could not get primary IP from metadata: %v", err)
+	}
+	iface, err := utils.GetInterface(ctx, 0)
+	if err != nil {
+		return state, fmt.Errorf("could not find primary interface: %v", err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return state, fmt.Errorf("could not get addresses of %s: %v", iface.Name, err)
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.String() == ip {
+			state.IP = ip
+		}
+	}
+	if state.IP == "" {
+		return state, fmt.Errorf("primary IP %s from metadata is not configured on %s", ip, iface.Name)
+	}
+	return state, nil
+}
+
+// checkInstanceRunning validates that the compute API reports the instance as
+// a running Spot instance.
+func checkInstanceRunning(ctx context.Context) error {
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		return fmt.Errorf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get instance: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+	inst, err := client.Get(ctx, &computepb.GetInstanceRequest{Project: prj, Zone: zone, Instance: name})
+	if err != nil {
+		return fmt.Errorf("could not get instance %s: %v", name, err)
+	}
+	if inst.GetStatus() != "RUNNING" {
+		return fmt.Errorf("instance %s has status %s, want RUNNING", name, inst.GetStatus())
+	}
+	if inst.GetScheduling().GetProvisioningModel() != "SPOT" {
+		return fmt.Errorf("instance %s has provisioning model %s, want SPOT", name, inst.GetScheduling().GetProvisioningModel())
+	}
+	return nil
+}
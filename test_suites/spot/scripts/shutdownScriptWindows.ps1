@@ -0,0 +1,19 @@
+# Copyright 2024 Google LLC.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Records the time the shutdown script ran and whether the instance observed
+# a preemption, for TestPreemptionNotice to validate on the next boot.
+$preempted = Invoke-RestMethod -Headers @{'Metadata-Flavor' = 'Google'} -Uri 'http://metadata.google.internal/computeMetadata/v1/instance/preempted' -UseBasicParsing
+$now = [DateTimeOffset]::UtcNow.ToUnixTimeSeconds()
+Set-Content -Path 'C:\preemption-shutdown' -Value "$now $preempted" -NoNewline
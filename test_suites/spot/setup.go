@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spot is a CIT suite for testing the guest behavior of Spot and
+// preemptible instances.
+package spot
+
+import (
+	"embed"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	daisy "github.com/GoogleCloudPlatform/compute-daisy"
+	"google.golang.org/api/compute/v1"
+)
+
+// Name is the name of the test package. It must match the directory name.
+var Name = "spot"
+
+const (
+	shutdownScriptLinuxURL   = "scripts/shutdownScriptLinux.sh"
+	shutdownScriptWindowsURL = "scripts/shutdownScriptWindows.ps1"
+)
+
+//go:embed scripts/*
+var scripts embed.FS
+
+// TestSetup sets up the test workflow.
+func TestSetup(t *imagetest.TestWorkflow) error {
+	preemptInst := &daisy.Instance{}
+	preemptInst.Scopes = append(preemptInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+	preemptInst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
+	preemptInst.Scheduling = spotScheduling()
+	preemptvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "preemption"}}, preemptInst)
+	if err != nil {
+		return err
+	}
+	if utils.HasFeature(t.Image, "WINDOWS") {
+		script, err := scripts.ReadFile(shutdownScriptWindowsURL)
+		if err != nil {
+			return err
+		}
+		preemptvm.SetWindowsShutdownScript(string(script))
+	} else {
+		script, err := scripts.ReadFile(shutdownScriptLinuxURL)
+		if err != nil {
+			return err
+		}
+		preemptvm.SetShutdownScript(string(script))
+	}
+	// The test preempts the instance on the first boot, the reboot brings it
+	// back up to validate what happened during preemption.
+	if err := preemptvm.Reboot(); err != nil {
+		return err
+	}
+	preemptvm.RunTests("TestPreemptionNotice")
+
+	restartInst := &daisy.Instance{}
+	restartInst.Scopes = append(restartInst.Scopes, "https://www.googleapis.com/auth/cloud-platform")
+	restartInst.Metadata = map[string]string{imagetest.ShouldRebootDuringTest: "true"}
+	restartInst.Scheduling = spotScheduling()
+	restartvm, err := t.CreateTestVMMultipleDisks([]*compute.Disk{{Name: "spotrestart"}, {Name: "spotdata", Type: imagetest.PdBalanced, SizeGb: 10}}, restartInst)
+	if err != nil {
+		return err
+	}
+	// The test preempts the instance on the first boot, the workflow restarts
+	// it once it has stopped.
+	if err := restartvm.Reboot(); err != nil {
+		return err
+	}
+	restartvm.RunTests("TestSpotRestart")
+	return nil
+}
+
+func spotScheduling() *compute.Scheduling {
+	automaticRestart := false
+	return &compute.Scheduling{
+		ProvisioningModel:         "SPOT",
+		InstanceTerminationAction: "STOP",
+		OnHostMaintenance:         "TERMINATE",
+		AutomaticRestart:          &automaticRestart,
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+// caLoginPort is the port of the sshd started to test certificate logins.
+const caLoginPort = 2222
+
+// TestSSHCATrust validates that sshd trusts the configured user CA and accepts
+// certificate based logins for a regular user.
+func TestSSHCATrust(t *testing.T) {
+	utils.LinuxOnly(t)
+	// Evaluate the config for an incoming user connection so that Match blocks
+	// which could disable certificate logins are applied.
+	config, err := sshdEffectiveConfig(fmt.Sprintf("user=%s,host=localhost,addr=127.0.0.1", user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := firstValue(config, "trustedusercakeys")
+	if caFile == "" || caFile == "none" {
+		t.Skip("sshd has no TrustedUserCAKeys configured")
+	}
+	principalsCmd := firstValue(config, "authorizedprincipalscommand")
+	t.Logf("sshd trusted CA configuration: TrustedUserCAKeys %s, AuthorizedPrincipalsCommand %q, AuthorizedPrincipalsCommandUser %q, CASignatureAlgorithms %q",
+		caFile, principalsCmd, firstValue(config, "authorizedprincipalscommanduser"), firstValue(config, "casignaturealgorithms"))
+
+	if pubkey := firstValue(config, "pubkeyauthentication"); pubkey != "yes" {
+		t.Errorf("PubkeyAuthentication is %q, want yes for certificate logins", pubkey)
+	}
+	cas, err := readCAKeys(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cas) == 0 {
+		t.Fatalf("TrustedUserCAKeys file %s contains no keys", caFile)
+	}
+	accepted := strings.Split(firstValue(config, "casignaturealgorithms"), ",")
+	for _, ca := range cas {
+		t.Logf("trusted CA key %s %s", ca.Type(), ssh.FingerprintSHA256(ca))
+		if !caAlgorithmAccepted(ca.Type(), accepted) {
+			t.Errorf("CA key type %s is not accepted by CASignatureAlgorithms %v", ca.Type(), accepted)
+		}
+	}
+	if principalsCmd != "" && principalsCmd != "none" {
+		bin := strings.Fields(principalsCmd)[0]
+		info, err := os.Stat(bin)
+		if err != nil {
+			t.Errorf("AuthorizedPrincipalsCommand %s not found: %v", bin, err)
+		} else if info.Mode().Perm()&0111 == 0 {
+			t.Errorf("AuthorizedPrincipalsCommand %s is not executable, mode %v", bin, info.Mode())
+		}
+	}
+	testCertificateLogin(t)
+}
+
+// testCertificateLogin signs a user key with a new CA and logs in with it to
+// an sshd which only trusts that CA, then checks that an unsigned key is
+// rejected.
+func testCertificateLogin(t *testing.T) {
+	t.Helper()
+	ctx := utils.Context(t)
+	dir := t.TempDir()
+	for _, key := range []string{"hostkey", "ca", "signed", "unsigned"} {
+		if out, err := exec.CommandContext(ctx, "ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", filepath.Join(dir, key)).CombinedOutput(); err != nil {
+			t.Fatalf("could not generate %s key: %v %s", key, err, out)
+		}
+	}
+	out, err := exec.CommandContext(ctx, "whoami").Output()
+	if err != nil {
+		t.Fatalf("could not get the current user: %v", err)
+	}
+	principal := strings.TrimSpace(string(out))
+	// Writes signed-cert.pub, which ssh picks up alongside the signed key.
+	if out, err := exec.CommandContext(ctx, "ssh-keygen", "-q", "-s", filepath.Join(dir, "ca"), "-I", "cit-ca-trust", "-n", principal, "-V", "+1h", filepath.Join(dir, "signed.pub")).CombinedOutput(); err != nil {
+		t.Fatalf("could not sign user key: %v %s", err, out)
+	}
+
+	config := strings.Join([]string{
+		fmt.Sprintf("Port %d", caLoginPort),
+		"ListenAddress 127.0.0.1",
+		"HostKey " + filepath.Join(dir, "hostkey"),
+		"PidFile " + filepath.Join(dir, "sshd.pid"),
+		"TrustedUserCAKeys " + filepath.Join(dir, "ca.pub"),
+		"AuthorizedKeysFile none",
+		"PubkeyAuthentication yes",
+		"PasswordAuthentication no",
+		"PermitRootLogin prohibit-password",
+		"StrictModes no",
+	}, "\n") + "\n"
+	configFile := filepath.Join(dir, "sshd_config")
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("could not write sshd config: %v", err)
+	}
+	// sshd must be started with an absolute path to re-exec itself.
+	sshd, err := exec.LookPath("sshd")
+	if err != nil {
+		t.Fatalf("could not find sshd: %v", err)
+	}
+	if !filepath.IsAbs(sshd) {
+		if sshd, err = filepath.Abs(sshd); err != nil {
+			t.Fatalf("could not get absolute path of sshd: %v", err)
+		}
+	}
+	var sshdLog strings.Builder
+	cmd := exec.CommandContext(ctx, sshd, "-D", "-e", "-f", configFile)
+	cmd.Stdout = &sshdLog
+	cmd.Stderr = &sshdLog
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("could not start sshd: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Logf("test sshd output:\n%s", sshdLog.String())
+	}()
+	addr := fmt.Sprintf("127.0.0.1:%d", caLoginPort)
+	for start := time.Now(); ; time.Sleep(time.Second) {
+		c, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			c.Close()
+			break
+		}
+		if time.Since(start) > 30*time.Second {
+			t.Fatalf("test sshd did not start listening on %s: %v", addr, err)
+		}
+	}
+
+	login := func(key string) ([]byte, error) {
+		return exec.CommandContext(ctx, "ssh", "-i", filepath.Join(dir, key), "-p", fmt.Sprint(caLoginPort),
+			"-o", "IdentitiesOnly=yes", "-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null",
+			principal+"@127.0.0.1", "true").CombinedOutput()
+	}
+	if out, err := login("signed"); err != nil {
+		t.Errorf("login with a certificate signed by the trusted CA failed: %v %s", err, out)
+	}
+	if _, err := login("unsigned"); err == nil {
+		t.Errorf("login with an unsigned key succeeded, want it rejected")
+	}
+}
+
+func firstValue(config map[string][]string, key string) string {
+	if v := config[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func readCAKeys(file string) ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read TrustedUserCAKeys file: %v", err)
+	}
+	var keys []ssh.PublicKey
+	for len(strings.TrimSpace(string(data))) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse TrustedUserCAKeys file %s: %v", file, err)
+		}
+		keys = append(keys, key)
+		data = rest
+	}
+	return keys, nil
+}
+
+// caAlgorithmAccepted reports whether a CA key of keyType can sign
+// certificates with one of the accepted signature algorithms.
+func caAlgorithmAccepted(keyType string, accepted []string) bool {
+	for _, alg := range accepted {
+		if alg == keyType || (keyType == ssh.KeyAlgoRSA && (alg == ssh.KeyAlgoRSASHA256 || alg == ssh.KeyAlgoRSASHA512)) {
+			return true
+		}
+	}
+	return false
+}
@@ -50,12 +50,20 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	vm2.AddMetadata("enable-oslogin", "false")
 	vm2.AddMetadata("enable-windows-ssh", "true")
 	vm2.AddMetadata("sysprep-specialize-script-cmd", "googet -noconfirm=true install google-compute-engine-ssh")
-	vm2.RunTests("TestEmptyTest")
+	vm2.RunTests("TestEmptyTest|TestSSHDCrypto")
 
 	vm3, err := t.CreateTestVM("hostkeysafteragentrestart")
 	if err != nil {
 		return err
 	}
 	vm3.RunTests("TestHostKeysNotOverrideAfterAgentRestart")
+
+	vm4, err := t.CreateTestVM("catrust")
+	if err != nil {
+		return err
+	}
+	vm4.AddMetadata("enable-oslogin", "true")
+	vm4.AddMetadata("enable-oslogin-certificates", "true")
+	vm4.RunTests("TestSSHCATrust")
 	return nil
 }
@@ -0,0 +1,46 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// sshdEffectiveConfig returns the effective sshd configuration as reported by
+// sshd -T, keyed by lowercase option name. Options which may be specified
+// more than once have one value per occurrence. If connSpec is not empty it is
+// passed to sshd -C so that Match blocks are applied for that connection.
+func sshdEffectiveConfig(connSpec string) (map[string][]string, error) {
+	args := []string{"-T"}
+	if connSpec != "" {
+		args = append(args, "-C", connSpec)
+	}
+	out, err := exec.Command("sshd", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("sshd %s failed: %v %s", strings.Join(args, " "), err, out)
+	}
+	config := make(map[string][]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(key)
+		config[key] = append(config[key], value)
+	}
+	return config, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// weakSSHDAlgorithms are the algorithms which must not be enabled, keyed by
+// the sshd -T option which lists them.
+var weakSSHDAlgorithms = map[string][]string{
+	"ciphers": {
+		"3des-cbc", "aes128-cbc", "aes192-cbc", "aes256-cbc", "blowfish-cbc",
+		"cast128-cbc", "arcfour", "arcfour128", "arcfour256",
+		"rijndael-cbc@lysator.liu.se",
+	},
+	"macs": {
+		"hmac-md5", "hmac-md5-96", "hmac-md5-etm@openssh.com",
+		"hmac-md5-96-etm@openssh.com", "hmac-sha1-96",
+		"hmac-sha1-96-etm@openssh.com", "hmac-ripemd160",
+		"hmac-ripemd160@openssh.com", "hmac-ripemd160-etm@openssh.com",
+	},
+	"kexalgorithms": {
+		"diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1",
+		"diffie-hellman-group-exchange-sha1",
+	},
+}
+
+// sshdAlgorithmExceptions are weak algorithms which images matching the
+// expression are allowed to enable for compatibility.
+var sshdAlgorithmExceptions = []struct {
+	image   *regexp.Regexp
+	allowed []string
+}{
+	// OpenSSH 7.4 on EL7 still offers group14-sha1 as its only finite field
+	// group by default.
+	{regexp.MustCompile(`^(centos|rhel)-7`), []string{"diffie-hellman-group14-sha1"}},
+	{regexp.MustCompile(`^sles-12`), []string{"diffie-hellman-group14-sha1"}},
+}
+
+// TestSSHDCrypto validates that sshd does not enable weak ciphers, MACs or key
+// exchange algorithms.
+func TestSSHDCrypto(t *testing.T) {
+	utils.LinuxOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "image")
+	if err != nil {
+		t.Fatalf("could not get image from metadata: %v", err)
+	}
+	image = path.Base(image)
+	allowed := make(map[string]bool)
+	for _, exception := range sshdAlgorithmExceptions {
+		if exception.image.MatchString(image) {
+			for _, algorithm := range exception.allowed {
+				allowed[algorithm] = true
+			}
+		}
+	}
+	config, err := sshdEffectiveConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for option, weak := range weakSSHDAlgorithms {
+		enabled := strings.Split(firstValue(config, option), ",")
+		t.Logf("sshd %s: %v", option, enabled)
+		for _, algorithm := range enabled {
+			for _, w := range weak {
+				if algorithm == w && !allowed[algorithm] {
+					t.Errorf("sshd %s enables weak algorithm %s", option, algorithm)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// clockJump is how far the clock is stepped backward.
+	clockJump = 2 * time.Second
+	// clockRecoveryTimeout is how long the time daemon has to correct the jump.
+	clockRecoveryTimeout = 3 * time.Minute
+	// clockSyncedOffset is the offset below which the clock counts as synced.
+	clockSyncedOffset = 100 * time.Millisecond
+)
+
+// timeDaemons are the time sync services used by supported images.
+var timeDaemons = []string{"chronyd", "chrony", "systemd-timesyncd", "ntpd", "ntp"}
+
+// timerServices are services which run scheduled jobs and must survive the
+// clock moving backward.
+var timerServices = []string{"cron", "crond"}
+
+func activeService(names []string) string {
+	for _, name := range names {
+		if exec.Command("systemctl", "is-active", "--quiet", name).Run() == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+func mainPID(service string) string {
+	out, _ := exec.Command("systemctl", "show", "--property=MainPID", "--value", service).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// stepClock moves the system clock by d.
+func stepClock(d time.Duration) error {
+	target := time.Now().Add(d)
+	out, err := exec.Command("date", "-s", fmt.Sprintf("@%d.%09d", target.Unix(), target.Nanosecond())).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("date -s failed: %v %s", err, out)
+	}
+	return nil
+}
+
+// TestClockJump validates that the time daemon corrects the clock after it
+// is stepped backward, and that services keep working.
+func TestClockJump(t *testing.T) {
+	utils.LinuxOnly(t)
+	daemon := activeService(timeDaemons)
+	if daemon == "" {
+		t.Skip("no supported time sync daemon is running")
+	}
+	offset, err := clockOffset(metadataNTPServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset.Abs() > clockSyncedOffset {
+		t.Fatalf("clock is not synced before the test, offset is %v", offset)
+	}
+	pids := map[string]string{daemon: mainPID(daemon)}
+	if timer := activeService(timerServices); timer != "" {
+		pids[timer] = mainPID(timer)
+	}
+	t.Logf("time daemon is %s, initial offset is %v, service pids: %v", daemon, offset, pids)
+
+	if err := stepClock(-clockJump); err != nil {
+		t.Skipf("stepping the clock is not permitted: %v", err)
+	}
+	t.Cleanup(func() {
+		// Put the clock back if the daemon didn't.
+		if offset, err := clockOffset(metadataNTPServer); err == nil && offset.Abs() > clockSyncedOffset {
+			if err := stepClock(-offset); err != nil {
+				t.Logf("could not restore clock: %v", err)
+			}
+		}
+	})
+
+	var trajectory []string
+	recovered := false
+	for start := time.Now(); time.Since(start) < clockRecoveryTimeout; time.Sleep(5 * time.Second) {
+		offset, err := clockOffset(metadataNTPServer)
+		if err != nil {
+			t.Logf("could not measure offset: %v", err)
+			continue
+		}
+		trajectory = append(trajectory, fmt.Sprintf("%v: %v", time.Since(start).Round(time.Second), offset.Round(time.Millisecond)))
+		if offset.Abs() <= clockSyncedOffset {
+			recovered = true
+			break
+		}
+	}
+	t.Logf("offset after stepping the clock back %v: %s", clockJump, strings.Join(trajectory, ", "))
+	if !recovered {
+		t.Errorf("%s did not correct the clock within %v", daemon, clockRecoveryTimeout)
+	}
+
+	for service, pid := range pids {
+		if exec.Command("systemctl", "is-active", "--quiet", service).Run() != nil {
+			t.Errorf("service %s is no longer active after the clock jump", service)
+		} else if after := mainPID(service); after != pid {
+			t.Errorf("service %s restarted after the clock jump, main pid was %s and is now %s", service, pid, after)
+		}
+	}
+	ctx, cancel := context.WithTimeout(utils.Context(t), 30*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, "systemctl", "list-timers", "--all", "--no-pager").CombinedOutput(); err != nil {
+		t.Errorf("systemctl list-timers failed after the clock jump: %v %s", err, out)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.googleapis.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Errorf("TLS connection failed after the clock jump: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
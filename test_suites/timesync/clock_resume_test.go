@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// clockResumeMarker is written before the migration so an unexpected reboot
+// in place of a live migration can be detected.
+const clockResumeMarker = "/var/cit-clock-resume"
+
+// TestClockOnResume validates that the time daemon brings the clock back in
+// sync promptly after the instance is live migrated.
+func TestClockOnResume(t *testing.T) {
+	utils.LinuxOnly(t)
+	ctx := utils.Context(t)
+	if _, err := os.Stat(clockResumeMarker); err == nil {
+		t.Fatal("instance rebooted instead of live migrating")
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("could not check for %s: %v", clockResumeMarker, err)
+	}
+	onHostMaintenance, err := utils.GetMetadata(ctx, "instance", "scheduling", "on-host-maintenance")
+	if err != nil {
+		t.Fatalf("could not get on-host-maintenance policy: %v", err)
+	}
+	if onHostMaintenance != "MIGRATE" {
+		t.Skipf("instance does not live migrate, on-host-maintenance is %s", onHostMaintenance)
+	}
+	daemon := activeService(timeDaemons)
+	if daemon == "" {
+		t.Skip("no supported time sync daemon is running")
+	}
+	offset, err := clockOffset(metadataNTPServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset.Abs() > clockSyncedOffset {
+		t.Fatalf("clock is not synced before the test, offset is %v", offset)
+	}
+	t.Logf("time daemon is %s, offset before migration is %v", daemon, offset.Round(time.Millisecond))
+
+	prj, zone, err := utils.GetProjectZone(ctx)
+	if err != nil {
+		t.Fatalf("could not find project and zone: %v", err)
+	}
+	name, err := utils.GetInstanceName(ctx)
+	if err != nil {
+		t.Fatalf("could not get instance name: %v", err)
+	}
+	client, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		t.Fatalf("could not make compute api client: %v", err)
+	}
+	defer client.Close()
+
+	if err := os.WriteFile(clockResumeMarker, []byte(time.Now().Format(time.RFC3339Nano)), 0644); err != nil {
+		t.Fatalf("could not write %s: %v", clockResumeMarker, err)
+	}
+	t.Cleanup(func() { os.Remove(clockResumeMarker) })
+	op, err := client.SimulateMaintenanceEvent(ctx, &computepb.SimulateMaintenanceEventInstanceRequest{Project: prj, Zone: zone, Instance: name})
+	if err != nil {
+		t.Fatalf("could not simulate maintenance event: %v", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		t.Skipf("live migration did not complete: %v", err)
+	}
+
+	var trajectory []string
+	recovered := false
+	for start := time.Now(); time.Since(start) < clockRecoveryTimeout; time.Sleep(5 * time.Second) {
+		offset, err := clockOffset(metadataNTPServer)
+		if err != nil {
+			t.Logf("could not measure offset: %v", err)
+			continue
+		}
+		trajectory = append(trajectory, fmt.Sprintf("%v: %v", time.Since(start).Round(time.Second), offset.Round(time.Millisecond)))
+		if offset.Abs() <= clockSyncedOffset {
+			recovered = true
+			break
+		}
+	}
+	t.Logf("offset after live migration: %s", strings.Join(trajectory, ", "))
+	if !recovered {
+		t.Errorf("%s did not correct the clock within %v of the migration", daemon, clockRecoveryTimeout)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesync
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// clockSourceDir is where the kernel reports its clocksources.
+const clockSourceDir = "/sys/devices/system/clocksource/clocksource0"
+
+// expectedClockSources are the clocksources the kernel may select on GCE, by
+// guest architecture. Newer x86 kernels prefer the TSC when the hypervisor
+// reports it invariant.
+var expectedClockSources = map[string][]string{
+	utils.ArchX86_64: {"kvm-clock", "tsc"},
+	utils.ArchARM64:  {"arch_sys_counter"},
+}
+
+// unstableClockSourceRe matches the kernel marking a clocksource unstable.
+var unstableClockSourceRe = regexp.MustCompile(`(?m)^.*clocksource.*unstable.*$`)
+
+// TestClockSource validates that the kernel selected a stable clocksource
+// expected for the platform.
+func TestClockSource(t *testing.T) {
+	utils.LinuxOnly(t)
+	arch, err := utils.GuestArchitecture()
+	if err != nil {
+		t.Fatalf("could not determine guest architecture: %v", err)
+	}
+	current, err := os.ReadFile(clockSourceDir + "/current_clocksource")
+	if err != nil {
+		t.Fatalf("could not read current clocksource: %v", err)
+	}
+	available, err := os.ReadFile(clockSourceDir + "/available_clocksource")
+	if err != nil {
+		t.Fatalf("could not read available clocksources: %v", err)
+	}
+	source := strings.TrimSpace(string(current))
+	t.Logf("current clocksource %s, available: %s", source, strings.TrimSpace(string(available)))
+
+	if expected, ok := expectedClockSources[arch]; !ok {
+		t.Logf("no expected clocksource for %s", arch)
+	} else {
+		found := false
+		for _, want := range expected {
+			if source == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("current clocksource is %s, want one of %v on %s", source, expected, arch)
+		}
+	}
+
+	out, err := exec.Command("dmesg").CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not read dmesg: %v", err)
+	}
+	for _, line := range unstableClockSourceRe.FindAllString(string(out), -1) {
+		t.Errorf("kernel reported an unstable clocksource: %s", strings.TrimSpace(line))
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timesync is a CIT suite for testing that the guest keeps accurate
+// time.
+package timesync
+
+import (
+	"github.com/GoogleCloudPlatform/cloud-image-tests"
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// Name is the name of the test package. It must match the directory name.
+var Name = "timesync"
+
+// TestSetup sets up the test workflow.
+func TestSetup(t *imagetest.TestWorkflow) error {
+	if !utils.HasFeature(t.Image, "WINDOWS") {
+		clockjumpvm, err := t.CreateTestVM("clockjump")
+		if err != nil {
+			return err
+		}
+		clockjumpvm.RunTests("TestClockJump|TestClockSource")
+
+		clockresumevm, err := t.CreateTestVM("clockresume")
+		if err != nil {
+			return err
+		}
+		clockresumevm.AddScope("https://www.googleapis.com/auth/cloud-platform")
+		clockresumevm.RunTests("TestClockOnResume")
+	} else {
+		windowstimevm, err := t.CreateTestVM("windowstime")
+		if err != nil {
+			return err
+		}
+		windowstimevm.RunTests("TestWindowsTimeService")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const (
+	// windowsTimeSource is the time source configured in GCE Windows images.
+	windowsTimeSource = "metadata.google.internal"
+	// windowsSyncedOffset is the offset below which the Windows clock counts
+	// as synced. W32Time disciplines the clock less tightly than chrony.
+	windowsSyncedOffset = time.Second
+)
+
+// w32tmValue returns the value of key from w32tm /query output, without the
+// trailing source of the setting such as "(Local)".
+func w32tmValue(out, key string) string {
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || strings.TrimSpace(k) != key {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if i := strings.LastIndex(v, " ("); i >= 0 {
+			v = v[:i]
+		}
+		return v
+	}
+	return ""
+}
+
+// TestWindowsTimeService validates that W32Time is running and syncing from
+// the metadata server, and that the clock is in sync with it.
+func TestWindowsTimeService(t *testing.T) {
+	utils.WindowsOnly(t)
+	status, err := utils.RunPowershellCmd("(Get-Service W32Time).Status")
+	if err != nil {
+		t.Fatalf("could not get W32Time status: %v %s", err, status.Stderr)
+	}
+	if s := strings.TrimSpace(status.Stdout); s != "Running" {
+		t.Errorf("W32Time status is %s, want Running", s)
+	}
+
+	config, err := utils.RunPowershellCmd("w32tm /query /configuration")
+	if err != nil {
+		t.Fatalf("w32tm /query /configuration failed: %v %s", err, config.Stdout)
+	}
+	syncType := w32tmValue(config.Stdout, "Type")
+	ntpServer := w32tmValue(config.Stdout, "NtpServer")
+	t.Logf("W32Time type is %s, NtpServer is %s", syncType, ntpServer)
+	if syncType != "NTP" {
+		t.Errorf("W32Time type is %q, want NTP rather than domain hierarchy sync", syncType)
+	}
+	var servers []string
+	for _, server := range strings.Fields(ntpServer) {
+		name, _, _ := strings.Cut(server, ",")
+		servers = append(servers, name)
+	}
+	if len(servers) == 0 || servers[0] != windowsTimeSource {
+		t.Errorf("W32Time NtpServer is %q, want %s", ntpServer, windowsTimeSource)
+	}
+
+	source, err := utils.RunPowershellCmd("w32tm /query /source")
+	if err != nil {
+		t.Fatalf("w32tm /query /source failed: %v %s", err, source.Stdout)
+	}
+	t.Logf("W32Time current source is %s", strings.TrimSpace(source.Stdout))
+
+	var offset time.Duration
+	for i := 0; i < 3; i++ {
+		if offset, err = clockOffset(metadataNTPServer); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("offset from %s is %v", metadataNTPServer, offset.Round(time.Millisecond))
+	if offset.Abs() > windowsSyncedOffset {
+		t.Errorf("clock offset is %v, want at most %v", offset, windowsSyncedOffset)
+	}
+}
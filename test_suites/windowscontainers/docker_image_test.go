@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windowscontainers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// TestContainerSaveLoad validates that an image exported with docker save can
+// be loaded back with docker load and still runs.
+func TestContainerSaveLoad(t *testing.T) {
+	utils.WindowsContainersOnly(t)
+	imageName := "saveload:test"
+	greeting := "Hello from a loaded image"
+	tarball := filepath.Join(t.TempDir(), "saveload.tar")
+	t.Cleanup(func() {
+		command := fmt.Sprintf("docker rmi -f %s", imageName)
+		if output, err := utils.RunPowershellCmd(command); err != nil {
+			t.Logf("Cleanup command %q failed: %v %s", command, err, output.Stderr)
+		}
+	})
+
+	// Tag the base image under a test name so that removing it doesn't remove
+	// the base image other tests rely on.
+	command := fmt.Sprintf("docker tag %s:%s %s", baseContainerImageRepo, baseContainerImageTag, imageName)
+	utils.FailOnPowershellFail(command, "Error tagging image", t)
+
+	output, err := utils.RunPowershellCmd(fmt.Sprintf("docker image inspect --format '{{.Id}}' %s", imageName))
+	if err != nil {
+		t.Fatalf("Error inspecting image %s: %v", imageName, err)
+	}
+	wantID := strings.TrimSpace(output.Stdout)
+
+	command = fmt.Sprintf("docker save -o %s %s", tarball, imageName)
+	utils.FailOnPowershellFail(command, "Error saving image", t)
+	info, err := os.Stat(tarball)
+	if err != nil {
+		t.Fatalf("Saved image %s not found: %v", tarball, err)
+	}
+	t.Logf("Saved image %s to %s, %d bytes", imageName, tarball, info.Size())
+
+	command = fmt.Sprintf("docker rmi %s", imageName)
+	utils.FailOnPowershellFail(command, "Error removing image", t)
+
+	command = fmt.Sprintf("docker load -i %s", tarball)
+	utils.FailOnPowershellFail(command, "Error loading image", t)
+
+	output, err = utils.RunPowershellCmd(fmt.Sprintf("docker image inspect --format '{{.Id}}' %s", imageName))
+	if err != nil {
+		t.Fatalf("Loaded image %s not found: %v", imageName, err)
+	}
+	if gotID := strings.TrimSpace(output.Stdout); gotID != wantID {
+		t.Errorf("Loaded image ID is %s, want %s", gotID, wantID)
+	}
+
+	output, err = utils.RunPowershellCmd(fmt.Sprintf("docker run --rm %s cmd.exe /c \"echo %s\"", imageName, greeting))
+	if err != nil {
+		t.Fatalf("Error running loaded image: %v %s", err, output.Stderr)
+	}
+	if !strings.Contains(output.Stdout, greeting) {
+		t.Fatalf("Loaded image output '%s' does not contain '%s'", output.Stdout, greeting)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windowscontainers
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// natNetworkRange is the private range Docker picks the default nat network
+// subnet from.
+var _, natNetworkRange, _ = net.ParseCIDR("172.16.0.0/12")
+
+type dockerNetwork struct {
+	Name   string `json:"Name"`
+	Driver string `json:"Driver"`
+	IPAM   struct {
+		Config []struct {
+			Subnet  string `json:"Subnet"`
+			Gateway string `json:"Gateway"`
+		} `json:"Config"`
+	} `json:"IPAM"`
+}
+
+// TestContainerNetworkMode validates that the default nat network exists with
+// a usable subnet and that WinNAT is running to serve it.
+func TestContainerNetworkMode(t *testing.T) {
+	utils.WindowsContainersOnly(t)
+	output, err := utils.RunPowershellCmd("docker network inspect nat")
+	if err != nil {
+		t.Fatalf("Cannot inspect the nat network: %v %s", err, output.Stderr)
+	}
+	var networks []dockerNetwork
+	if err := json.Unmarshal([]byte(output.Stdout), &networks); err != nil || len(networks) != 1 {
+		t.Fatalf("Cannot parse nat network configuration %q: %v", output.Stdout, err)
+	}
+	nat := networks[0]
+	if nat.Driver != "nat" {
+		t.Errorf("nat network uses driver %q, want nat. Configuration: %s", nat.Driver, output.Stdout)
+	}
+	if len(nat.IPAM.Config) == 0 {
+		t.Fatalf("nat network has no subnet. Configuration: %s", output.Stdout)
+	}
+	_, subnet, err := net.ParseCIDR(nat.IPAM.Config[0].Subnet)
+	if err != nil {
+		t.Fatalf("nat network has malformed subnet %q: %v", nat.IPAM.Config[0].Subnet, err)
+	}
+	if !natNetworkRange.Contains(subnet.IP) {
+		t.Errorf("nat network subnet %s is outside %s. Configuration: %s", subnet, natNetworkRange, output.Stdout)
+	}
+	if ip, err := utils.GetMetadata(utils.Context(t), "instance", "network-interfaces", "0", "ip"); err == nil && subnet.Contains(net.ParseIP(ip)) {
+		t.Errorf("nat network subnet %s overlaps the instance IP %s", subnet, ip)
+	}
+
+	output, err = utils.RunPowershellCmd("(Get-Service winnat).Status")
+	if err != nil {
+		t.Fatalf("Cannot get WinNAT service status: %v %s", err, output.Stderr)
+	}
+	if status := strings.TrimSpace(output.Stdout); status != "Running" {
+		t.Errorf("WinNAT service is %s, want Running", status)
+	}
+	output, err = utils.RunPowershellCmd("Get-NetNat | Select-Object -ExpandProperty InternalIPInterfaceAddressPrefix")
+	if err != nil {
+		t.Fatalf("Cannot get WinNAT configuration: %v %s", err, output.Stderr)
+	}
+	if prefixes := strings.Fields(output.Stdout); len(prefixes) > 0 && !strings.Contains(output.Stdout, subnet.String()) {
+		t.Errorf("WinNAT serves %v, which does not include the nat network subnet %s", prefixes, subnet)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windowscontainers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// TestVolumePersistence validates that data written to a named volume by one
+// container is available to a new container which mounts the same volume.
+func TestVolumePersistence(t *testing.T) {
+	utils.WindowsContainersOnly(t)
+	volumeName := "persistvolume"
+	writerName := "volume_writer"
+	readerName := "volume_reader"
+	mountDir := "C:\\persist_dir"
+	testFileContents := "Written by the first container"
+	t.Cleanup(func() {
+		for _, command := range []string{
+			fmt.Sprintf("docker rm -f %s %s", writerName, readerName),
+			fmt.Sprintf("docker volume rm -f %s", volumeName),
+		} {
+			if output, err := utils.RunPowershellCmd(command); err != nil {
+				t.Logf("Cleanup command %q failed: %v %s", command, err, output.Stderr)
+			}
+		}
+	})
+
+	command := fmt.Sprintf("docker volume create %s", volumeName)
+	utils.FailOnPowershellFail(command, "Error creating docker volume", t)
+
+	command = fmt.Sprintf("docker run --name %s -v %s:%s %s:%s cmd.exe /c \"echo %s> %s\\data.txt\"", writerName, volumeName, mountDir, baseContainerImageRepo, baseContainerImageTag, testFileContents, mountDir)
+	utils.FailOnPowershellFail(command, "Error writing to volume from container", t)
+
+	command = fmt.Sprintf("docker rm %s", writerName)
+	utils.FailOnPowershellFail(command, "Error removing writer container", t)
+
+	command = fmt.Sprintf("docker run --name %s -v %s:%s %s:%s cmd.exe /c \"type %s\\data.txt\"", readerName, volumeName, mountDir, baseContainerImageRepo, baseContainerImageTag, mountDir)
+	output, err := utils.RunPowershellCmd(command)
+	if err != nil {
+		t.Fatalf("Error reading volume from new container: %v %s", err, output.Stderr)
+	}
+	if !strings.Contains(output.Stdout, testFileContents) {
+		t.Fatalf("Data in volume %s did not persist across containers, got '%s' want '%s'", volumeName, output.Stdout, testFileContents)
+	}
+}
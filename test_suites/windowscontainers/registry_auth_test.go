@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windowscontainers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+type dockerConfig struct {
+	CredHelpers map[string]string `json:"credHelpers"`
+	CredsStore  string            `json:"credsStore"`
+}
+
+// TestContainerRegistryAuth validates that the docker credential helper is
+// configured for the registry of a private image and that the image can be
+// pulled with the instance service account.
+func TestContainerRegistryAuth(t *testing.T) {
+	utils.WindowsContainersOnly(t)
+	image, err := utils.GetMetadata(utils.Context(t), "instance", "attributes", "container-registry-image")
+	if err != nil {
+		t.Skip("No private container image configured")
+	}
+	registry, _, _ := strings.Cut(image, "/")
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("Could not find home directory: %v", err)
+	}
+	configFile := filepath.Join(home, ".docker", "config.json")
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Skipf("No docker config found at %s: %v", configFile, err)
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Could not parse docker config %s: %v", configFile, err)
+	}
+	t.Logf("Docker config credHelpers: %v, credsStore: %q", config.CredHelpers, config.CredsStore)
+	helper, ok := config.CredHelpers[registry]
+	if !ok {
+		t.Skipf("No credential helper configured for registry %s", registry)
+	}
+	if _, err := exec.LookPath("docker-credential-" + helper); err != nil {
+		t.Skipf("Credential helper docker-credential-%s for registry %s is not installed", helper, registry)
+	}
+
+	t.Cleanup(func() {
+		command := fmt.Sprintf("docker rmi -f %s", image)
+		if output, err := utils.RunPowershellCmd(command); err != nil {
+			t.Logf("Cleanup command %q failed: %v %s", command, err, output.Stderr)
+		}
+	})
+	output, err := utils.RunPowershellCmd(fmt.Sprintf("docker pull %s", image))
+	if err != nil || output.Exitcode != 0 {
+		t.Fatalf("Could not pull %s using credential helper %s: %v %s", image, helper, err, output.Stderr)
+	}
+}
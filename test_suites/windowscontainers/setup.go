@@ -16,6 +16,9 @@
 package windowscontainers
 
 import (
+	"flag"
+	"regexp"
+
 	"github.com/GoogleCloudPlatform/cloud-image-tests"
 	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
 )
@@ -23,13 +26,26 @@ import (
 // Name is the name of the test package. It must match the directory name.
 var Name = "windowscontainers"
 
+var privateImage = flag.String("windowscontainers_private_image", "", "image in a private Artifact Registry or Container Registry repo, readable by the test VM service account, to pull in TestContainerRegistryAuth")
+
 // TestSetup sets up the test workflow.
 func TestSetup(t *imagetest.TestWorkflow) error {
 	if utils.HasFeature(t.Image, "WINDOWS") {
-		_, err := t.CreateTestVM("vm")
+		vm, err := t.CreateTestVM("vm")
+		if err != nil {
+			return err
+		}
+		if *privateImage != "" {
+			vm.AddScope("https://www.googleapis.com/auth/cloud-platform")
+			vm.AddMetadata("container-registry-image", *privateImage)
+		}
+	} else if regexp.MustCompile(`^cos-`).MatchString(t.Image.Family) {
+		// Container-Optimized OS ships docker, so check its storage driver too.
+		vm, err := t.CreateTestVM("linuxvm")
 		if err != nil {
 			return err
 		}
+		vm.RunTests("TestContainerStorageDriver")
 	}
 
 	return nil
@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package windowscontainers
+
+import (
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+// expectedStorageDrivers is the docker storage driver expected on each
+// platform.
+var expectedStorageDrivers = map[string]string{
+	"windows": "windowsfilter",
+	"linux":   "overlay2",
+}
+
+// dockerInfo is the subset of `docker info` output checked for the storage
+// driver.
+type dockerInfo struct {
+	Driver        string
+	DriverStatus  [][2]string
+	DockerRootDir string
+	Warnings      []string
+}
+
+// TestContainerStorageDriver validates that docker uses the storage driver
+// expected for the platform and reports it healthy.
+func TestContainerStorageDriver(t *testing.T) {
+	var out []byte
+	if utils.IsWindows() {
+		utils.WindowsContainersOnly(t)
+		output, err := utils.RunPowershellCmd("docker info --format '{{json .}}'")
+		if err != nil {
+			t.Fatalf("Cannot get Docker info: %v %s", err, output.Stderr)
+		}
+		out = []byte(output.Stdout)
+	} else {
+		if !utils.CheckLinuxCmdExists("docker") {
+			t.Skip("docker is not installed")
+		}
+		var err error
+		if out, err = exec.Command("docker", "info", "--format", "{{json .}}").Output(); err != nil {
+			t.Fatalf("Cannot get Docker info: %v", err)
+		}
+	}
+
+	var info dockerInfo
+	if err := json.Unmarshal(out, &info); err != nil {
+		t.Fatalf("Cannot parse Docker info %q: %v", out, err)
+	}
+	t.Logf("Docker storage driver is %s, root dir %s, status %v", info.Driver, info.DockerRootDir, info.DriverStatus)
+	if want := expectedStorageDrivers[runtime.GOOS]; info.Driver != want {
+		t.Errorf("Docker storage driver is %q, want %q", info.Driver, want)
+	}
+	for _, status := range info.DriverStatus {
+		// overlay2 reports whether its backing filesystem supports d_type,
+		// without which layers are silently corrupted.
+		if status[0] == "Supports d_type" && status[1] != "true" {
+			t.Errorf("Docker storage driver %s reports %s: %s", info.Driver, status[0], status[1])
+		}
+	}
+	for _, warning := range info.Warnings {
+		if strings.Contains(strings.ToLower(warning), "storage") || strings.Contains(warning, info.Driver) {
+			t.Errorf("Docker reports a storage driver warning: %s", warning)
+		}
+	}
+}
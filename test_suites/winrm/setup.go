@@ -46,5 +46,12 @@ func TestSetup(t *imagetest.TestWorkflow) error {
 	vm2.AddMetadata("winrm-passwd", passwd)
 	vm2.RunTests("TestWaitForWinrmConnection")
 
+	vm3, err := t.CreateTestVM("https")
+	if err != nil {
+		return err
+	}
+	vm3.AddMetadata("winrm-passwd", passwd)
+	vm3.RunTests("TestWinRMHTTPS")
+
 	return nil
 }
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package winrm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-image-tests/utils"
+)
+
+const winrmHTTPSPort = 5986
+
+// TestWinRMHTTPS validates that the WinRM HTTPS listener has a usable
+// certificate and accepts a loopback session.
+func TestWinRMHTTPS(t *testing.T) {
+	utils.WindowsOnly(t)
+	out, err := utils.RunPowershellCmd(`Get-ChildItem WSMan:\localhost\Listener | Where-Object { $_.Keys -contains 'Transport=HTTPS' } | ForEach-Object { (Get-ChildItem $_.PSPath | Where-Object Name -eq 'CertificateThumbprint').Value }`)
+	if err != nil {
+		t.Fatalf("could not list WinRM listeners: %v %s", err, out.Stderr)
+	}
+	thumbprint := strings.TrimSpace(out.Stdout)
+	if thumbprint == "" {
+		t.Skip("no WinRM HTTPS listener is configured, only HTTP is expected")
+	}
+
+	out, err = utils.RunPowershellCmd(fmt.Sprintf(`$c = Get-Item Cert:\LocalMachine\My\%s; $valid = (Get-Date) -ge $c.NotBefore -and (Get-Date) -le $c.NotAfter; "$($c.Subject)|$($c.NotBefore.ToString('o'))|$($c.NotAfter.ToString('o'))|$($c.HasPrivateKey)|$valid"`, thumbprint))
+	if err != nil {
+		t.Fatalf("could not find WinRM HTTPS certificate %s: %v %s", thumbprint, err, out.Stderr)
+	}
+	fields := strings.Split(strings.TrimSpace(out.Stdout), "|")
+	if len(fields) != 5 {
+		t.Fatalf("unexpected certificate details %q", out.Stdout)
+	}
+	t.Logf("WinRM HTTPS certificate %s for %s is valid from %s to %s", thumbprint, fields[0], fields[1], fields[2])
+	if fields[3] != "True" {
+		t.Errorf("WinRM HTTPS certificate %s has no private key", thumbprint)
+	}
+	if fields[4] != "True" {
+		t.Errorf("WinRM HTTPS certificate %s is not currently valid", thumbprint)
+	}
+
+	passwd, err := utils.GetMetadata(utils.Context(t), "instance", "attributes", "winrm-passwd")
+	if err != nil {
+		t.Fatalf("could not fetch winrm password: %v", err)
+	}
+	passwd = strings.TrimSpace(passwd)
+	runOrFail(t, fmt.Sprintf(`net user "%s" "%s" /add`, user, passwd), fmt.Sprintf("could not add user %s", user))
+	runOrFail(t, fmt.Sprintf(`Add-LocalGroupMember -Group Administrators -Member "%s"`, user), fmt.Sprintf("could not add user %s to administrators", user))
+	// The certificate is self signed for the instance name, so CA and CN
+	// checks are skipped for the loopback connection.
+	out, err = utils.RunPowershellCmd(fmt.Sprintf(`Invoke-Command -UseSSL -Port %d -SessionOption(New-PSSessionOption -SkipCACheck -SkipCNCheck -SkipRevocationCheck) -ScriptBlock{ hostname } -ComputerName localhost -Credential (New-Object -TypeName System.Management.Automation.PSCredential -ArgumentList "$env:COMPUTERNAME\%s", (ConvertTo-SecureString -String '%s' -AsPlainText -Force))`, winrmHTTPSPort, user, passwd))
+	if err != nil {
+		t.Fatalf("could not open loopback WinRM session over HTTPS: %v %s", err, out.Stderr)
+	}
+	t.Logf("loopback WinRM HTTPS session ran on %s", strings.TrimSpace(out.Stdout))
+}
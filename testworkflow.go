@@ -209,6 +209,10 @@ func (t *TestWorkflow) appendCreateDisksStep(diskParams *compute.Disk) (*daisy.S
 	bootdisk.SourceImage = t.ImageURL
 	bootdisk.Type = diskParams.Type
 	bootdisk.Zone = diskParams.Zone
+	// The boot disk defaults to the size of the image.
+	if diskParams.SizeGb != 0 {
+		bootdisk.SizeGb = strconv.FormatInt(diskParams.SizeGb, 10)
+	}
 
 	createDisks := &daisy.CreateDisks{bootdisk}
 
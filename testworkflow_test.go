@@ -309,6 +309,17 @@ func TestAppendCreateDisksStep(t *testing.T) {
 	if disks[1].Name != "diskname2" {
 		t.Error("CreateDisks step is malformed")
 	}
+	if disks[0].SizeGb != "" {
+		t.Errorf("boot disk without SizeGb has size %q, want the image size", disks[0].SizeGb)
+	}
+	step3, err := twf.appendCreateDisksStep(&compute.Disk{Name: "diskname3", SizeGb: 50})
+	if err != nil {
+		t.Fatalf("failed to add boot disk with size to test workflow: %v", err)
+	}
+	disks = []*daisy.Disk(*step3.CreateDisks)
+	if len(disks) != 3 || disks[2].SizeGb != "50" {
+		t.Error("boot disk SizeGb was not set")
+	}
 }
 
 func TestAppendCreateVMStep(t *testing.T) {
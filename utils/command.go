@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// ErrCommandTimeout is returned by RunCommandWithTimeout when the command does
+// not exit in time.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// commandWaitDelay is how long to wait for output to be drained after the
+// command is killed.
+const commandWaitDelay = 5 * time.Second
+
+// RunCommandWithTimeout runs a command and returns its combined stdout and
+// stderr. If the command has not exited after timeout, it is killed along with
+// every process it started and an error wrapping ErrCommandTimeout is returned
+// with the output collected so far.
+func RunCommandWithTimeout(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = commandWaitDelay
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return out.Bytes(), err
+	case <-timer.C:
+		killProcessGroup(cmd)
+		<-done
+		return out.Bytes(), fmt.Errorf("%s: %w after %v", name, ErrCommandTimeout, timeout)
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		return out.Bytes(), ctx.Err()
+	}
+}
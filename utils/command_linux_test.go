@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processRunning reports whether pid is running and not a zombie.
+func processRunning(t *testing.T, pid int) bool {
+	t.Helper()
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if os.IsNotExist(err) {
+		return false
+	} else if err != nil {
+		t.Fatalf("could not read state of process %d: %v", pid, err)
+	}
+	// The state follows the parenthesized command name.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunCommandWithTimeout(t *testing.T) {
+	out, err := RunCommandWithTimeout(context.Background(), 10*time.Second, "echo", "hello")
+	if err != nil {
+		t.Fatalf("RunCommandWithTimeout(echo) failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "hello" {
+		t.Errorf("RunCommandWithTimeout(echo) = %q, want %q", got, "hello")
+	}
+
+	_, err = RunCommandWithTimeout(context.Background(), 10*time.Second, "false")
+	if err == nil || errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("RunCommandWithTimeout(false) = %v, want exit error", err)
+	}
+}
+
+func TestRunCommandWithTimeoutExpires(t *testing.T) {
+	start := time.Now()
+	_, err := RunCommandWithTimeout(context.Background(), 100*time.Millisecond, "sleep", "60")
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("RunCommandWithTimeout(sleep 60) = %v, want %v", err, ErrCommandTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("RunCommandWithTimeout(sleep 60) returned after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestRunCommandWithTimeoutCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err := RunCommandWithTimeout(ctx, time.Minute, "sleep", "60")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RunCommandWithTimeout(sleep 60) = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRunCommandWithTimeoutKillsProcessGroup(t *testing.T) {
+	// The shell prints the pid of a background child which also holds stdout
+	// open, then waits for it.
+	out, err := RunCommandWithTimeout(context.Background(), time.Second, "sh", "-c", "sleep 60 & echo $!; wait")
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Fatalf("RunCommandWithTimeout(sh) = %v, want %v", err, ErrCommandTimeout)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("could not parse child pid from %q: %v", out, err)
+	}
+	for start := time.Now(); processRunning(t, pid); time.Sleep(100 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("child process %d is still running after timeout", pid)
+		}
+	}
+}
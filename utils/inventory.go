@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// InstalledPackage is a package installed on the guest.
+type InstalledPackage struct {
+	Name         string
+	Architecture string
+	Version      string
+}
+
+// ListInstalledPackages returns the packages installed by the guest's
+// package manager: dpkg, rpm or googet.
+func ListInstalledPackages(ctx context.Context) ([]InstalledPackage, error) {
+	if IsWindows() {
+		googet := filepath.Join(os.Getenv("ProgramData"), "GooGet", "googet.exe")
+		out, err := exec.CommandContext(ctx, googet, "installed").Output()
+		if err != nil {
+			return nil, fmt.Errorf("googet installed failed: %v", err)
+		}
+		return parseGooGetInstalled(string(out)), nil
+	}
+	switch {
+	case CheckLinuxCmdExists("dpkg-query"):
+		out, err := exec.CommandContext(ctx, "dpkg-query", "-W", "-f", `${Package} ${Architecture} ${Version}\n`).Output()
+		if err != nil {
+			return nil, fmt.Errorf("dpkg-query failed: %v", err)
+		}
+		return parsePackageLines(string(out)), nil
+	case CheckLinuxCmdExists("rpm"):
+		out, err := exec.CommandContext(ctx, "rpm", "-qa", "--queryformat", `%{NAME} %{ARCH} %|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\n`).Output()
+		if err != nil {
+			return nil, fmt.Errorf("rpm -qa failed: %v", err)
+		}
+		return parsePackageLines(string(out)), nil
+	}
+	return nil, fmt.Errorf("no supported package manager found")
+}
+
+// parsePackageLines parses lines of "name architecture version".
+func parsePackageLines(out string) []InstalledPackage {
+	var pkgs []InstalledPackage
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		pkgs = append(pkgs, InstalledPackage{Name: fields[0], Architecture: fields[1], Version: fields[2]})
+	}
+	return pkgs
+}
+
+// parseGooGetInstalled parses the output of googet installed, which lists
+// packages as "name.architecture version".
+func parseGooGetInstalled(out string) []InstalledPackage {
+	var pkgs []InstalledPackage
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		dot := strings.LastIndex(fields[0], ".")
+		if dot < 1 {
+			continue
+		}
+		pkgs = append(pkgs, InstalledPackage{Name: fields[0][:dot], Architecture: fields[0][dot+1:], Version: fields[1]})
+	}
+	return pkgs
+}
+
+// OSInfo describes the guest operating system.
+type OSInfo struct {
+	Hostname string
+	// ShortName is the os-release ID on Linux, or "windows".
+	ShortName string
+	// Version is the os-release VERSION_ID on Linux, or the major, minor and
+	// build number on Windows.
+	Version string
+	// KernelRelease is the kernel release on Linux, or the full build number
+	// including the update revision on Windows.
+	KernelRelease string
+	// Architecture is the normalized architecture of the running kernel.
+	Architecture string
+}
+
+// GetOSInfo returns information about the guest operating system.
+func GetOSInfo(ctx context.Context) (OSInfo, error) {
+	var info OSInfo
+	var err error
+	if info.Hostname, err = os.Hostname(); err != nil {
+		return info, fmt.Errorf("could not get hostname: %v", err)
+	}
+	if info.Architecture, err = GuestArchitecture(); err != nil {
+		return info, err
+	}
+	id, err := GetImageIdentity(ctx)
+	if err != nil {
+		return info, err
+	}
+	info.ShortName = id.OSID
+	if IsWindows() {
+		out, err := RunPowershellCmd(`$v = Get-ItemProperty 'HKLM:\SOFTWARE\Microsoft\Windows NT\CurrentVersion'; "$([Environment]::OSVersion.Version.Major).$([Environment]::OSVersion.Version.Minor).$($v.CurrentBuildNumber) $($v.CurrentBuildNumber).$($v.UBR)"`)
+		if err != nil {
+			return info, fmt.Errorf("could not get windows version: %v %s", err, out.Stderr)
+		}
+		fields := strings.Fields(out.Stdout)
+		if len(fields) != 2 {
+			return info, fmt.Errorf("unexpected windows version %q", out.Stdout)
+		}
+		info.Version, info.KernelRelease = fields[0], fields[1]
+		return info, nil
+	}
+	info.Version = id.OSVersion
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return info, fmt.Errorf("could not get kernel release: %v", err)
+	}
+	info.KernelRelease = strings.TrimSpace(string(release))
+	return info, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePackageLines(t *testing.T) {
+	out := "bash amd64 5.2.15-2+b2\nlibc6 amd64 2.36-9+deb12u4\n\nkernel x86_64 0:6.1.0-1\nmalformed line\n"
+	want := []InstalledPackage{
+		{Name: "bash", Architecture: "amd64", Version: "5.2.15-2+b2"},
+		{Name: "libc6", Architecture: "amd64", Version: "2.36-9+deb12u4"},
+		{Name: "kernel", Architecture: "x86_64", Version: "0:6.1.0-1"},
+	}
+	if got := parsePackageLines(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePackageLines() = %v, want %v", got, want)
+	}
+}
+
+func TestParseGooGetInstalled(t *testing.T) {
+	out := "Installed packages:\n  google-compute-engine-sysprep.noarch 20240104.00@1\n  googet.x86_64 2.18.5@0\n"
+	want := []InstalledPackage{
+		{Name: "google-compute-engine-sysprep", Architecture: "noarch", Version: "20240104.00@1"},
+		{Name: "googet", Architecture: "x86_64", Version: "2.18.5@0"},
+	}
+	if got := parseGooGetInstalled(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGooGetInstalled() = %v, want %v", got, want)
+	}
+}
@@ -49,6 +49,38 @@ func GetMetadata(ctx context.Context, elem ...string) (string, error) {
 	return body, err
 }
 
+// GetMetadataWaitForChange is similar to GetMetadata but blocks until the
+// metadata entry differs from its current value, returning the new value.
+func GetMetadataWaitForChange(ctx context.Context, elem ...string) (string, error) {
+	path, err := url.JoinPath(metadataURLPrefix, elem...)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse metadata url: %+s", err)
+	}
+
+	body, _, err := doHTTPGet(ctx, path+"?wait_for_change=true")
+	return body, err
+}
+
+// GetMetadataWaitForChangeETag blocks until the ETag of the metadata entry
+// differs from lastETag or timeoutSec seconds pass, whichever is first. It
+// returns the value of the entry and its ETag, which is unchanged on timeout.
+func GetMetadataWaitForChangeETag(ctx context.Context, lastETag string, timeoutSec int, elem ...string) (string, string, error) {
+	path, err := url.JoinPath(metadataURLPrefix, elem...)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse metadata url: %+s", err)
+	}
+	query := url.Values{}
+	query.Set("wait_for_change", "true")
+	query.Set("last_etag", lastETag)
+	query.Set("timeout_sec", fmt.Sprintf("%d", timeoutSec))
+
+	body, headers, err := doHTTPGet(ctx, path+"?"+query.Encode())
+	if err != nil {
+		return "", "", err
+	}
+	return body, headers.Get("ETag"), nil
+}
+
 // GetMetadataWithHeaders is similar to GetMetadata it only differs on the return where GetMetadata
 // returns only the response's body as a string and an error GetMetadataWithHeaders returns the
 // response's body as a string, the headers and an error.
@@ -0,0 +1,154 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const rebootGuardFile = "reboot-guard"
+
+var (
+	// ErrRebootGuardHeld is returned when a test tries to begin a reboot while
+	// another test's reboot is still pending.
+	ErrRebootGuardHeld = errors.New("reboot guard is held by another test")
+)
+
+// RebootState is the state of a test's reboot in a RebootGuard.
+type RebootState int
+
+const (
+	// RebootNone means the test has no reboot in progress.
+	RebootNone RebootState = iota
+	// RebootPending means the test has begun a reboot which has not happened yet.
+	RebootPending
+	// RebootDone means the instance has rebooted since the test began its reboot.
+	RebootDone
+)
+
+// RebootGuard serializes tests which reboot the instance, and records which
+// test initiated a reboot so that after the reboot only that test runs its
+// post-reboot continuation. The state is kept in a marker file which survives
+// reboots.
+type RebootGuard struct {
+	dir    string
+	bootID func() (string, error)
+}
+
+// NewRebootGuard returns a RebootGuard which keeps its state in dir.
+func NewRebootGuard(dir string) *RebootGuard {
+	return &RebootGuard{dir: dir, bootID: currentBootID}
+}
+
+// DefaultRebootGuard returns the RebootGuard shared by all tests on the instance.
+func DefaultRebootGuard() *RebootGuard {
+	if IsWindows() {
+		return NewRebootGuard(`C:\cit`)
+	}
+	return NewRebootGuard("/var/lib/cit")
+}
+
+// Begin records that test is about to reboot the instance. It returns
+// ErrRebootGuardHeld if another test has a reboot in progress.
+func (g *RebootGuard) Begin(test string) error {
+	owner, _, err := g.read()
+	if err != nil {
+		return err
+	}
+	if owner != "" && owner != test {
+		return fmt.Errorf("%w: %s", ErrRebootGuardHeld, owner)
+	}
+	bootID, err := g.bootID()
+	if err != nil {
+		return fmt.Errorf("could not get boot id: %v", err)
+	}
+	if err := os.MkdirAll(g.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.dir, rebootGuardFile), []byte(test+"\n"+bootID+"\n"), 0644)
+}
+
+// State returns the state of test's reboot.
+func (g *RebootGuard) State(test string) (RebootState, error) {
+	owner, bootID, err := g.read()
+	if err != nil {
+		return RebootNone, err
+	}
+	if owner != test {
+		return RebootNone, nil
+	}
+	current, err := g.bootID()
+	if err != nil {
+		return RebootNone, fmt.Errorf("could not get boot id: %v", err)
+	}
+	if current == bootID {
+		return RebootPending, nil
+	}
+	return RebootDone, nil
+}
+
+// Owner returns the test with a reboot in progress, or an empty string if
+// there is none.
+func (g *RebootGuard) Owner() (string, error) {
+	owner, _, err := g.read()
+	return owner, err
+}
+
+// Release clears test's reboot from the guard, allowing other tests to reboot.
+// It is a no-op if test does not hold the guard.
+func (g *RebootGuard) Release(test string) error {
+	owner, _, err := g.read()
+	if err != nil {
+		return err
+	}
+	if owner != test {
+		return nil
+	}
+	return os.Remove(filepath.Join(g.dir, rebootGuardFile))
+}
+
+func (g *RebootGuard) read() (owner, bootID string, err error) {
+	data, err := os.ReadFile(filepath.Join(g.dir, rebootGuardFile))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("could not read reboot guard: %v", err)
+	}
+	fields := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("malformed reboot guard %q", data)
+	}
+	return fields[0], fields[1], nil
+}
+
+// currentBootID returns an identifier which changes every time the instance boots.
+func currentBootID() (string, error) {
+	if IsWindows() {
+		out, err := RunPowershellCmd("(Get-CimInstance -ClassName Win32_OperatingSystem).LastBootUpTime.ToFileTimeUtc()")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(out.Stdout), nil
+	}
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestRebootGuard(t *testing.T, bootID *string) *RebootGuard {
+	t.Helper()
+	g := NewRebootGuard(t.TempDir())
+	g.bootID = func() (string, error) { return *bootID, nil }
+	return g
+}
+
+func TestRebootGuardStateMachine(t *testing.T) {
+	bootID := "boot1"
+	g := newTestRebootGuard(t, &bootID)
+
+	expectState := func(test string, want RebootState) {
+		t.Helper()
+		got, err := g.State(test)
+		if err != nil {
+			t.Fatalf("State(%q) failed: %v", test, err)
+		}
+		if got != want {
+			t.Errorf("State(%q) = %v, want %v", test, got, want)
+		}
+	}
+
+	expectState("TestA", RebootNone)
+	if err := g.Begin("TestA"); err != nil {
+		t.Fatalf("Begin(TestA) failed: %v", err)
+	}
+	expectState("TestA", RebootPending)
+	expectState("TestB", RebootNone)
+	if err := g.Begin("TestB"); !errors.Is(err, ErrRebootGuardHeld) {
+		t.Errorf("Begin(TestB) while TestA holds the guard = %v, want %v", err, ErrRebootGuardHeld)
+	}
+	if owner, err := g.Owner(); err != nil || owner != "TestA" {
+		t.Errorf("Owner() = %q, %v, want TestA", owner, err)
+	}
+
+	bootID = "boot2"
+	expectState("TestA", RebootDone)
+	expectState("TestB", RebootNone)
+	if err := g.Release("TestB"); err != nil {
+		t.Errorf("Release(TestB) failed: %v", err)
+	}
+	expectState("TestA", RebootDone)
+	if err := g.Release("TestA"); err != nil {
+		t.Fatalf("Release(TestA) failed: %v", err)
+	}
+	expectState("TestA", RebootNone)
+	if owner, err := g.Owner(); err != nil || owner != "" {
+		t.Errorf("Owner() after release = %q, %v, want empty", owner, err)
+	}
+	if err := g.Begin("TestB"); err != nil {
+		t.Errorf("Begin(TestB) after release failed: %v", err)
+	}
+	expectState("TestB", RebootPending)
+}
+
+func TestRebootGuardBeginIsIdempotent(t *testing.T) {
+	bootID := "boot1"
+	g := newTestRebootGuard(t, &bootID)
+	for i := 0; i < 2; i++ {
+		if err := g.Begin("TestA"); err != nil {
+			t.Fatalf("Begin(TestA) attempt %d failed: %v", i, err)
+		}
+	}
+	bootID = "boot2"
+	// Beginning again after the reboot starts a new reboot.
+	if err := g.Begin("TestA"); err != nil {
+		t.Fatalf("Begin(TestA) after reboot failed: %v", err)
+	}
+	if got, err := g.State("TestA"); err != nil || got != RebootPending {
+		t.Errorf("State(TestA) = %v, %v, want %v", got, err, RebootPending)
+	}
+}
@@ -73,6 +73,9 @@ type BlockDevice struct {
 	// This allows both to be parsed
 	Size json.Number `json:"size,omitempty"`
 	Type string      `json:"type,omitempty"`
+	// Mountpoint and Children are only set when requested from lsblk.
+	Mountpoint string        `json:"mountpoint,omitempty"`
+	Children   []BlockDevice `json:"children,omitempty"`
 	// Other fields are not currently used.
 	X map[string]any `json:"-"`
 }
@@ -381,6 +384,95 @@ func IsWindows() bool {
 	return false
 }
 
+const (
+	// ArchX86_64 is the normalized name of the x86_64 architecture.
+	ArchX86_64 = "x86_64"
+	// ArchARM64 is the normalized name of the arm64 architecture.
+	ArchARM64 = "arm64"
+)
+
+// NormalizeArchitecture maps the architecture names used by GCE images, Go,
+// uname and Windows to ArchX86_64 or ArchARM64. Unknown names are returned
+// lowercased.
+func NormalizeArchitecture(arch string) string {
+	switch strings.ToLower(arch) {
+	case "x86_64", "amd64", "x64":
+		return ArchX86_64
+	case "arm64", "aarch64":
+		return ArchARM64
+	}
+	return strings.ToLower(arch)
+}
+
+// GuestArchitecture returns the normalized architecture of the running kernel.
+func GuestArchitecture() (string, error) {
+	if IsWindows() {
+		// PROCESSOR_ARCHITEW6432 is set instead when running under emulation.
+		if arch := os.Getenv("PROCESSOR_ARCHITEW6432"); arch != "" {
+			return NormalizeArchitecture(arch), nil
+		}
+		return NormalizeArchitecture(os.Getenv("PROCESSOR_ARCHITECTURE")), nil
+	}
+	out, err := exec.Command("uname", "-m").Output()
+	if err != nil {
+		return "", fmt.Errorf("uname -m failed: %v", err)
+	}
+	return NormalizeArchitecture(strings.TrimSpace(string(out))), nil
+}
+
+// ImageIdentity describes the image an instance was created from, both as
+// published and as reported by the guest OS.
+type ImageIdentity struct {
+	// Name is the image name from the metadata server.
+	Name string
+	// OSID is the os-release ID on Linux, or "windows".
+	OSID string
+	// OSVersion is the os-release VERSION_ID on Linux, or the product name
+	// on Windows.
+	OSVersion string
+}
+
+// GetImageIdentity returns the published image name along with the OS
+// identity reported by the guest.
+func GetImageIdentity(ctx context.Context) (ImageIdentity, error) {
+	var id ImageIdentity
+	image, err := GetMetadata(ctx, "instance", "image")
+	if err != nil {
+		return id, fmt.Errorf("could not get image from metadata: %v", err)
+	}
+	id.Name = filepath.Base(image)
+	if IsWindows() {
+		out, err := RunPowershellCmd(`(Get-ItemProperty 'HKLM:\SOFTWARE\Microsoft\Windows NT\CurrentVersion').ProductName`)
+		if err != nil {
+			return id, fmt.Errorf("could not get windows product name: %v %s", err, out.Stderr)
+		}
+		id.OSID = "windows"
+		id.OSVersion = strings.TrimSpace(out.Stdout)
+		return id, nil
+	}
+	osRelease, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return id, err
+	}
+	for _, line := range strings.Split(string(osRelease), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch key {
+		case "ID":
+			id.OSID = value
+		case "VERSION_ID":
+			id.OSVersion = value
+		}
+	}
+	if id.OSID == "" {
+		return id, fmt.Errorf("no ID in /etc/os-release")
+	}
+	return id, nil
+}
+
 // IsWindowsClient returns true if the image is a client (non-server) Windows image.
 func IsWindowsClient(image string) bool {
 	for _, pattern := range windowsClientImagePatterns {